@@ -1,6 +1,10 @@
 package file
 
-import "io"
+import (
+	"io"
+
+	"TLKV/pb"
+)
 
 // Options
 type Options struct {
@@ -10,6 +14,16 @@ type Options struct {
 	Path     string
 	Flag     int
 	MaxSz    int
+	// Keys encrypts new WAL and value log files with the latest data key and
+	// decrypts existing ones, nil writes them in clear
+	Keys DataKeys
+}
+
+// DataKeys provides the data keys files are encrypted with, LatestDataKey
+// returns nil while encryption is off
+type DataKeys interface {
+	DataKey(id uint64) (*pb.DataKey, error)
+	LatestDataKey() (*pb.DataKey, error)
 }
 
 type CoreFile interface {
@@ -0,0 +1,328 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"TLKV/pb"
+	"TLKV/utils"
+
+	"github.com/pkg/errors"
+)
+
+// manifestVersion follows MagicText at the head of the manifest
+const manifestVersion = 1
+
+// ManifestFile is the log of the changes made to the set of tables, the
+// levels are rebuilt from it when the DB opens. Once enough tables were
+// deleted it is rewritten with only the tables left.
+// MANIFEST:  | MagicText | manifestVersion | changeSet ... |
+// changeSet: | len | crc32 | pb.ManifestChangeSet |
+type ManifestFile struct {
+	opt      *Options
+	f        *os.File
+	lock     sync.Mutex
+	manifest *Manifest
+}
+
+// Manifest is the state the changes of the manifest add up to
+type Manifest struct {
+	Tables map[uint64]TableManifest
+	// Families are the column families created and not dropped since, the
+	// default family 0 always exists and isn't among them
+	Families  map[uint32]FamilyManifest
+	Creations int
+	Deletions int
+}
+
+// TableManifest is where a table lives
+type TableManifest struct {
+	Level        uint8
+	ColumnFamily uint32
+}
+
+// FamilyManifest is the name and the options a column family was created with
+type FamilyManifest struct {
+	Name    string
+	Options *pb.ColumnFamilyOptions
+}
+
+func createManifest() *Manifest {
+	return &Manifest{
+		Tables:   make(map[uint64]TableManifest),
+		Families: make(map[uint32]FamilyManifest),
+	}
+}
+
+// OpenManifestFile opens the manifest in opt.Dir, creating an empty one
+func OpenManifestFile(opt *Options) (*ManifestFile, error) {
+	path := filepath.Join(opt.Dir, utils.ManifestFilename)
+	mf := &ManifestFile{opt: opt}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		m := createManifest()
+		if mf.f, err = writeManifest(opt.Dir, m); err != nil {
+			return nil, err
+		}
+		mf.manifest = m
+		return mf, nil
+	}
+	m, offset, err := replayManifest(f)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "while replaying manifest: %s", path)
+	}
+	// drop a change set that was cut off while it was written
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, err
+	}
+	mf.f, mf.manifest = f, m
+	return mf, nil
+}
+
+// replayManifest applies the change sets of f, it returns the offset past
+// the last complete one
+func replayManifest(f *os.File) (*Manifest, int64, error) {
+	r := bufio.NewReader(f)
+	var magic [8]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, 0, utils.ErrBadMagic
+	}
+	if !bytes.Equal(magic[:4], utils.MagicText[:]) {
+		return nil, 0, utils.ErrBadMagic
+	}
+	if v := binary.BigEndian.Uint32(magic[4:]); v != manifestVersion {
+		return nil, 0, fmt.Errorf("%w: unsupported manifest version %d", utils.ErrBadMagic, v)
+	}
+	m := createManifest()
+	offset := int64(len(magic))
+	for {
+		var lenCrc [8]byte
+		if _, err := io.ReadFull(r, lenCrc[:]); err != nil {
+			break
+		}
+		buf := make([]byte, binary.BigEndian.Uint32(lenCrc[:4]))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			break
+		}
+		if crc32.Checksum(buf, utils.CastagnoliCrcTable) != binary.BigEndian.Uint32(lenCrc[4:]) {
+			break
+		}
+		var set pb.ManifestChangeSet
+		if err := set.Unmarshal(buf); err != nil {
+			return nil, 0, err
+		}
+		if err := m.apply(set.Changes); err != nil {
+			return nil, 0, err
+		}
+		offset += int64(len(lenCrc) + len(buf))
+	}
+	return m, offset, nil
+}
+
+// writeManifest writes m as a single change set to a new manifest, which
+// then atomically replaces the old one
+func writeManifest(dir string, m *Manifest) (*os.File, error) {
+	rewritePath := filepath.Join(dir, utils.ManifestRewriteFilename)
+	f, err := os.OpenFile(rewritePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 8)
+	copy(buf, utils.MagicText[:])
+	binary.BigEndian.PutUint32(buf[4:], manifestVersion)
+	set := &pb.ManifestChangeSet{Changes: m.changes()}
+	if buf, err = appendChangeSet(buf, set); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	manifestPath := filepath.Join(dir, utils.ManifestFilename)
+	if err := os.Rename(rewritePath, manifestPath); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := utils.SyncDir(dir); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func appendChangeSet(buf []byte, set *pb.ManifestChangeSet) ([]byte, error) {
+	data, err := set.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(data, utils.CastagnoliCrcTable))
+	return append(buf, data...), nil
+}
+
+// AddChanges applies the changes atomically and syncs them to disk
+func (mf *ManifestFile) AddChanges(changes []*pb.ManifestChange) error {
+	mf.lock.Lock()
+	defer mf.lock.Unlock()
+	// check them on a copy, a failed change set must leave no trace
+	next := mf.manifest.clone()
+	if err := next.apply(changes); err != nil {
+		return err
+	}
+	if next.Deletions > utils.ManifestDeletionsRewriteThreshold &&
+		next.Deletions > utils.ManifestDeletionsRatio*(next.Creations-next.Deletions) {
+		f, err := writeManifest(mf.opt.Dir, next)
+		if err != nil {
+			return err
+		}
+		mf.f.Close()
+		mf.f = f
+		next.Creations, next.Deletions = len(next.Tables), 0
+		mf.manifest = next
+		return nil
+	}
+	buf, err := appendChangeSet(nil, &pb.ManifestChangeSet{Changes: changes})
+	if err != nil {
+		return err
+	}
+	if _, err := mf.f.Write(buf); err != nil {
+		return err
+	}
+	if err := mf.f.Sync(); err != nil {
+		return err
+	}
+	mf.manifest = next
+	return nil
+}
+
+// Manifest returns a copy of the current state
+func (mf *ManifestFile) Manifest() *Manifest {
+	mf.lock.Lock()
+	defer mf.lock.Unlock()
+	return mf.manifest.clone()
+}
+
+// Close closes the manifest
+func (mf *ManifestFile) Close() error {
+	return mf.f.Close()
+}
+
+func (m *Manifest) clone() *Manifest {
+	out := &Manifest{
+		Tables:    make(map[uint64]TableManifest, len(m.Tables)),
+		Families:  make(map[uint32]FamilyManifest, len(m.Families)),
+		Creations: m.Creations,
+		Deletions: m.Deletions,
+	}
+	for id, tm := range m.Tables {
+		out.Tables[id] = tm
+	}
+	for id, fm := range m.Families {
+		out.Families[id] = fm
+	}
+	return out
+}
+
+func (m *Manifest) apply(changes []*pb.ManifestChange) error {
+	for _, mc := range changes {
+		switch mc.Op {
+		case pb.ManifestChange_CREATE:
+			if _, ok := m.Tables[mc.Id]; ok {
+				return fmt.Errorf("MANIFEST invalid, table %d exists", mc.Id)
+			}
+			if _, ok := m.Families[mc.ColumnFamily]; !ok && mc.ColumnFamily != 0 {
+				return fmt.Errorf("MANIFEST adds table %d to non-existing column family %d", mc.Id, mc.ColumnFamily)
+			}
+			m.Tables[mc.Id] = TableManifest{Level: uint8(mc.Level), ColumnFamily: mc.ColumnFamily}
+			m.Creations++
+		case pb.ManifestChange_DELETE:
+			if _, ok := m.Tables[mc.Id]; !ok {
+				return fmt.Errorf("MANIFEST removes non-existing table %d", mc.Id)
+			}
+			delete(m.Tables, mc.Id)
+			m.Deletions++
+		case pb.ManifestChange_CREATE_COLUMN_FAMILY:
+			if _, ok := m.Families[mc.ColumnFamily]; ok || mc.ColumnFamily == 0 {
+				return fmt.Errorf("MANIFEST invalid, column family %d exists", mc.ColumnFamily)
+			}
+			m.Families[mc.ColumnFamily] = FamilyManifest{Name: mc.ColumnFamilyName, Options: mc.ColumnFamilyOptions}
+		case pb.ManifestChange_DELETE_COLUMN_FAMILY:
+			if _, ok := m.Families[mc.ColumnFamily]; !ok {
+				return fmt.Errorf("MANIFEST removes non-existing column family %d", mc.ColumnFamily)
+			}
+			for id, tm := range m.Tables {
+				if tm.ColumnFamily == mc.ColumnFamily {
+					return fmt.Errorf("MANIFEST removes column family %d with table %d", mc.ColumnFamily, id)
+				}
+			}
+			delete(m.Families, mc.ColumnFamily)
+		}
+	}
+	return nil
+}
+
+// changes returns the creations that rebuild m, families before their tables
+func (m *Manifest) changes() []*pb.ManifestChange {
+	changes := make([]*pb.ManifestChange, 0, len(m.Families)+len(m.Tables))
+	for id, fm := range m.Families {
+		changes = append(changes, NewCreateColumnFamilyChange(id, fm.Name, fm.Options))
+	}
+	for id, tm := range m.Tables {
+		mc := NewCreateChange(id, int(tm.Level))
+		mc.ColumnFamily = tm.ColumnFamily
+		changes = append(changes, mc)
+	}
+	return changes
+}
+
+// NewCreateChange records the table id written to level of the default
+// column family, set ColumnFamily for another one
+func NewCreateChange(id uint64, level int) *pb.ManifestChange {
+	return &pb.ManifestChange{Id: id, Op: pb.ManifestChange_CREATE, Level: uint32(level)}
+}
+
+// NewDeleteChange records the removal of the table id
+func NewDeleteChange(id uint64) *pb.ManifestChange {
+	return &pb.ManifestChange{Id: id, Op: pb.ManifestChange_DELETE}
+}
+
+// NewCreateColumnFamilyChange records the column family id named name
+func NewCreateColumnFamilyChange(id uint32, name string, opt *pb.ColumnFamilyOptions) *pb.ManifestChange {
+	return &pb.ManifestChange{
+		Op:                  pb.ManifestChange_CREATE_COLUMN_FAMILY,
+		ColumnFamily:        id,
+		ColumnFamilyName:    name,
+		ColumnFamilyOptions: opt,
+	}
+}
+
+// NewDeleteColumnFamilyChange records the removal of the column family id,
+// its tables have to be deleted before
+func NewDeleteColumnFamilyChange(id uint32) *pb.ManifestChange {
+	return &pb.ManifestChange{Op: pb.ManifestChange_DELETE_COLUMN_FAMILY, ColumnFamily: id}
+}
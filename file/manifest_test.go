@@ -0,0 +1,158 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"TLKV/pb"
+	"TLKV/utils"
+)
+
+func TestManifestReplay(t *testing.T) {
+	opt := &Options{Dir: t.TempDir()}
+	mf, err := OpenManifestFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mf.AddChanges([]*pb.ManifestChange{NewCreateChange(1, 0), NewCreateChange(2, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mf.AddChanges([]*pb.ManifestChange{NewCreateChange(3, 1), NewDeleteChange(1), NewDeleteChange(2)}); err != nil {
+		t.Fatal(err)
+	}
+	// a bad change set is rejected as a whole
+	if err := mf.AddChanges([]*pb.ManifestChange{NewCreateChange(4, 1), NewDeleteChange(1)}); err == nil {
+		t.Fatal("deleted a missing table")
+	}
+	mf.Close()
+
+	// a change set cut off while it was written is dropped
+	path := filepath.Join(opt.Dir, utils.ManifestFilename)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 9, 1, 2})
+	f.Close()
+
+	mf, err = OpenManifestFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mf.Manifest()
+	if len(m.Tables) != 1 || m.Tables[3].Level != 1 {
+		t.Fatalf("replayed tables %v", m.Tables)
+	}
+	if err := mf.AddChanges([]*pb.ManifestChange{NewCreateChange(5, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	mf.Close()
+	mf, err = OpenManifestFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mf.Close()
+	if m := mf.Manifest(); len(m.Tables) != 2 || m.Tables[5].Level != 2 {
+		t.Fatalf("tables after appending past a torn tail %v", m.Tables)
+	}
+}
+
+func TestManifestRewrite(t *testing.T) {
+	opt := &Options{Dir: t.TempDir()}
+	mf, err := OpenManifestFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mf.AddChanges([]*pb.ManifestChange{NewCreateChange(1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	// every table replaces the one before it, only the last is left
+	for id := uint64(1); id <= utils.ManifestDeletionsRewriteThreshold+1; id++ {
+		if err := mf.AddChanges([]*pb.ManifestChange{NewCreateChange(id+1, 1), NewDeleteChange(id)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := mf.Manifest()
+	if m.Deletions > utils.ManifestDeletionsRewriteThreshold {
+		t.Fatalf("manifest with %d deletions was not rewritten", m.Deletions)
+	}
+	mf.Close()
+	fi, err := os.Stat(filepath.Join(opt.Dir, utils.ManifestFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > 1024 {
+		t.Fatalf("rewritten manifest has %d bytes", fi.Size())
+	}
+	mf, err = OpenManifestFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mf.Close()
+	if got := mf.Manifest(); len(got.Tables) != 1 {
+		t.Fatalf("tables after rewrite %v", got.Tables)
+	}
+}
+
+func TestManifestColumnFamilies(t *testing.T) {
+	opt := &Options{Dir: t.TempDir()}
+	mf, err := OpenManifestFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { mf.Close() }()
+	inFamily := func(mc *pb.ManifestChange, cf uint32) *pb.ManifestChange {
+		mc.ColumnFamily = cf
+		return mc
+	}
+	if err := mf.AddChanges([]*pb.ManifestChange{inFamily(NewCreateChange(1, 0), 1)}); err == nil {
+		t.Fatal("added a table to a missing family")
+	}
+	cfOpt := &pb.ColumnFamilyOptions{BlockSize: 1 << 10, BloomFalsePositive: 0.05}
+	if err := mf.AddChanges([]*pb.ManifestChange{
+		NewCreateColumnFamilyChange(1, "blobs", cfOpt),
+		inFamily(NewCreateChange(1, 0), 1),
+		NewCreateChange(2, 1),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mf.AddChanges([]*pb.ManifestChange{NewDeleteColumnFamilyChange(1)}); err == nil {
+		t.Fatal("dropped a family with tables")
+	}
+	if err := mf.AddChanges([]*pb.ManifestChange{NewCreateColumnFamilyChange(0, "default", nil)}); err == nil {
+		t.Fatal("created the default family")
+	}
+	mf.Close()
+
+	if mf, err = OpenManifestFile(opt); err != nil {
+		t.Fatal(err)
+	}
+	m := mf.Manifest()
+	fm, ok := m.Families[1]
+	if !ok || fm.Name != "blobs" || fm.Options.BlockSize != 1<<10 || fm.Options.BloomFalsePositive != 0.05 {
+		t.Fatalf("replayed families %v", m.Families)
+	}
+	if m.Tables[1].ColumnFamily != 1 || m.Tables[2].ColumnFamily != 0 {
+		t.Fatalf("replayed tables %v", m.Tables)
+	}
+	// the rewrite keeps families and their tables
+	f, err := writeManifest(opt.Dir, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	mf.Close()
+	if mf, err = OpenManifestFile(opt); err != nil {
+		t.Fatal(err)
+	}
+	if m := mf.Manifest(); len(m.Families) != 1 || m.Tables[1].ColumnFamily != 1 {
+		t.Fatalf("rewritten manifest has %v %v", m.Families, m.Tables)
+	}
+	if err := mf.AddChanges([]*pb.ManifestChange{NewDeleteChange(1), NewDeleteColumnFamilyChange(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if m := mf.Manifest(); len(m.Families) != 0 || len(m.Tables) != 1 {
+		t.Fatalf("after dropping the family: %v %v", m.Families, m.Tables)
+	}
+}
@@ -1,11 +0,0 @@
-package file
-
-import (
-	"encoding/binary"
-	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-
-	"TLKV/utils/mmap"
-)
\ No newline at end of file
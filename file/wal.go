@@ -3,18 +3,307 @@ package file
 import (
 	"bufio"
 	"bytes"
-	"fmt"
+	"encoding/binary"
 	"hash/crc32"
 	"io"
 	"os"
 	"sync"
 
 	"TLKV/utils"
+
 	"github.com/pkg/errors"
 )
 
-// WalFile _
+// WalFile is the write ahead log of one memtable, replayed into a new
+// memtable when the DB opens again before it was flushed. Value log files
+// share its format.
+// | WalFileHeader | record ... |, a record is encoded by utils.WalCodec.
+// Since version 4 the records are encrypted as one AES-CTR stream from the
+// end of the header when the header names a data key.
 type WalFile struct {
-	lock *sync.RWMutex
-	f *MmapFile
-}
\ No newline at end of file
+	lock    *sync.RWMutex
+	f       *os.File
+	opt     *Options
+	path    string
+	version uint32
+	header  int   // size of the file header, 0 for version 1 files
+	size    int64 // bytes written, the next record starts here
+	buf     *bytes.Buffer
+	// key and iv encrypt the records, key is nil if they are in clear
+	key, iv []byte
+}
+
+// OpenWalFile opens the WAL opt.FID in opt.Dir, a new one is created with
+// the header of the current WalVersion
+func OpenWalFile(opt *Options) (*WalFile, error) {
+	return openLogFile(opt, utils.FileNameWal(opt.Dir, opt.FID))
+}
+
+// OpenVlogFile opens the value log file opt.FID in opt.Dir like OpenWalFile
+func OpenVlogFile(opt *Options) (*WalFile, error) {
+	return openLogFile(opt, utils.FileNameVlog(opt.Dir, opt.FID))
+}
+
+func openLogFile(opt *Options, path string) (*WalFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while opening wal: %s", path)
+	}
+	wf := &WalFile{lock: &sync.RWMutex{}, f: f, opt: opt, path: path, buf: &bytes.Buffer{}}
+	if err := wf.init(); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "while opening wal: %s", path)
+	}
+	return wf, nil
+}
+
+// init writes the header of a new file or reads the one of an existing file
+func (wf *WalFile) init() error {
+	fi, err := wf.f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() == 0 {
+		var h utils.WalFileHeader
+		if wf.opt.Keys != nil {
+			dk, err := wf.opt.Keys.LatestDataKey()
+			if err != nil {
+				return err
+			}
+			if dk != nil {
+				if h.IV, err = utils.GenerateIV(); err != nil {
+					return err
+				}
+				h.KeyID, wf.key, wf.iv = dk.KeyId, dk.Data, h.IV
+			}
+		}
+		hdr := h.Encode()
+		if _, err := wf.f.Write(hdr); err != nil {
+			return err
+		}
+		wf.version, wf.header, wf.size = utils.WalVersion, len(hdr), int64(len(hdr))
+		return nil
+	}
+	hdr := make([]byte, utils.WalFileHeaderSize)
+	n, err := wf.f.ReadAt(hdr, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	h, hsz, err := utils.DecodeWalFileHeader(hdr[:n])
+	if err != nil {
+		return err
+	}
+	if h.KeyID != 0 {
+		if wf.opt.Keys == nil {
+			return errors.Wrapf(utils.ErrInvalidDataKeyID, "key id: %d", h.KeyID)
+		}
+		dk, err := wf.opt.Keys.DataKey(h.KeyID)
+		if err != nil {
+			return err
+		}
+		wf.key, wf.iv = dk.Data, h.IV
+	}
+	wf.version, wf.header, wf.size = h.Version, hsz, fi.Size()
+	return nil
+}
+
+// Fid returns the id of the WAL
+func (wf *WalFile) Fid() uint64 {
+	return wf.opt.FID
+}
+
+// Size returns the bytes written to the WAL
+func (wf *WalFile) Size() int64 {
+	wf.lock.RLock()
+	defer wf.lock.RUnlock()
+	return wf.size
+}
+
+// Write appends the entries with a single write, they are in the OS cache
+// until Sync
+func (wf *WalFile) Write(entries ...*utils.Entry) error {
+	_, err := wf.Append(entries...)
+	return err
+}
+
+// Append writes the entries like Write and returns where each of them is,
+// Read finds them there
+func (wf *WalFile) Append(entries ...*utils.Entry) ([]utils.ValuePtr, error) {
+	wf.lock.Lock()
+	defer wf.lock.Unlock()
+	if wf.version != utils.WalVersion {
+		// files of older versions are only replayed
+		return nil, errors.Errorf("wal %d has version %d, it is read only", wf.opt.FID, wf.version)
+	}
+	wf.buf.Reset()
+	ptrs := make([]utils.ValuePtr, len(entries))
+	var rec bytes.Buffer
+	for i, e := range entries {
+		n := utils.WalCodec(&rec, e)
+		ptrs[i] = utils.ValuePtr{Fid: uint32(wf.opt.FID), Len: uint32(n), Offset: uint32(wf.size) + uint32(wf.buf.Len())}
+		wf.buf.Write(rec.Bytes())
+	}
+	data := wf.buf.Bytes()
+	if wf.key != nil {
+		if err := utils.XORStream(data, data, wf.key, wf.iv, wf.size-int64(wf.header)); err != nil {
+			return nil, err
+		}
+	}
+	n, err := wf.f.WriteAt(data, wf.size)
+	wf.size += int64(n)
+	if err != nil {
+		return nil, err
+	}
+	return ptrs, nil
+}
+
+// Read returns the entry Append put at vp
+func (wf *WalFile) Read(vp utils.ValuePtr) (*utils.Entry, error) {
+	wf.lock.RLock()
+	size := wf.size
+	wf.lock.RUnlock()
+	off := int64(vp.Offset)
+	if off < int64(wf.header) || off+int64(vp.Len) > size {
+		return nil, errors.Wrapf(utils.ErrBadValuePointer, "%+v is outside of file %d", vp, wf.opt.FID)
+	}
+	buf := make([]byte, vp.Len)
+	if _, err := wf.f.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	if wf.key != nil {
+		if err := utils.XORStream(buf, buf, wf.key, wf.iv, off-int64(wf.header)); err != nil {
+			return nil, err
+		}
+	}
+	e, n, err := wf.read(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil || n != len(buf) {
+		return nil, errors.Wrapf(utils.ErrBadValuePointer, "%+v holds no record", vp)
+	}
+	return e, nil
+}
+
+// Sync flushes the WAL to disk
+func (wf *WalFile) Sync() error {
+	return wf.f.Sync()
+}
+
+// Iterate calls fn with every record of the WAL in order. A torn record at
+// the end is where the last write stopped, the WAL is truncated there and
+// Iterate returns nil. A bad record followed by good ones is corruption and
+// ErrWalCorrupted is returned.
+func (wf *WalFile) Iterate(fn func(e *utils.Entry) error) error {
+	wf.lock.Lock()
+	defer wf.lock.Unlock()
+	fi, err := wf.f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := wf.f.Seek(int64(wf.header), io.SeekStart); err != nil {
+		return err
+	}
+	var reader io.Reader = bufio.NewReader(wf.f)
+	if wf.key != nil {
+		var err error
+		if reader, err = utils.NewDecryptReader(reader, wf.key, wf.iv); err != nil {
+			return err
+		}
+	}
+	offset := int64(wf.header)
+	for {
+		e, n, err := wf.read(reader, fi.Size()-offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if found, err := wf.recordAfter(offset, fi.Size()); err != nil {
+				return err
+			} else if found {
+				return errors.Wrapf(utils.ErrWalCorrupted, "file %d offset %d", wf.opt.FID, offset)
+			}
+			// the rest was never fully written
+			if err := wf.f.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		offset += int64(n)
+	}
+	wf.size = offset
+	return nil
+}
+
+// recordAfter reports whether a good record starts anywhere after the bad
+// one at offset. A torn write only leaves its record at the end of the file,
+// it never has another record after it.
+func (wf *WalFile) recordAfter(offset, size int64) (bool, error) {
+	buf := make([]byte, size-offset)
+	if _, err := wf.f.ReadAt(buf, offset); err != nil {
+		return false, err
+	}
+	if wf.key != nil {
+		if err := utils.XORStream(buf, buf, wf.key, wf.iv, offset-int64(wf.header)); err != nil {
+			return false, err
+		}
+	}
+	for p := 1; p < len(buf); p++ {
+		if _, _, err := wf.read(bytes.NewReader(buf[p:]), int64(len(buf)-p)); err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// read decodes the next record of the avail bytes left, io.EOF means there
+// is none
+func (wf *WalFile) read(reader io.Reader, avail int64) (*utils.Entry, int, error) {
+	hr := utils.NewHashReader(reader)
+	var h utils.WalHeader
+	if _, err := h.Decode(hr, wf.version); err != nil {
+		if err == io.EOF && hr.BytesRead == 0 {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, utils.ErrTruncate
+	}
+	// keys are never empty, and garbage lengths must not allocate
+	if h.KeyLen == 0 || int64(h.KeyLen)+int64(h.ValueLen) > avail {
+		return nil, 0, utils.ErrTruncate
+	}
+	kv := make([]byte, int(h.KeyLen)+int(h.ValueLen))
+	if _, err := io.ReadFull(hr, kv); err != nil {
+		return nil, 0, utils.ErrTruncate
+	}
+	var crcBuf [crc32.Size]byte
+	if _, err := io.ReadFull(reader, crcBuf[:]); err != nil {
+		return nil, 0, utils.ErrTruncate
+	}
+	if binary.BigEndian.Uint32(crcBuf[:]) != hr.Sum32() {
+		return nil, 0, utils.ErrTruncate
+	}
+	e := &utils.Entry{
+		Key:          kv[:h.KeyLen],
+		Meta:         h.Meta,
+		UserMeta:     h.UserMeta,
+		ColumnFamily: h.ColumnFamily,
+		ExpiresAt:    h.ExpiresAt,
+	}
+	if h.ValueLen > 0 || wf.version >= 2 {
+		e.Value = kv[h.KeyLen:]
+	}
+	return e, hr.BytesRead + crc32.Size, nil
+}
+
+// Close closes the WAL without syncing it
+func (wf *WalFile) Close() error {
+	return wf.f.Close()
+}
+
+// Delete closes and removes the WAL once its memtable is flushed
+func (wf *WalFile) Delete() error {
+	if err := wf.f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(wf.path)
+}
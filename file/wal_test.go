@@ -0,0 +1,313 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"TLKV/pb"
+	"TLKV/utils"
+)
+
+func readWal(t *testing.T, opt *Options) []*utils.Entry {
+	wf, err := OpenWalFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wf.Close()
+	var entries []*utils.Entry
+	if err := wf.Iterate(func(e *utils.Entry) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestWalRoundTrip(t *testing.T) {
+	opt := &Options{FID: 1, Dir: t.TempDir()}
+	wf, err := OpenWalFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*utils.Entry{
+		{Key: []byte("a"), Value: []byte("1"), ExpiresAt: 42, UserMeta: 7},
+		{Key: []byte("b"), Value: []byte{}, ColumnFamily: 3},
+		{Key: []byte("c"), Meta: utils.BitDelete},
+	}
+	if err := wf.Write(want[:2]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := wf.Write(want[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := wf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readWal(t, opt)
+	if len(got) != len(want) {
+		t.Fatalf("read %d entries, want %d", len(got), len(want))
+	}
+	for i, e := range got {
+		w := want[i]
+		if !bytes.Equal(e.Key, w.Key) || !bytes.Equal(e.Value, w.Value) || e.Meta != w.Meta ||
+			e.UserMeta != w.UserMeta || e.ExpiresAt != w.ExpiresAt || e.ColumnFamily != w.ColumnFamily {
+			t.Fatalf("entry %d = %+v, want %+v", i, e, w)
+		}
+		if e.Value == nil {
+			t.Fatalf("entry %d lost its empty value", i)
+		}
+	}
+}
+
+func TestWalTruncatesTornTail(t *testing.T) {
+	opt := &Options{FID: 2, Dir: t.TempDir()}
+	wf, err := OpenWalFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := wf.Write(utils.NewEntry([]byte(fmt.Sprintf("k%d", i)), []byte("value"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full := wf.Size()
+	wf.Close()
+	path := utils.FileNameWal(opt.Dir, opt.FID)
+	if err := os.Truncate(path, full-3); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readWal(t, opt); len(got) != 9 {
+		t.Fatalf("read %d entries of a torn wal, want 9", len(got))
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() >= full-3 {
+		t.Fatalf("torn record left in place, size %d", fi.Size())
+	}
+	// later records are appended after the last good one
+	wf, err = OpenWalFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wf.Write(utils.NewEntry([]byte("k9"), []byte("again"))); err != nil {
+		t.Fatal(err)
+	}
+	wf.Close()
+	got := readWal(t, opt)
+	if len(got) != 10 || string(got[9].Value) != "again" {
+		t.Fatalf("read %d entries after appending", len(got))
+	}
+}
+
+func TestWalCorruptRecordInMiddle(t *testing.T) {
+	opt := &Options{FID: 3, Dir: t.TempDir()}
+	wf, err := OpenWalFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mid int64
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			mid = wf.Size()
+		}
+		if err := wf.Write(utils.NewEntry([]byte(fmt.Sprintf("k%d", i)), []byte("value"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full := wf.Size()
+	wf.Close()
+	path := utils.FileNameWal(opt.Dir, opt.FID)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flip a byte in the value of the sixth record
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, mid+8); err != nil {
+		t.Fatal(err)
+	}
+	b[0] ^= 0xff
+	if _, err := f.WriteAt(b, mid+8); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	wf, err = OpenWalFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wf.Close()
+	n := 0
+	err = wf.Iterate(func(e *utils.Entry) error {
+		n++
+		return nil
+	})
+	if !errors.Is(err, utils.ErrWalCorrupted) {
+		t.Fatalf("iterate a wal with a bad record in the middle: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("read %d entries before the bad record, want 5", n)
+	}
+	// the records after it are kept for whoever looks at the file
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != full {
+		t.Fatalf("corrupt wal truncated to %d, was %d", fi.Size(), full)
+	}
+}
+
+func TestWalVersion1IsReadOnly(t *testing.T) {
+	opt := &Options{FID: 3, Dir: t.TempDir()}
+	var rec []byte
+	h := utils.WalHeader{KeyLen: 1, ValueLen: 0}
+	var hdr [32]byte
+	rec = append(rec, hdr[:h.Encode(hdr[:], 1)]...)
+	rec = append(rec, 'k')
+	rec = append(rec, utils.U32ToBytes(crc(rec))...)
+	if err := os.WriteFile(utils.FileNameWal(opt.Dir, opt.FID), rec, 0600); err != nil {
+		t.Fatal(err)
+	}
+	got := readWal(t, opt)
+	if len(got) != 1 || string(got[0].Key) != "k" || got[0].Value != nil {
+		t.Fatalf("version 1 wal read as %+v", got)
+	}
+	wf, err := OpenWalFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wf.Close()
+	if err := wf.Write(utils.NewEntry([]byte("x"), []byte("y"))); err == nil {
+		t.Fatal("appended to a version 1 wal")
+	}
+}
+
+// testKeys hands out data key 1, or no key while off
+type testKeys struct{ off bool }
+
+func (k testKeys) DataKey(id uint64) (*pb.DataKey, error) {
+	if id != 1 {
+		return nil, utils.ErrInvalidDataKeyID
+	}
+	return &pb.DataKey{KeyId: 1, Data: bytes.Repeat([]byte{9}, 16)}, nil
+}
+
+func (k testKeys) LatestDataKey() (*pb.DataKey, error) {
+	if k.off {
+		return nil, nil
+	}
+	return k.DataKey(1)
+}
+
+func TestWalEncrypted(t *testing.T) {
+	opt := &Options{FID: 4, Dir: t.TempDir(), Keys: testKeys{}}
+	wf, err := OpenWalFile(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// appends continue the stream at odd offsets
+	for i := 0; i < 20; i++ {
+		if err := wf.Write(utils.NewEntry([]byte(fmt.Sprintf("key%d", i)), []byte("secret value"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full := wf.Size()
+	wf.Close()
+	path := utils.FileNameWal(opt.Dir, opt.FID)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("secret")) || bytes.Contains(raw, []byte("key1")) {
+		t.Fatal("wal holds plaintext")
+	}
+	got := readWal(t, opt)
+	if len(got) != 20 || string(got[19].Key) != "key19" || string(got[19].Value) != "secret value" {
+		t.Fatalf("read %d entries", len(got))
+	}
+
+	// a torn tail is found through the decryption too
+	if err := os.Truncate(path, full-3); err != nil {
+		t.Fatal(err)
+	}
+	if got := readWal(t, opt); len(got) != 19 {
+		t.Fatalf("read %d entries of a torn wal, want 19", len(got))
+	}
+	// the key id in the header decides, not whether encryption is on now
+	if got := readWal(t, &Options{FID: 4, Dir: opt.Dir, Keys: testKeys{off: true}}); len(got) != 19 {
+		t.Fatalf("read %d entries with encryption off", len(got))
+	}
+	if _, err := OpenWalFile(&Options{FID: 4, Dir: opt.Dir}); !errors.Is(err, utils.ErrInvalidDataKeyID) {
+		t.Fatalf("opened an encrypted wal without keys: %v", err)
+	}
+}
+
+func TestVlogAppendRead(t *testing.T) {
+	for _, keys := range []DataKeys{nil, testKeys{}} {
+		opt := &Options{FID: 5, Dir: t.TempDir(), Keys: keys}
+		vf, err := OpenVlogFile(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ptrs []utils.ValuePtr
+		for i := 0; i < 3; i++ {
+			batch := []*utils.Entry{
+				utils.NewEntry([]byte(fmt.Sprintf("a%d", i)), bytes.Repeat([]byte{byte(i)}, 100+i)),
+				utils.NewEntry([]byte(fmt.Sprintf("b%d", i)), []byte("short")),
+			}
+			p, err := vf.Append(batch...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ptrs = append(ptrs, p...)
+		}
+		vf.Close()
+		if _, err := os.Stat(utils.FileNameVlog(opt.Dir, opt.FID)); err != nil {
+			t.Fatal(err)
+		}
+		vf, err = OpenVlogFile(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// pointers read back in any order
+		for i := len(ptrs) - 1; i >= 0; i-- {
+			e, err := vf.Read(ptrs[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := fmt.Sprintf("b%d", i/2)
+			if i%2 == 0 {
+				want = fmt.Sprintf("a%d", i/2)
+			}
+			if string(e.Key) != want || ptrs[i].Fid != 5 {
+				t.Fatalf("pointer %+v read %q, want %q", ptrs[i], e.Key, want)
+			}
+		}
+		bad := ptrs[1]
+		bad.Offset++
+		if _, err := vf.Read(bad); !errors.Is(err, utils.ErrBadValuePointer) {
+			t.Fatalf("misplaced pointer: %v", err)
+		}
+		bad = ptrs[len(ptrs)-1]
+		bad.Len += 10
+		if _, err := vf.Read(bad); !errors.Is(err, utils.ErrBadValuePointer) {
+			t.Fatalf("pointer past the end: %v", err)
+		}
+		if err := vf.Delete(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func crc(b []byte) uint32 {
+	return uint32(utils.CalculateChecksum(b))
+}
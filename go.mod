@@ -4,6 +4,11 @@ go 1.22.0
 
 require (
 	github.com/gogo/protobuf v1.3.2 // indirect
-	golang.org/x/sys v0.18.0 // indirect
 	google.golang.org/protobuf v1.32.0 // indirect
 )
+
+require (
+	github.com/golang/protobuf v1.5.0
+	github.com/pkg/errors v0.9.1
+	golang.org/x/sys v0.18.0
+)
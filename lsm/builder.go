@@ -2,20 +2,16 @@ package lsm
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
-	"go/version"
 	"io"
 	"math"
-	"os"
 	"sort"
 	"unsafe"
 
-	"TLKV/file"
 	"TLKV/pb"
 	"TLKV/utils"
-
-	"golang.org/x/tools/go/analysis/passes/defers"
 )
 
 type tableBuilder struct {
@@ -29,6 +25,16 @@ type tableBuilder struct {
 	baseKey       []byte
 	staleDataSize int
 	estimateSz    int64
+	rawSize       int64 // data block bytes before compression
+	// maxExpiresAt is the latest TTL seen so far, neverExpires records
+	// whether some entry has no TTL at all
+	maxExpiresAt uint64
+	neverExpires bool
+	compression  utils.CompressionType
+	filterPolicy utils.FilterPolicy // nil builds no filter
+	lastPrefix   []byte             // prefix last added to keyHashes
+	// dataKey encrypts blocks and the index, nil writes them in clear
+	dataKey *pb.DataKey
 }
 type buildData struct {
 	blockList []*block
@@ -43,11 +49,36 @@ type block struct {
 	chklen            int
 	data              []byte
 	baseKey           []byte
-	entryOffsets      []uint32
+	entryOffsets      []uint32 // every entry, tables before version 4 only
+	restarts          []uint32 // entries holding a full key
+	numEntries        int
+	prevKey           []byte        // builder only, the last key added
+	keyRestarts       []hashRestart // builder only, first restart of each user key
+	hashIndex         []byte        // bucket -> restart, nil if the block has none
+	noMeta            bool          // version 1, values have no meta bytes
+	firstHash         int           // builder only, first of the block's keys in keyHashes
 	end               int
 	estimateSz        int64
 }
 
+// hashRestart maps the hash of a user key to the restart interval where the
+// key first shows up in the block
+type hashRestart struct {
+	hash    uint32
+	restart uint8
+}
+
+// hash index buckets hold a restart index, or one of these
+const (
+	hashBucketEmpty     = 0xff
+	hashBucketCollision = 0xfe
+	// blocks with more restarts don't get a hash index
+	maxHashIndexRestarts = hashBucketCollision
+	// hasHashIndex is set in the restart count when the block has a hash index
+	hasHashIndex = uint32(1) << 31
+)
+
+// header is the entry header of tables before version 4, it is only decoded
 type header struct {
 	overlap uint16 // overlap with base key
 	diff    uint16 // Length of the diff
@@ -55,21 +86,22 @@ type header struct {
 
 const headerSize = uint16(unsafe.Sizeof(header{}))
 
+// footerSize is the size of | MagicText | MagicVersion | at the end of a table
+const footerSize = len(utils.MagicText) + 4
+
 // Decodes decodes the header
 func (h *header) decode(buf []byte) {
 	copy(((*[headerSize]byte)(unsafe.Pointer(h))[:]), buf[:headerSize])
 }
 
-func (h header) encode() []byte {
-	var b [4]byte
-	*(*header)(unsafe.Pointer(&b[0])) = h
-	return b[:]
-}
-
-// add an entry header | diffkey | valuestruct
+// add an entry | uvarint shared | uvarint unshared | uvarint value len | unshared key | valuestruct |
+// the key shares a prefix with the previous key, except at restart points
+// where it is stored whole so readers can start decoding there
 func (tb *tableBuilder) add(e *utils.Entry, isStale bool) {
 	key := e.Key
 	val := utils.ValueStruct{
+		Meta:      e.Meta,
+		UserMeta:  e.UserMeta,
 		Value:     e.Value,
 		ExpiresAt: e.ExpiresAt,
 	}
@@ -82,50 +114,100 @@ func (tb *tableBuilder) add(e *utils.Entry, isStale bool) {
 		tb.finishBlock()
 		// Create a new block and start writing
 		tb.curBlock = &block{
-			data: make([]byte, tb.opt.BlockSize),
+			data:      make([]byte, tb.opt.BlockSize),
+			firstHash: len(tb.keyHashes),
 		}
+		// every block repeats its prefixes, partition filters cover whole blocks
+		tb.lastPrefix = nil
 	}
 
-	tb.keyHashes = append(tb.keyHashes, utils.Hash(utils.ParseKey(key)))
+	keyHash := utils.Hash(utils.ParseKey(key))
+	tb.keyHashes = append(tb.keyHashes, keyHash)
+	tb.addPrefixHash(utils.ParseKey(key))
 
 	if version := utils.ParseTs(key); version > tb.maxVersion {
 		tb.maxVersion = version
 	}
+	tb.trackExpiry(e.ExpiresAt)
 
-	var diffKey []byte
-	if len(tb.curBlock.baseKey) == 0 {
-		tb.curBlock.baseKey = append(tb.curBlock.baseKey[:0], key...)
-		diffKey = key
+	bl := tb.curBlock
+	if len(bl.baseKey) == 0 {
+		bl.baseKey = append(bl.baseKey[:0], key...)
+	}
+	var shared int
+	if bl.numEntries%tb.opt.restartInterval() == 0 {
+		bl.restarts = append(bl.restarts, uint32(bl.end))
 	} else {
-		diffKey = tb.keyDiff(key)
+		shared = sharedPrefixLen(bl.prevKey, key)
 	}
-	utils.CondPanic(!(len(key)-len(diffKey) <= math.MaxUint16), fmt.Errorf("tableBuilder.add: len(key)-len(diffkey) <= math.MaxUint16"))
-	utils.CondPanic(!(len(diffKey) <= math.MaxUint16), fmt.Errorf("tableBuilder.add:len(diffkey) <= math.MaxUint16"))
-
-	h := header{
-		overlap: uint16(len(key) - len(diffKey)),
-		diff:    uint16(len(diffKey)),
+	unshared := key[shared:]
+	if tb.opt.BlockHashIndex && (bl.numEntries == 0 || !utils.SameKey(bl.prevKey, key)) {
+		bl.keyRestarts = append(bl.keyRestarts, hashRestart{
+			hash:    keyHash,
+			restart: uint8(len(bl.restarts) - 1), // only used when it fits, see finishBlock
+		})
 	}
 
-	tb.curBlock.entryOffsets = append(tb.curBlock.entryOffsets, uint32(tb.curBlock.end))
-
-	tb.append(h.encode())
-	tb.append(diffKey)
+	var h [3 * binary.MaxVarintLen32]byte
+	n := binary.PutUvarint(h[:], uint64(shared))
+	n += binary.PutUvarint(h[n:], uint64(len(unshared)))
+	n += binary.PutUvarint(h[n:], uint64(val.EncodedSize()))
+	tb.append(h[:n])
+	tb.append(unshared)
 	dst := tb.allocate(int(val.EncodedSize()))
 	val.EncodeValue(dst)
+
+	bl.prevKey = append(bl.prevKey[:0], key...)
+	bl.numEntries++
 }
 
-func newTableBuilerWithSSTSize(opt *Options, size int64) *tableBuilder {
+// addPrefixHash puts the prefix of userKey in the filter, once per run of
+// keys sharing it
+func (tb *tableBuilder) addPrefixHash(userKey []byte) {
+	pe := tb.opt.PrefixExtractor
+	if pe == nil || !pe.InDomain(userKey) {
+		return
+	}
+	prefix := pe.Transform(userKey)
+	if tb.lastPrefix != nil && bytes.Equal(prefix, tb.lastPrefix) {
+		return
+	}
+	tb.lastPrefix = append(tb.lastPrefix[:0], prefix...)
+	tb.keyHashes = append(tb.keyHashes, utils.Hash(prefix))
+}
+
+// newTableBuilerWithSSTSize is used by compaction, level is the level the table is written to.
+// dataKey comes from KeyRegistry.LatestDataKey, nil if encryption is off
+func newTableBuilerWithSSTSize(opt *Options, size int64, level int, dataKey *pb.DataKey) *tableBuilder {
 	return &tableBuilder{
-		opt:     opt,
-		sstSize: size,
+		opt:          opt,
+		sstSize:      size,
+		compression:  opt.compressionFor(level),
+		filterPolicy: opt.filterPolicyFor(level),
+		dataKey:      dataKey,
 	}
 }
 
-func newTableBuiler(opt *Options) *tableBuilder {
+// newTableBuiler is used to flush memtables to level 0
+func newTableBuiler(opt *Options, dataKey *pb.DataKey) *tableBuilder {
 	return &tableBuilder{
-		opt:     opt,
-		sstSize: opt.SSTableMaxSz,
+		opt:          opt,
+		sstSize:      opt.SSTableMaxSz,
+		compression:  opt.compressionFor(0),
+		filterPolicy: opt.filterPolicyFor(0),
+		dataKey:      dataKey,
+	}
+}
+
+// trackExpiry records when every entry of the table has expired so that
+// compaction can find expired tables without reading them
+func (tb *tableBuilder) trackExpiry(expiresAt uint64) {
+	if expiresAt == 0 {
+		tb.neverExpires = true
+		return
+	}
+	if expiresAt > tb.maxExpiresAt {
+		tb.maxExpiresAt = expiresAt
 	}
 }
 
@@ -144,15 +226,18 @@ func (tb *tableBuilder) tryFinishBlock(e *utils.Entry) bool {
 	if tb.curBlock == nil {
 		return true
 	}
-	if len(tb.curBlock.entryOffsets) <= 0 {
+	if tb.curBlock.numEntries <= 0 {
 		return false
 	}
-	// + 1, last entry offset
-	utils.CondPanic(!((uint32(len(tb.curBlock.entryOffsets))+1)*4+4+8+4 < math.MaxUint32), errors.New("Integer overflow"))
-	entriesOffsetsSize := int64((len(tb.curBlock.entryOffsets)+1)*4 +
+	// + 1, the entry may start a new restart
+	utils.CondPanic(!((uint32(len(tb.curBlock.restarts))+1)*4+4+8+4 < math.MaxUint32), errors.New("Integer overflow"))
+	entriesOffsetsSize := int64((len(tb.curBlock.restarts)+1)*4 +
 		4 + // size of list
 		8 + // sum64 in checksum proto
 		4) // checksum length
+	if tb.opt.BlockHashIndex {
+		entriesOffsetsSize += int64(hashIndexBuckets(len(tb.curBlock.keyRestarts)+1)) + 4
+	}
 
 	tb.curBlock.estimateSz = int64(tb.curBlock.end) + int64(6 /*header size for entry */) +
 		int64(len(e.Key)) + int64(e.EncodedSize()) + entriesOffsetsSize
@@ -183,26 +268,92 @@ func (tb *tableBuilder) Close() {
 
 
 func (tb *tableBuilder) finishBlock() {
-	if tb.curBlock == nil || len(tb.curBlock.entryOffsets) == 0 {
+	if tb.curBlock == nil || tb.curBlock.numEntries == 0 {
 		return
 	}
-	// Append the entryOffsets and its length.
-	tb.append(utils.U32SliceToBytes(tb.curBlock.entryOffsets))
-	tb.append(utils.U32ToBytes(uint32(len(tb.curBlock.entryOffsets))))
+	numRestarts := uint32(len(tb.curBlock.restarts))
+	if tb.opt.BlockHashIndex && numRestarts <= maxHashIndexRestarts {
+		buckets := tb.buildHashIndex(tb.curBlock)
+		tb.append(buckets)
+		tb.append(utils.U32ToBytes(uint32(len(buckets))))
+		numRestarts |= hasHashIndex
+	}
+	// Append the restart points and their count.
+	tb.append(utils.U32SliceToBytes(tb.curBlock.restarts))
+	tb.append(utils.U32ToBytes(numRestarts))
 
 	checksum := tb.calculateChecksum(tb.curBlock.data[:tb.curBlock.end])
 
 	// Append the block checksum and its length
 	tb.append(checksum)
 	tb.append(utils.U32ToBytes(uint32(len(checksum))))
-	tb.estimateSz += tb.curBlock.estimateSz
+
+	// Compression runs after the checksum is calculated, so the checksum covers
+	// the uncompressed bytes and is verified after decompression
+	tb.rawSize += int64(tb.curBlock.end)
+	tb.compressBlock(tb.curBlock)
+	tb.encryptBlock(tb.curBlock)
+	tb.estimateSz += int64(tb.curBlock.end)
 	tb.blockList = append(tb.blockList, tb.curBlock)
 	// TODO: Estimate the size of the SST file after organizing the builder's writes to disk.
-	tb.keyCount += uint32(len(tb.curBlock.entryOffsets))
+	tb.keyCount += uint32(tb.curBlock.numEntries)
 	tb.curBlock = nil // indicates that current block has been serialized to memory
 	return
 }
 
+// hashIndexBuckets sizes the hash index for a 0.75 load factor
+func hashIndexBuckets(numKeys int) int {
+	return numKeys*4/3 + 1
+}
+
+// buildHashIndex maps every user key of the block to the restart interval
+// it starts in: | bucket ... | len(buckets) |. Point lookups jump straight
+// to that interval instead of binary searching the restarts.
+func (tb *tableBuilder) buildHashIndex(bl *block) []byte {
+	buckets := make([]byte, hashIndexBuckets(len(bl.keyRestarts)))
+	for i := range buckets {
+		buckets[i] = hashBucketEmpty
+	}
+	for _, kr := range bl.keyRestarts {
+		i := kr.hash % uint32(len(buckets))
+		switch buckets[i] {
+		case hashBucketEmpty:
+			buckets[i] = kr.restart
+		case kr.restart:
+		default:
+			buckets[i] = hashBucketCollision
+		}
+	}
+	return buckets
+}
+
+// compressBlock compresses the finished block and appends the codec id:
+// | compressed(entries | restarts | len | checksum | checksum len) | type |
+func (tb *tableBuilder) compressBlock(bl *block) {
+	ct := tb.compression
+	data, err := utils.CompressBlock(ct, bl.data[:bl.end])
+	utils.Panic(err)
+	if ct != utils.NoCompression && len(data) >= bl.end {
+		// incompressible, keep the raw bytes
+		ct, data = utils.NoCompression, bl.data[:bl.end]
+	}
+	bl.data = append(data, byte(ct))
+	bl.end = len(bl.data)
+}
+
+// encryptBlock seals the compressed block with the data key and appends the IV:
+// | encrypted(compressed block | type) | iv |
+func (tb *tableBuilder) encryptBlock(bl *block) {
+	if tb.dataKey == nil {
+		return
+	}
+	iv, err := utils.GenerateIV()
+	utils.Panic(err)
+	utils.Panic(utils.XORBlock(bl.data[:bl.end], bl.data[:bl.end], tb.dataKey.Data, iv))
+	bl.data = append(bl.data[:bl.end], iv...)
+	bl.end = len(bl.data)
+}
+
 // append appends to curBlock.data
 func (tb *tableBuilder) append(data []byte) {
 	dst := tb.allocate(len(data))
@@ -231,14 +382,14 @@ func (tb *tableBuilder) calculateChecksum(data []byte) []byte {
 	return utils.U64ToBytes(checkSum)
 }
 
-func (tb *tableBuilder) keyDiff(newKey []byte) []byte {
+func sharedPrefixLen(a, b []byte) int {
 	var i int
-	for i = 0; i < len(newKey) && i < len(tb.curBlock.baseKey); i++ {
-		if newKey[i] != tb.curBlock.baseKey[i] {
+	for i = 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
 			break
 		}
 	}
-	return newKey[i:]
+	return i
 }
 
 func (bd *buildData) Copy(dst []byte) int {
@@ -251,6 +402,10 @@ func (bd *buildData) Copy(dst []byte) int {
 
 	written += copy(dst[written:], bd.checksum)
 	written += copy(dst[written:], utils.U32ToBytes(uint32(len(bd.checksum))))
+
+	// the footer tells readers which encoding the table was written with
+	written += copy(dst[written:], utils.MagicText[:])
+	written += copy(dst[written:], utils.U32ToBytes(utils.MagicVersion))
 	return written
 }
 
@@ -259,21 +414,21 @@ func (tb *tableBuilder) done() buildData {
 	if len(tb.blockList) == 0 {
 		return buildData{}
 	}
-	bd := buildData{
-		blockList: tb.blockList,
-	}
+	bd := buildData{}
 
 	var f utils.Filter
-	if tb.opt.BloomFalsePositive > 0 {
-		bits := utils.BloomBitsPerKey(len(tb.keyHashes), tb.opt.BloomFalsePositive)
-		f = utils.NewFilter(tb.keyHashes, bits)
+	// partitioned tables get one filter per index partition instead
+	if tb.filterPolicy != nil && tb.opt.IndexPartitionSize <= 0 {
+		f = tb.filterPolicy.NewFilter(tb.keyHashes)
 	}
 	// TODO build sst index
 	index, dataSize := tb.buildIndex(f)
+	// after buildIndex, which appends the partitions of a partitioned index
+	bd.blockList = tb.blockList
 	checksum := tb.calculateChecksum(index)
 	bd.index = index
 	bd.checksum = checksum
-	bd.size = int(dataSize) + len(index) + len(checksum) + 4 + 4
+	bd.size = int(dataSize) + len(index) + len(checksum) + 4 + 4 + footerSize
 	return bd
 }
 
@@ -284,18 +439,114 @@ func (tb *tableBuilder) buildIndex(bloom []byte) ([]byte, uint32) {
 		tableIndex.BloomFilter = bloom
 	}
 	tableIndex.KeyCount = tb.keyCount
+	tableIndex.StaleDataSize = tb.staleOnDisk()
+	tableIndex.BiggestKey = tb.blockList[len(tb.blockList)-1].prevKey
+	if tb.opt.PrefixExtractor != nil && tb.filterPolicy != nil {
+		tableIndex.PrefixExtractor = tb.opt.PrefixExtractor.Name()
+	}
 	tableIndex.MaxVersion = tb.maxVersion
+	if !tb.neverExpires {
+		tableIndex.MaxExpiresAt = tb.maxExpiresAt
+	}
 	tableIndex.Offsets = tb.writeBlockOffsets(tableIndex)
 	var dataSize uint32
 	for i := range tb.blockList {
 		dataSize += uint32(tb.blockList[i].end)
 	}
+	if tb.opt.IndexPartitionSize > 0 {
+		dataSize += tb.partitionIndex(tableIndex, dataSize)
+	}
 	data, err := tableIndex.Marshal()
 	utils.Panic(err)
+	if tb.dataKey != nil {
+		data = tb.sealIndex(data)
+	}
 	return data, dataSize
 	
 }
 
+// staleOnDisk scales the stale bytes, counted before prefix sharing and
+// compression, to the share of the data blocks they take
+func (tb *tableBuilder) staleOnDisk() uint32 {
+	if tb.rawSize == 0 {
+		return 0
+	}
+	var onDisk int64
+	for _, bl := range tb.blockList {
+		onDisk += int64(bl.end)
+	}
+	stale := int64(tb.staleDataSize) * onDisk / tb.rawSize
+	if stale > onDisk {
+		stale = onDisk
+	}
+	return uint32(stale)
+}
+
+// partitionIndex moves the block offsets and the bloom filter into index and
+// filter partitions stored after the data blocks, so opening a table only
+// decodes one entry per partition. Partitions are read lazily through the
+// block cache. Returns the bytes the partitions take.
+func (tb *tableBuilder) partitionIndex(ti *pb.TableIndex, offset uint32) uint32 {
+	start := offset
+	offsets := ti.Offsets
+	numData := len(tb.blockList)
+	ti.Offsets = nil
+	ti.BlockCount = uint32(len(offsets))
+	for first := 0; first < len(offsets); {
+		last, sz := first, 0
+		for last < len(offsets) && (last == first || sz < tb.opt.IndexPartitionSize) {
+			sz += offsets[last].Size()
+			last++
+		}
+		part := &pb.IndexPartition{Offsets: offsets[first:last]}
+		data, err := part.Marshal()
+		utils.Panic(err)
+		key := offsets[first].GetKey()
+		ti.PartitionFirstBlocks = append(ti.PartitionFirstBlocks, uint32(first))
+		ti.IndexPartitions = append(ti.IndexPartitions, tb.addPartition(data, key, &offset))
+
+		if tb.filterPolicy != nil {
+			end := len(tb.keyHashes)
+			if last < numData {
+				end = tb.blockList[last].firstHash
+			}
+			hashes := tb.keyHashes[tb.blockList[first].firstHash:end]
+			f := tb.filterPolicy.NewFilter(hashes)
+			ti.FilterPartitions = append(ti.FilterPartitions, tb.addPartition(f, key, &offset))
+		}
+		first = last
+	}
+	return offset - start
+}
+
+// addPartition appends | data | checksum | as a block, encrypted like data
+// blocks but not compressed
+func (tb *tableBuilder) addPartition(data, key []byte, offset *uint32) *pb.BlockOffset {
+	bl := &block{data: append(data, tb.calculateChecksum(data)...)}
+	bl.end = len(bl.data)
+	tb.encryptBlock(bl)
+	bo := &pb.BlockOffset{Key: key, Offset: *offset, Len: uint32(bl.end)}
+	*offset += uint32(bl.end)
+	tb.blockList = append(tb.blockList, bl)
+	return bo
+}
+
+// sealIndex encrypts the marshalled index, only the key id stays readable
+// so the reader knows which data key opens it: | encrypted(index) | iv |
+func (tb *tableBuilder) sealIndex(data []byte) []byte {
+	iv, err := utils.GenerateIV()
+	utils.Panic(err)
+	sealed, err := utils.XORBlockAllocate(data, tb.dataKey.Data, iv)
+	utils.Panic(err)
+	outer := &pb.TableIndex{
+		KeyId:          tb.dataKey.KeyId,
+		EncryptedIndex: append(sealed, iv...),
+	}
+	data, err = outer.Marshal()
+	utils.Panic(err)
+	return data
+}
+
 func (tb *tableBuilder) writeBlockOffsets(tableIndex *pb.TableIndex) []*pb.BlockOffset {
 	var startOffset uint32
 	var offsets []*pb.BlockOffset
@@ -332,6 +583,9 @@ type blockIterator struct {
 	key []byte
 	val []byte
 	entryOffsets []uint32
+	restarts []uint32
+	nextOff int // start of the entry after the current one, version 4 blocks
+	noMeta bool
 	block *block
 
 	tableID uint64
@@ -353,14 +607,119 @@ func (itr *blockIterator) setBlock(b *block) {
 	//Drop the index from the block. We don't need it anymore
 	itr.data = b.data[:b.entriesIndexStart]
 	itr.entryOffsets = b.entryOffsets
+	itr.restarts = b.restarts
+	itr.noMeta = b.noMeta
 }
 
 // seekToFirst brings us to the first element
 func (itr *blockIterator) seekToFirst() {
+	if itr.restarts != nil {
+		itr.seekRestart(0)
+		return
+	}
 	itr.setIdx(0)
 	
 }
 
+// seek brings us to the first entry whose key is >= key
+func (itr *blockIterator) seek(key []byte) {
+	if itr.restarts == nil {
+		i := sort.Search(len(itr.entryOffsets), func(i int) bool {
+			itr.setIdx(i)
+			return utils.CompareKeys(itr.key, key) >= 0
+		})
+		itr.setIdx(i)
+		return
+	}
+	// binary search the restart keys for the last one <= key, then walk
+	i := sort.Search(len(itr.restarts), func(i int) bool {
+		itr.seekRestart(i)
+		return itr.err == nil && utils.CompareKeys(itr.key, key) > 0
+	})
+	if i > 0 {
+		i--
+	}
+	itr.seekRestart(i)
+	for itr.err == nil && utils.CompareKeys(itr.key, key) < 0 {
+		itr.next()
+	}
+}
+
+// seekPoint is seek for a point lookup, it uses the block's hash index when
+// there is one. It returns false if the hash index proves the user key is not
+// in the block, the iterator is then left invalid
+func (itr *blockIterator) seekPoint(key []byte) bool {
+	buckets := itr.block.hashIndex
+	if len(buckets) == 0 {
+		itr.seek(key)
+		return true
+	}
+	switch r := buckets[utils.Hash(utils.ParseKey(key))%uint32(len(buckets))]; r {
+	case hashBucketEmpty:
+		itr.err = io.EOF
+		return false
+	case hashBucketCollision:
+		itr.seek(key)
+	default:
+		itr.seekRestart(int(r))
+		for itr.err == nil && utils.CompareKeys(itr.key, key) < 0 {
+			itr.next()
+		}
+	}
+	return true
+}
+
+// seekRestart decodes the entry at the i-th restart point
+func (itr *blockIterator) seekRestart(i int) {
+	if i < 0 || i >= len(itr.restarts) {
+		itr.err = io.EOF
+		return
+	}
+	itr.idx = i
+	itr.key = itr.key[:0]
+	itr.nextOff = int(itr.restarts[i])
+	itr.next()
+}
+
+// next decodes the entry at nextOff of a version 4 block
+// | uvarint shared | uvarint unshared | uvarint value len | unshared key | valuestruct |
+func (itr *blockIterator) next() {
+	if itr.nextOff >= len(itr.data) {
+		itr.err = io.EOF
+		return
+	}
+	entryData := itr.data[itr.nextOff:]
+	corrupt := func() {
+		itr.err = fmt.Errorf("%w: table: %d block: %d offset: %d", utils.ErrBlockCorrupted, itr.tableID, itr.blockID, itr.nextOff)
+	}
+	shared, n1 := binary.Uvarint(entryData)
+	if n1 <= 0 {
+		corrupt()
+		return
+	}
+	unshared, n2 := binary.Uvarint(entryData[n1:])
+	if n2 <= 0 {
+		corrupt()
+		return
+	}
+	vlen, n3 := binary.Uvarint(entryData[n1+n2:])
+	if n3 <= 0 {
+		corrupt()
+		return
+	}
+	n := n1 + n2 + n3
+	rest := uint64(len(entryData) - n)
+	if shared > uint64(len(itr.key)) || unshared > rest || vlen > rest-unshared || vlen < 3 {
+		corrupt()
+		return
+	}
+	itr.err = nil
+	keyEnd := n + int(unshared)
+	itr.key = append(itr.key[:shared], entryData[n:keyEnd]...)
+	itr.nextOff += keyEnd + int(vlen)
+	itr.setItem(entryData[keyEnd : keyEnd+int(vlen)])
+}
+
 func (itr *blockIterator) setIdx(i int) {
 	itr.idx = i
 	if i >= len(itr.entryOffsets) || i < 0 {
@@ -409,12 +768,27 @@ func (itr *blockIterator) setIdx(i int) {
 	valueOff := headerSize + h.diff
 	diffKey := entryData[headerSize:valueOff]
 	itr.key = append(itr.key[:h.overlap], diffKey...)
+	itr.setItem(entryData[valueOff:])
+}
+
+func (itr *blockIterator) setItem(value []byte) {
 	e := &utils.Entry{Key: itr.key}
 	val := &utils.ValueStruct{}
-	val.DecodeValue(entryData[valueOff:])
+	var err error
+	if itr.noMeta {
+		err = val.DecodeLegacyValue(value)
+	} else {
+		err = val.DecodeValue(value)
+	}
+	if err != nil {
+		itr.err = fmt.Errorf("%w: table: %d block: %d key: %q: %v", utils.ErrBlockCorrupted, itr.tableID, itr.blockID, itr.key, err)
+		return
+	}
 	itr.val = val.Value
 	e.Value = val.Value
 	e.ExpiresAt = val.ExpiresAt
+	e.Meta = val.Meta
+	e.UserMeta = val.UserMeta
 	itr.it = &Item{e: e}
 }
 
@@ -423,14 +797,18 @@ func (itr *blockIterator) Error() error {
 }
 
 func (itr *blockIterator) Next() {
+	if itr.restarts != nil {
+		itr.next()
+		return
+	}
 	itr.setIdx(itr.idx + 1)
 }
 
 func (itr *blockIterator) Valid() bool {
-	return itr.err != io.EOF // TODO 这里用err比较好
+	return itr.err == nil
 }
 func (itr *blockIterator) Rewind() bool {
-	itr.setIdx(0)
+	itr.seekToFirst()
 	return true
 }
 func (itr *blockIterator) Item() utils.Item {
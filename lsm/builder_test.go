@@ -0,0 +1,31 @@
+package lsm
+
+import (
+	"errors"
+	"testing"
+
+	"TLKV/utils"
+)
+
+func TestBlockIteratorCorruptVarint(t *testing.T) {
+	// An overlong varint makes binary.Uvarint report overflow (n < 0).
+	overflow := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	cases := map[string][]byte{
+		"shared":   overflow,
+		"unshared": append([]byte{0}, overflow...),
+		"vlen":     append([]byte{0, 1, 'k'}, overflow...),
+		"short":    {0, 0x7f, 'k'},
+		"value":    {0, 9, 1, 'k', 'e', 'y', 'k', 'e', 'y', 'x', 'y', 'z'},
+		"expires":  {0, 1, 3, 'k', 0, 0, 0xff},
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			itr := &blockIterator{}
+			itr.setBlock(&block{data: data, entriesIndexStart: len(data), restarts: []uint32{0}})
+			itr.next()
+			if !errors.Is(itr.err, utils.ErrBlockCorrupted) {
+				t.Fatalf("err = %v, want ErrBlockCorrupted", itr.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package lsm
+
+import (
+	"math"
+
+	coreCache "TLKV/utils/cache"
+)
+
+// cache keeps decoded blocks and table indexes in memory so reads don't
+// decrypt and decompress the same block over and over
+type cache struct {
+	blocks *coreCache.Cache // keyed by blockCacheKey
+	indexs *coreCache.Cache // keyed by fid, nil pins indexes in their tables
+}
+
+func newCache(opt *Options) *cache {
+	c := &cache{}
+	if opt.BlockCacheSize > 0 {
+		c.blocks = coreCache.NewCache(opt.BlockCacheSize)
+	}
+	if opt.IndexCacheSize > 0 {
+		c.indexs = coreCache.NewCache(opt.IndexCacheSize)
+	}
+	return c
+}
+
+// blockCacheKey packs the table id and block offset, tables stay below 4GB
+func blockCacheKey(fid uint64, offset uint32) uint64 {
+	return fid<<32 | uint64(offset)
+}
+
+// purge drops the index and blocks of table fid once it is deleted, nothing
+// can ask for them again
+func (c *cache) purge(fid uint64) {
+	if c == nil {
+		return
+	}
+	if c.indexs != nil {
+		c.indexs.Del(fid)
+	}
+	if c.blocks != nil {
+		c.blocks.DelRange(blockCacheKey(fid, 0), blockCacheKey(fid, math.MaxUint32))
+	}
+}
+
+// CacheMetrics are the hit and miss counters of the block and index caches
+type CacheMetrics struct {
+	Block coreCache.Metrics
+	Index coreCache.Metrics
+}
+
+// metrics returns the counters of both caches
+func (c *cache) metrics() CacheMetrics {
+	return CacheMetrics{Block: c.blockMetrics(), Index: c.indexMetrics()}
+}
+
+// blockMetrics returns the hits and misses of the block cache
+func (c *cache) blockMetrics() coreCache.Metrics {
+	if c == nil || c.blocks == nil {
+		return coreCache.Metrics{}
+	}
+	return c.blocks.Metrics()
+}
+
+// indexMetrics returns the hits and misses of the index cache
+func (c *cache) indexMetrics() coreCache.Metrics {
+	if c == nil || c.indexs == nil {
+		return coreCache.Metrics{}
+	}
+	return c.indexs.Metrics()
+}
@@ -0,0 +1,113 @@
+package lsm
+
+import (
+	"testing"
+
+	"TLKV/utils"
+)
+
+func readAllBlocks(t *testing.T, tbl *table) int {
+	index, err := tbl.getIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for i := 0; i < numBlocks(index); i++ {
+		b, err := tbl.block(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		it := &blockIterator{}
+		it.setBlock(b)
+		for it.seekToFirst(); it.Valid(); it.Next() {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBlockCacheMetrics(t *testing.T) {
+	opt := testOptions(t)
+	opt.BlockCacheSize = 1 << 20
+	opt.IndexCacheSize = 1 << 20
+	opt.BlockCompression = []utils.CompressionType{utils.LZCompression}
+	keys := buildTestTable(t, opt, 1, 0, 500)
+	tc := newTableCache(opt, nil, newCache(opt))
+	tbl, err := tc.acquire(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := 0; r < 3; r++ {
+		if n := readAllBlocks(t, tbl); n != len(keys) {
+			t.Fatalf("read %d entries, want %d", n, len(keys))
+		}
+	}
+	tc.release(tbl)
+	m := tc.cache.metrics()
+	if m.Block.Hits == 0 || m.Block.Ratio() < 0.5 {
+		t.Fatalf("block metrics %+v", m.Block)
+	}
+}
+
+// blockKeys returns the block cache keys of every data block of tbl
+func blockKeys(t *testing.T, tbl *table) []uint64 {
+	index, err := tbl.getIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []uint64
+	for i := 0; i < numBlocks(index); i++ {
+		ko, err := tbl.blockOffset(index, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, blockCacheKey(tbl.fid, ko.GetOffset()))
+	}
+	return keys
+}
+
+func TestTableRemovePurgesCache(t *testing.T) {
+	opt := testOptions(t)
+	opt.BlockCacheSize = 1 << 20
+	opt.IndexCacheSize = 1 << 20
+	buildTestTable(t, opt, 1, 0, 500)
+	buildTestTable(t, opt, 2, 0, 500)
+	tc := newTableCache(opt, nil, newCache(opt))
+	keys := map[uint64][]uint64{}
+	tables := map[uint64]*table{}
+	for fid := uint64(1); fid <= 2; fid++ {
+		tbl, err := tc.acquire(fid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readAllBlocks(t, tbl)
+		keys[fid], tables[fid] = blockKeys(t, tbl), tbl
+	}
+	cached := func(fid uint64) (n int) {
+		for _, k := range keys[fid] {
+			if _, ok := tc.cache.blocks.Get(k); ok {
+				n++
+			}
+		}
+		if _, ok := tc.cache.indexs.Get(fid); ok {
+			n++
+		}
+		return n
+	}
+	if cached(1) == 0 || cached(2) == 0 {
+		t.Fatal("blocks were not cached")
+	}
+
+	// table 1 is idle when removed, table 2 is still being read
+	tc.release(tables[1])
+	tc.remove(1)
+	tc.remove(2)
+	if n := cached(1); n != 0 {
+		t.Fatalf("%d entries of removed table 1 still cached", n)
+	}
+	readAllBlocks(t, tables[2])
+	tc.release(tables[2])
+	if n := cached(2); n != 0 {
+		t.Fatalf("%d entries of removed table 2 still cached", n)
+	}
+}
@@ -0,0 +1,372 @@
+package lsm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"TLKV/file"
+	"TLKV/pb"
+	"TLKV/utils"
+)
+
+// DefaultColumnFamily is the family entries belong to unless told otherwise,
+// it always has id 0
+const DefaultColumnFamily = "default"
+
+// ColumnFamily is a logically separate keyspace with its own options,
+// memtables and levels. All families share one WAL and one manifest, so a
+// batch spanning several families is still applied atomically.
+type ColumnFamily struct {
+	ID   uint32
+	Name string
+	Opt  *Options
+
+	db *DB
+	lm *levelManager
+	// dropped is set under DB.writeLock and DB.lock once DropColumnFamily
+	// started, the family takes no reads or writes from then on
+	dropped bool
+}
+
+// columnFamilies maps family names and ids to their definitions
+type columnFamilies struct {
+	lock   sync.RWMutex
+	byName map[string]*ColumnFamily
+	byID   map[uint32]*ColumnFamily
+	nextID uint32
+}
+
+func newColumnFamilies() *columnFamilies {
+	return &columnFamilies{
+		byName: make(map[string]*ColumnFamily),
+		byID:   make(map[uint32]*ColumnFamily),
+	}
+}
+
+func (cfs *columnFamilies) add(cf *ColumnFamily) {
+	cfs.lock.Lock()
+	defer cfs.lock.Unlock()
+	cfs.byName[cf.Name] = cf
+	cfs.byID[cf.ID] = cf
+	// ids of dropped families are not handed out again while the DB is open
+	if cf.ID >= cfs.nextID {
+		cfs.nextID = cf.ID + 1
+	}
+}
+
+func (cfs *columnFamilies) remove(cf *ColumnFamily) {
+	cfs.lock.Lock()
+	defer cfs.lock.Unlock()
+	delete(cfs.byName, cf.Name)
+	delete(cfs.byID, cf.ID)
+}
+
+func (cfs *columnFamilies) get(name string) (*ColumnFamily, error) {
+	cfs.lock.RLock()
+	defer cfs.lock.RUnlock()
+	cf, ok := cfs.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("column family %s: %w", name, utils.ErrColumnFamilyNotFound)
+	}
+	return cf, nil
+}
+
+func (cfs *columnFamilies) getByID(id uint32) (*ColumnFamily, error) {
+	cfs.lock.RLock()
+	defer cfs.lock.RUnlock()
+	cf, ok := cfs.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("column family %d: %w", id, utils.ErrColumnFamilyNotFound)
+	}
+	return cf, nil
+}
+
+// all returns the families in the order of their ids
+func (cfs *columnFamilies) all() []*ColumnFamily {
+	cfs.lock.RLock()
+	defer cfs.lock.RUnlock()
+	out := make([]*ColumnFamily, 0, len(cfs.byID))
+	for _, cf := range cfs.byID {
+		out = append(out, cf)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (cfs *columnFamilies) next() uint32 {
+	cfs.lock.RLock()
+	defer cfs.lock.RUnlock()
+	return cfs.nextID
+}
+
+// familyOptions returns the options of a family created with opt. The
+// layout of its memtables, tables and levels and its merge operator and
+// filters come from opt, what the families share from the DB options.
+func familyOptions(dbOpt, opt *Options) *Options {
+	out := opt.withDefaults()
+	out.WorkDir = dbOpt.WorkDir
+	out.BlockCacheSize = dbOpt.BlockCacheSize
+	out.IndexCacheSize = dbOpt.IndexCacheSize
+	out.MaxOpenTables = dbOpt.MaxOpenTables
+	out.NumMemtables = dbOpt.NumMemtables
+	out.SyncWrites = dbOpt.SyncWrites
+	out.NumCompactors = dbOpt.NumCompactors
+	out.NumLevelZeroTablesStall = dbOpt.NumLevelZeroTablesStall
+	out.Clock = dbOpt.Clock
+	out.EncryptionKey = dbOpt.EncryptionKey
+	out.EncryptionKeyRotationDuration = dbOpt.EncryptionKeyRotationDuration
+	return out
+}
+
+// familyOptionsPB returns the options of a family the manifest keeps.
+// Functions like MergeOperator can't be stored, a family opened again gets
+// those of the DB options.
+func familyOptionsPB(opt *Options) *pb.ColumnFamilyOptions {
+	p := &pb.ColumnFamilyOptions{
+		MemTableSize:               opt.MemTableSize,
+		SsTableMaxSz:               opt.SSTableMaxSz,
+		BlockSize:                  uint32(opt.BlockSize),
+		BloomFalsePositive:         opt.BloomFalsePositive,
+		BaseLevelSize:              opt.BaseLevelSize,
+		LevelSizeMultiplier:        uint32(opt.LevelSizeMultiplier),
+		TableSizeMultiplier:        uint32(opt.TableSizeMultiplier),
+		BaseTableSize:              opt.BaseTableSize,
+		NumLevelZeroTables:         uint32(opt.NumLevelZeroTables),
+		MaxLevelNum:                uint32(opt.MaxLevelNum),
+		BlockRestartInterval:       uint32(opt.BlockRestartInterval),
+		BlockHashIndex:             opt.BlockHashIndex,
+		IndexPartitionSize:         uint32(opt.IndexPartitionSize),
+		MemTableBloomBitsPerKey:    uint32(opt.MemTableBloomBitsPerKey),
+		StaleDataCompactionRatio:   opt.StaleDataCompactionRatio,
+		SeekCompactionBytesPerSeek: opt.SeekCompactionBytesPerSeek,
+	}
+	for _, c := range opt.BlockCompression {
+		p.BlockCompression = append(p.BlockCompression, uint32(c))
+	}
+	return p
+}
+
+// restoreFamilyOptions returns the options of a family read back from the
+// manifest
+func restoreFamilyOptions(dbOpt *Options, p *pb.ColumnFamilyOptions) *Options {
+	opt := *dbOpt
+	if p == nil {
+		return &opt
+	}
+	opt.MemTableSize = p.MemTableSize
+	opt.SSTableMaxSz = p.SsTableMaxSz
+	opt.BlockSize = int(p.BlockSize)
+	opt.BloomFalsePositive = p.BloomFalsePositive
+	opt.BaseLevelSize = p.BaseLevelSize
+	opt.LevelSizeMultiplier = int(p.LevelSizeMultiplier)
+	opt.TableSizeMultiplier = int(p.TableSizeMultiplier)
+	opt.BaseTableSize = p.BaseTableSize
+	opt.NumLevelZeroTables = int(p.NumLevelZeroTables)
+	opt.MaxLevelNum = int(p.MaxLevelNum)
+	opt.BlockCompression = nil
+	for _, c := range p.BlockCompression {
+		opt.BlockCompression = append(opt.BlockCompression, utils.CompressionType(c))
+	}
+	opt.BlockRestartInterval = int(p.BlockRestartInterval)
+	opt.BlockHashIndex = p.BlockHashIndex
+	opt.IndexPartitionSize = int(p.IndexPartitionSize)
+	opt.MemTableBloomBitsPerKey = int(p.MemTableBloomBitsPerKey)
+	opt.StaleDataCompactionRatio = p.StaleDataCompactionRatio
+	opt.SeekCompactionBytesPerSeek = p.SeekCompactionBytesPerSeek
+	return opt.withDefaults()
+}
+
+// openColumnFamilies opens the default family and those of the manifest m
+func (db *DB) openColumnFamilies(m *file.Manifest) error {
+	db.cfs = newColumnFamilies()
+	if _, err := db.openColumnFamily(0, DefaultColumnFamily, db.opt, m); err != nil {
+		return err
+	}
+	for id, fm := range m.Families {
+		if _, err := db.openColumnFamily(id, fm.Name, restoreFamilyOptions(db.opt, fm.Options), m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openColumnFamily places the tables of family id in the manifest m into
+// its levels and registers it
+func (db *DB) openColumnFamily(id uint32, name string, opt *Options, m *file.Manifest) (*ColumnFamily, error) {
+	tc := newTableCache(opt, db.kr, db.cache)
+	tc.vlog = db.vlog
+	lm, err := newLevelManager(opt, tc, m, id)
+	if err != nil {
+		return nil, err
+	}
+	cf := &ColumnFamily{ID: id, Name: name, Opt: opt, db: db, lm: lm}
+	db.cfs.add(cf)
+	return cf, nil
+}
+
+// CreateColumnFamily creates the family name with the options opt. Sizes
+// left at 0 take their defaults; the caches, the clock, encryption and the
+// write stalls are those of the DB.
+func (db *DB) CreateColumnFamily(name string, opt *Options) (*ColumnFamily, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.closed {
+		return nil, utils.ErrDBClosed
+	}
+	if _, err := db.cfs.get(name); err == nil {
+		return nil, fmt.Errorf("column family %s: %w", name, utils.ErrColumnFamilyExists)
+	}
+	opt = familyOptions(db.opt, opt)
+	id := db.cfs.next()
+	mc := file.NewCreateColumnFamilyChange(id, name, familyOptionsPB(opt))
+	if err := db.manifest.AddChanges([]*pb.ManifestChange{mc}); err != nil {
+		return nil, err
+	}
+	return db.openColumnFamily(id, name, opt, &file.Manifest{})
+}
+
+// ColumnFamily returns the family name
+func (db *DB) ColumnFamily(name string) (*ColumnFamily, error) {
+	return db.cfs.get(name)
+}
+
+// DropColumnFamily deletes the family name and all of its data. The
+// default family can't be dropped.
+func (db *DB) DropColumnFamily(name string) error {
+	cf, err := db.cfs.get(name)
+	if err != nil {
+		return err
+	}
+	if cf.ID == 0 {
+		return utils.ErrDropDefaultColumnFamily
+	}
+	// flush the memtables with entries of the family while it takes no new
+	// ones, so no WAL is left that replays them
+	db.writeLock.Lock()
+	db.lock.Lock()
+	if cf.dropped {
+		db.lock.Unlock()
+		db.writeLock.Unlock()
+		return fmt.Errorf("column family %s: %w", name, utils.ErrColumnFamilyNotFound)
+	}
+	cf.dropped = true
+	err = db.rotateForFlush()
+	db.writeLock.Unlock()
+	if err == nil {
+		err = db.waitForFlush(db.flushed + uint64(len(db.imm)))
+	}
+	db.lock.Unlock()
+	if err != nil {
+		db.undrop(cf)
+		return err
+	}
+
+	db.compactLock.Lock()
+	defer db.compactLock.Unlock()
+	db.lock.Lock()
+	var fids []uint64
+	var changes []*pb.ManifestChange
+	for _, level := range cf.lm.levels {
+		for _, fid := range level {
+			fids = append(fids, fid)
+			changes = append(changes, file.NewDeleteChange(fid))
+		}
+	}
+	changes = append(changes, file.NewDeleteColumnFamilyChange(cf.ID))
+	if err := db.manifest.AddChanges(changes); err != nil {
+		db.lock.Unlock()
+		db.undrop(cf)
+		return err
+	}
+	db.cfs.remove(cf)
+	db.lock.Unlock()
+	cf.lm.removeTables(fids)
+	return nil
+}
+
+// undrop lets cf take reads and writes again after DropColumnFamily failed
+func (db *DB) undrop(cf *ColumnFamily) {
+	db.writeLock.Lock()
+	db.lock.Lock()
+	cf.dropped = false
+	db.lock.Unlock()
+	db.writeLock.Unlock()
+}
+
+// Get returns the newest version of key in the family, see DB.Get
+func (cf *ColumnFamily) Get(key []byte) (*utils.Entry, error) {
+	if len(key) == 0 {
+		return nil, utils.ErrEmptyKey
+	}
+	return cf.db.read(cf, key, cf.db.readTs())
+}
+
+// MultiGet looks keys up in the family, see DB.MultiGet
+func (cf *ColumnFamily) MultiGet(keys [][]byte) ([]*utils.Entry, []error) {
+	return cf.db.multiGet(cf, keys, cf.db.readTs())
+}
+
+// Scan returns a page of the keys of the family, see DB.Scan
+func (cf *ColumnFamily) Scan(start []byte, limit int, opt *ScanOptions) (*ScanPage, error) {
+	return cf.db.scan(cf, start, limit, opt)
+}
+
+// ApproximateRange estimates the data of the family in the user key range
+// [start, end), see DB.ApproximateRange
+func (cf *ColumnFamily) ApproximateRange(start, end []byte) (*RangeStats, error) {
+	return cf.db.approximateRange(cf, start, end)
+}
+
+// SuggestSplitKeys returns keys dividing the data of the family in the user
+// key range [start, end), see DB.SuggestSplitKeys
+func (cf *ColumnFamily) SuggestSplitKeys(start, end []byte, n int) ([][]byte, error) {
+	return cf.db.suggestSplitKeys(cf, start, end, n)
+}
+
+// Set writes e to the family, see DB.Set
+func (cf *ColumnFamily) Set(e *utils.Entry) error {
+	ce := *e
+	ce.ColumnFamily = cf.ID
+	return cf.db.write([]*utils.Entry{&ce})
+}
+
+// Del deletes key from the family
+func (cf *ColumnFamily) Del(key []byte) error {
+	e := utils.NewDeleteEntry(key)
+	e.ColumnFamily = cf.ID
+	return cf.db.write([]*utils.Entry{e})
+}
+
+// NewIterator returns an iterator over the user keys of the family, see
+// DB.NewIterator
+func (cf *ColumnFamily) NewIterator(opt *utils.Options) (*Iterator, error) {
+	return cf.db.newIterator(cf, opt)
+}
+
+// CompactRange compacts the tables of the family, see DB.CompactRange
+func (cf *ColumnFamily) CompactRange(start, end []byte, targetLevel int) (*CompactionStats, error) {
+	return cf.db.compactRange(cf, start, end, targetLevel)
+}
+
+// Tables describes the tables of every level of the family
+func (cf *ColumnFamily) Tables() ([]TableInfo, error) {
+	db := cf.db
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if err := cf.readable(); err != nil {
+		return nil, err
+	}
+	return cf.lm.tc.tableInfos(cf.lm.levels)
+}
+
+// readable returns the error reads of the family fail with, DB.lock is held
+func (cf *ColumnFamily) readable() error {
+	switch {
+	case cf.db.closed:
+		return utils.ErrDBClosed
+	case cf.dropped:
+		return fmt.Errorf("column family %s: %w", cf.Name, utils.ErrColumnFamilyNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package lsm
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"TLKV/utils"
+)
+
+func cfMustGet(t *testing.T, cf *ColumnFamily, key, want string) {
+	t.Helper()
+	e, err := cf.Get([]byte(key))
+	if err != nil {
+		t.Fatalf("get %s from %s: %v", key, cf.Name, err)
+	}
+	if string(e.Value) != want {
+		t.Fatalf("get %s from %s = %q, want %q", key, cf.Name, e.Value, want)
+	}
+}
+
+func TestColumnFamilies(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	blobs, err := db.CreateColumnFamily("blobs", &Options{BlockSize: 1 << 10, BloomFalsePositive: 0.1, MemTableSize: 1 << 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateColumnFamily("blobs", &Options{}); !errors.Is(err, utils.ErrColumnFamilyExists) {
+		t.Fatalf("created blobs twice: %v", err)
+	}
+	if err := db.DropColumnFamily(DefaultColumnFamily); err != utils.ErrDropDefaultColumnFamily {
+		t.Fatalf("dropped the default family: %v", err)
+	}
+
+	// one batch over both families
+	inBlobs := utils.NewEntry([]byte("k"), []byte("blob"))
+	inBlobs.ColumnFamily = blobs.ID
+	if err := db.WriteBatch([]*utils.Entry{utils.NewEntry([]byte("k"), []byte("meta")), inBlobs}); err != nil {
+		t.Fatal(err)
+	}
+	if err := blobs.Set(utils.NewEntry([]byte("only"), []byte("blob"))); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "k", "meta")
+	mustMiss(t, db, "only")
+	cfMustGet(t, blobs, "k", "blob")
+	cfMustGet(t, blobs, "only", "blob")
+	unknown := utils.NewEntry([]byte("k"), []byte("x"))
+	unknown.ColumnFamily = 42
+	if err := db.Set(unknown); !errors.Is(err, utils.ErrColumnFamilyNotFound) {
+		t.Fatalf("wrote to a missing family: %v", err)
+	}
+
+	// each family flushes into its own levels
+	flushDB(t, db)
+	compactFamily(t, blobs, 1)
+	infos, err := blobs.Tables()
+	if err != nil || len(infos) != 1 || infos[0].Level != 1 {
+		t.Fatalf("tables of blobs %+v %v", infos, err)
+	}
+	if infos, err := db.Tables(); err != nil || len(infos) != 1 || infos[0].Level != 0 {
+		t.Fatalf("tables of default %+v %v", infos, err)
+	}
+	// these are only in the WAL
+	if err := blobs.Set(utils.NewEntry([]byte("late"), []byte("wal"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(utils.NewEntry([]byte("late"), []byte("default wal"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTestDB(t, opt)
+	if blobs, err = db.ColumnFamily("blobs"); err != nil {
+		t.Fatal(err)
+	}
+	if blobs.Opt.BlockSize != 1<<10 || blobs.Opt.BloomFalsePositive != 0.1 || blobs.Opt.MemTableSize != 1<<15 {
+		t.Fatalf("options of blobs after reopening: %+v", blobs.Opt)
+	}
+	cfMustGet(t, blobs, "k", "blob")
+	cfMustGet(t, blobs, "late", "wal")
+	mustGet(t, db, "late", "default wal")
+	itr, err := blobs.NewIterator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.Item().Entry().Key))
+	}
+	itr.Close()
+	if len(keys) != 3 || keys[0] != "k" || keys[1] != "late" || keys[2] != "only" {
+		t.Fatalf("keys of blobs %v", keys)
+	}
+
+	// dropping removes the data and the tables
+	infos, _ = blobs.Tables()
+	if err := db.DropColumnFamily("blobs"); err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range infos {
+		if _, err := os.Stat(utils.FileNameSSTable(opt.WorkDir, info.ID)); !os.IsNotExist(err) {
+			t.Fatalf("table %d of the dropped family is left: %v", info.ID, err)
+		}
+	}
+	if _, err := blobs.Get([]byte("k")); !errors.Is(err, utils.ErrColumnFamilyNotFound) {
+		t.Fatalf("read a dropped family: %v", err)
+	}
+	if err := blobs.Set(utils.NewEntry([]byte("k"), []byte("x"))); !errors.Is(err, utils.ErrColumnFamilyNotFound) {
+		t.Fatalf("wrote to a dropped family: %v", err)
+	}
+	if err := db.DropColumnFamily("blobs"); !errors.Is(err, utils.ErrColumnFamilyNotFound) {
+		t.Fatalf("dropped blobs twice: %v", err)
+	}
+	// a new family doesn't get the data of the dropped one
+	again, err := db.CreateColumnFamily("blobs", &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := again.Get([]byte("k")); err != utils.ErrKeyNotFound {
+		t.Fatalf("recreated family holds old data: %v", err)
+	}
+	db.Close()
+	db = openTestDB(t, opt)
+	if again, err = db.ColumnFamily("blobs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := again.Get([]byte("late")); err != utils.ErrKeyNotFound {
+		t.Fatalf("reopened family holds old data: %v", err)
+	}
+	mustGet(t, db, "k", "meta")
+}
+
+func TestColumnFamilyOptionsRoundTrip(t *testing.T) {
+	dbOpt, _ := testDBOptions(t)
+	opt := familyOptions(dbOpt, &Options{
+		BlockSize:                  512,
+		BlockRestartInterval:       4,
+		BlockHashIndex:             true,
+		BloomFalsePositive:         0.02,
+		BlockCompression:           []utils.CompressionType{utils.NoCompression, utils.LZCompression},
+		StaleDataCompactionRatio:   0.3,
+		SeekCompactionBytesPerSeek: -1,
+		MaxLevelNum:                4,
+	})
+	got := restoreFamilyOptions(dbOpt, familyOptionsPB(opt))
+	if got.BlockSize != 512 || got.BlockRestartInterval != 4 || !got.BlockHashIndex ||
+		got.BloomFalsePositive != 0.02 || len(got.BlockCompression) != 2 ||
+		got.BlockCompression[1] != utils.LZCompression || got.StaleDataCompactionRatio != 0.3 ||
+		got.SeekCompactionBytesPerSeek != -1 || got.MaxLevelNum != 4 ||
+		got.MemTableSize != opt.MemTableSize || got.WorkDir != dbOpt.WorkDir {
+		t.Fatalf("restored %+v, want %+v", got, opt)
+	}
+}
@@ -0,0 +1,320 @@
+package lsm
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	"TLKV/pb"
+	"TLKV/utils"
+)
+
+// CompactionStats reports the work done by a flush or a compaction
+type CompactionStats struct {
+	TablesRead    int
+	TablesWritten int
+	BytesRead     int64
+	BytesWritten  int64
+	// decisions of the CompactionFilter
+	FilterKept    int
+	FilterDropped int
+	FilterChanged int
+}
+
+// compactDef is one compaction job, its input tables are merged into new
+// tables of targetLevel
+type compactDef struct {
+	targetLevel int
+	// inputs are ordered newest first: level 0 from newest to oldest, then
+	// the tables of the following levels
+	inputs []uint64
+	// discardTs is the oldest version a reader may still ask for. Of the
+	// versions at or below it only the newest is kept
+	discardTs uint64
+	// bottommost is set when no level below targetLevel holds the keys, so
+	// tombstones can be dropped and merge operands applied
+	bottommost bool
+}
+
+// tableRange returns the user key range of the table fid, opening it only
+// if it was never opened before
+func (tc *tableCache) tableRange(fid uint64) (keyRange, error) {
+	tc.lock.Lock()
+	kr, ok := tc.ranges[fid]
+	tc.lock.Unlock()
+	if ok {
+		return kr, nil
+	}
+	t, err := tc.acquire(fid)
+	if err != nil {
+		return keyRange{}, err
+	}
+	kr = keyRange{utils.ParseKey(t.smallest), utils.ParseKey(t.biggest)}
+	tc.release(t)
+	return kr, nil
+}
+
+// overlaps reports whether kr holds user keys of [start, end), nil leaves
+// that side open
+func (kr keyRange) overlaps(start, end []byte) bool {
+	return (start == nil || bytes.Compare(kr.biggest, start) >= 0) &&
+		(end == nil || bytes.Compare(kr.smallest, end) < 0)
+}
+
+// planCompactRange picks the tables of levels, the table ids of each level
+// with level 0 newest first, that have to be merged to move the user key
+// range [start, end) of fromLevel to targetLevel. CompactRange starts from
+// level 0. The range grows to the tables picked until no other table of
+// those levels overlaps it, so level 0 keeps its order and the other levels
+// stay disjoint.
+func (tc *tableCache) planCompactRange(levels [][]uint64, start, end []byte, fromLevel, targetLevel int, discardTs uint64) (*compactDef, error) {
+	if fromLevel < 0 || targetLevel < fromLevel || targetLevel >= len(levels) {
+		return nil, utils.ErrInvalidRequest
+	}
+	picked := make(map[uint64]bool)
+	for changed := true; changed; {
+		changed = false
+		for l := fromLevel; l <= targetLevel; l++ {
+			for _, fid := range levels[l] {
+				if picked[fid] {
+					continue
+				}
+				kr, err := tc.tableRange(fid)
+				if err != nil {
+					return nil, err
+				}
+				if !kr.overlaps(start, end) {
+					continue
+				}
+				picked[fid] = true
+				changed = true
+				start, end = widen(start, end, kr)
+			}
+		}
+	}
+
+	cd := &compactDef{targetLevel: targetLevel, discardTs: discardTs, bottommost: true}
+	for l := fromLevel; l <= targetLevel; l++ {
+		for _, fid := range levels[l] {
+			if picked[fid] {
+				cd.inputs = append(cd.inputs, fid)
+			}
+		}
+	}
+	for _, fids := range levels[targetLevel+1:] {
+		for _, fid := range fids {
+			kr, err := tc.tableRange(fid)
+			if err != nil {
+				return nil, err
+			}
+			if kr.overlaps(start, end) {
+				cd.bottommost = false
+			}
+		}
+	}
+	return cd, nil
+}
+
+// widen returns [start, end) grown to cover kr, the end right after it
+func widen(start, end []byte, kr keyRange) ([]byte, []byte) {
+	if start != nil && bytes.Compare(kr.smallest, start) < 0 {
+		start = kr.smallest
+	}
+	if end != nil && bytes.Compare(kr.biggest, end) >= 0 {
+		end = append(utils.SafeCopy(nil, kr.biggest), 0)
+	}
+	return start, end
+}
+
+// runCompaction merges the inputs of cd into new tables of cd.targetLevel
+// named by newFid. The versions of a user key never span two tables. The
+// caller records the outputs and removes the inputs. If it fails, the tables
+// it wrote are deleted again.
+func (tc *tableCache) runCompaction(cd *compactDef, newFid func() uint64) ([]uint64, *CompactionStats, error) {
+	outputs, stats, err := tc.mergeInputs(cd, newFid)
+	if err != nil {
+		for _, fid := range outputs {
+			_ = os.Remove(utils.FileNameSSTable(tc.opt.WorkDir, fid))
+		}
+		return nil, stats, err
+	}
+	return outputs, stats, nil
+}
+
+// mergeInputs does the work of runCompaction, it returns the tables written
+// so far along with an error
+func (tc *tableCache) mergeInputs(cd *compactDef, newFid func() uint64) ([]uint64, *CompactionStats, error) {
+	stats := &CompactionStats{}
+	iters := make([]utils.Iterator, 0, len(cd.inputs))
+	for _, fid := range cd.inputs {
+		t, err := tc.acquire(fid)
+		if err != nil {
+			newMergeIterator(iters).Close()
+			return nil, nil, err
+		}
+		stats.TablesRead++
+		stats.BytesRead += int64(len(t.data))
+		itr := t.NewIterator(&utils.Options{IsAsc: true}).(*tableIterator)
+		itr.onClose = func() { tc.release(t) }
+		iters = append(iters, itr)
+	}
+	mi := newMergeIterator(iters)
+	defer mi.Close()
+
+	now := tc.opt.clock().Now()
+	var outputs []uint64
+	var tb *tableBuilder
+	var versions []*utils.Entry
+	for mi.Rewind(); mi.Valid(); {
+		key := utils.SafeCopy(nil, utils.ParseKey(mi.Item().Entry().Key))
+		versions = versions[:0]
+		for ; mi.Valid(); mi.Next() {
+			e := mi.Item().Entry()
+			if !bytes.Equal(utils.ParseKey(e.Key), key) {
+				break
+			}
+			versions = append(versions, copyEntry(e))
+		}
+		kept, err := tc.compactVersions(cd, versions, now, stats)
+		if err != nil {
+			return outputs, stats, err
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		if tb == nil {
+			if tb, err = tc.newCompactionBuilder(cd.targetLevel); err != nil {
+				return outputs, stats, err
+			}
+		}
+		// versions below one that isn't a merge operand are dropped once no
+		// snapshot reads them, the ones merge operands fold onto are not
+		shadowed := false
+		for _, e := range kept {
+			if shadowed {
+				tb.AddStaleKey(e)
+			} else {
+				tb.AddKey(e)
+			}
+			shadowed = shadowed || e.Meta&utils.BitMerge == 0
+		}
+		if tb.ReachedCapacity() {
+			fid, err := tc.writeTable(tb, newFid, stats)
+			if err != nil {
+				return outputs, stats, err
+			}
+			outputs = append(outputs, fid)
+			tb = nil
+		}
+	}
+	if tb != nil {
+		fid, err := tc.writeTable(tb, newFid, stats)
+		if err != nil {
+			return outputs, stats, err
+		}
+		outputs = append(outputs, fid)
+	}
+	return outputs, stats, utils.SyncDir(tc.opt.WorkDir)
+}
+
+// compactVersions returns the versions of one user key, newest first, that
+// survive cd. Versions above discardTs are all kept, of the rest only the
+// newest is, with older merge operands folded into it, and it goes through
+// the CompactionFilter. At the bottom a tombstone or expired entry is dropped.
+func (tc *tableCache) compactVersions(cd *compactDef, versions []*utils.Entry, now time.Time, stats *CompactionStats) ([]*utils.Entry, error) {
+	i := 0
+	for i < len(versions) && utils.ParseTs(versions[i].Key) > cd.discardTs {
+		i++
+	}
+	kept, rest := versions[:i], versions[i:]
+	if len(rest) == 0 {
+		return kept, nil
+	}
+	rest, err := mergeEntries(tc.opt.MergeOperator, tc.vlog, rest, cd.bottommost, now)
+	if err != nil {
+		return nil, err
+	}
+	if rest[0].Meta&utils.BitMerge == 0 {
+		rest = rest[:1]
+		if cd.bottommost && rest[0].IsDeletedOrExpiredAt(now) {
+			return kept, nil
+		}
+		if !rest[0].IsDeletedOrExpiredAt(now) {
+			if rest[0], err = filterEntry(tc.opt.CompactionFilter, tc.vlog, cd, rest[0], stats); err != nil {
+				return nil, err
+			}
+			if rest[0] == nil {
+				return kept, nil
+			}
+		}
+	}
+	return append(kept, rest...), nil
+}
+
+func (tc *tableCache) newCompactionBuilder(level int) (*tableBuilder, error) {
+	dk, err := tc.latestDataKey()
+	if err != nil {
+		return nil, err
+	}
+	return newTableBuilerWithSSTSize(tc.opt, tc.opt.SSTableMaxSz, level, dk), nil
+}
+
+// latestDataKey returns the key new tables are encrypted with, nil if none
+func (tc *tableCache) latestDataKey() (*pb.DataKey, error) {
+	if tc.kr == nil {
+		return nil, nil
+	}
+	return tc.kr.LatestDataKey()
+}
+
+// writeTable writes the table built by tb to a new file named by newFid, a
+// partly written file is deleted
+func (tc *tableCache) writeTable(tb *tableBuilder, newFid func() uint64, stats *CompactionStats) (uint64, error) {
+	fid := newFid()
+	buf := tb.finish()
+	path := utils.FileNameSSTable(tc.opt.WorkDir, fid)
+	fd, err := utils.CreateSyncedFile(path, true)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := fd.Write(buf); err != nil {
+		fd.Close()
+		os.Remove(path)
+		return 0, err
+	}
+	if err := fd.Close(); err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	stats.TablesWritten++
+	stats.BytesWritten += int64(len(buf))
+	return fid, nil
+}
+
+// flushMemTable writes the skiplist of an immutable memtable to a level 0
+// table named by newFid. An empty skiplist writes nothing and returns fid 0.
+func (tc *tableCache) flushMemTable(skl *utils.Skiplist, newFid func() uint64) (uint64, *CompactionStats, error) {
+	stats := &CompactionStats{}
+	if skl.Empty() {
+		return 0, stats, nil
+	}
+	dk, err := tc.latestDataKey()
+	if err != nil {
+		return 0, nil, err
+	}
+	tb := newTableBuiler(tc.opt, dk)
+	itr := skl.NewSkipListIterator()
+	defer itr.Close()
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		tb.AddKey(itr.Item().Entry())
+	}
+	fid, err := tc.writeTable(tb, newFid, stats)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := utils.SyncDir(tc.opt.WorkDir); err != nil {
+		os.Remove(utils.FileNameSSTable(tc.opt.WorkDir, fid))
+		return 0, nil, err
+	}
+	return fid, stats, nil
+}
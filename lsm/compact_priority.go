@@ -0,0 +1,70 @@
+package lsm
+
+import "sort"
+
+// compactionPriority ranks a level for compaction, it is due from 1 on
+type compactionPriority struct {
+	level int
+	// score is the level size over its target size, or the number of level 0
+	// tables over NumLevelZeroTables
+	score float64
+	// adjusted is score raised by the stale data of the level
+	adjusted float64
+	// stalest is the table with the highest stale ratio, compacted first
+	stalest    uint64
+	staleRatio float64
+}
+
+// minLiveShare caps how much stale data can raise a level's score
+const minLiveShare = 0.1
+
+// compactionPriorities scores levels, targets holding the target size of
+// each level past 0. The score is divided by the live share of the level, so
+// a level half made of stale versions is compacted as if it were twice as
+// full. A level with a table past StaleDataCompactionRatio is due anyway.
+// The result is sorted by adjusted score, highest first.
+func (tc *tableCache) compactionPriorities(levels [][]uint64, targets []int64) ([]compactionPriority, error) {
+	prios := make([]compactionPriority, len(levels))
+	sizes := make([]int64, len(levels))
+	stale := make([]int64, len(levels))
+	for l, fids := range levels {
+		p := &prios[l]
+		p.level = l
+		for _, fid := range fids {
+			ts, err := tc.tableSize(fid)
+			if err != nil {
+				return nil, err
+			}
+			sizes[l] += ts.size
+			stale[l] += ts.stale
+			if ts.size == 0 {
+				continue
+			}
+			if r := float64(ts.stale) / float64(ts.size); r > p.staleRatio {
+				p.stalest, p.staleRatio = fid, r
+			}
+		}
+	}
+	for l := range prios {
+		p := &prios[l]
+		switch {
+		case l == 0 && tc.opt.NumLevelZeroTables > 0:
+			p.score = float64(len(levels[0])) / float64(tc.opt.NumLevelZeroTables)
+		case l > 0 && l < len(targets) && targets[l] > 0:
+			p.score = float64(sizes[l]) / float64(targets[l])
+		}
+		live := 1.0
+		if sizes[l] > 0 {
+			live = 1 - float64(stale[l])/float64(sizes[l])
+		}
+		if live < minLiveShare {
+			live = minLiveShare
+		}
+		p.adjusted = p.score / live
+		if r := tc.opt.StaleDataCompactionRatio; r > 0 && p.staleRatio >= r && p.adjusted < 1 {
+			p.adjusted = 1
+		}
+	}
+	sort.SliceStable(prios, func(i, j int) bool { return prios[i].adjusted > prios[j].adjusted })
+	return prios, nil
+}
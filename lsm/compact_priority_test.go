@@ -0,0 +1,92 @@
+package lsm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"TLKV/utils"
+)
+
+func TestStaleDataSize(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	opt.MergeOperator = appendOperator{}
+	opt.ScanRetention = 10 * time.Minute
+	db := openTestDB(t, opt)
+	// every version is within ScanRetention, so compaction keeps them all
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("m%03d", i))
+		if err := db.Set(utils.NewEntry(key, []byte("base"))); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 3; j++ {
+			if err := db.Merge(key, []byte(fmt.Sprintf("op%d", j))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	compactDB(t, db, 1)
+	infos, err := db.def.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the versions below merge operands are folded, not dropped
+	for _, ti := range infos {
+		if ti.StaleDataSize != 0 {
+			t.Fatalf("merge operands counted stale: %+v", ti)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		for j := 0; j < 3; j++ {
+			if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("s%03d", i)), []byte(fmt.Sprintf("v%d", j)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := db.CompactRange([]byte("s"), nil, 2); err != nil {
+		t.Fatal(err)
+	}
+	infos, err = db.def.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stalest TableInfo
+	for _, ti := range infos {
+		if ti.Level == 1 && ti.StaleDataSize != 0 {
+			t.Fatalf("merge operands counted stale: %+v", ti)
+		}
+		if ti.Level == 2 && ti.StaleRatio > stalest.StaleRatio {
+			stalest = ti
+		}
+	}
+	// two of three versions of each s key are overwritten
+	if stalest.StaleRatio < 0.5 || stalest.StaleRatio > 1 {
+		t.Fatalf("stalest table: %+v", stalest)
+	}
+
+	// scoring reads the cached sizes, a removed table is forgotten
+	tc := db.def.lm.tc
+	prios, err := tc.compactionPriorities(db.def.lm.levels, db.def.lm.targets())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range prios {
+		if p.level == 2 && (p.stalest != stalest.ID || p.staleRatio != stalest.StaleRatio) {
+			t.Fatalf("level 2: %+v, want table %d", p, stalest.ID)
+		}
+	}
+	tc.lock.Lock()
+	ts, ok := tc.sizes[stalest.ID]
+	tc.lock.Unlock()
+	if !ok || ts.size != stalest.Size || ts.stale != stalest.StaleDataSize {
+		t.Fatalf("cached %+v, %v", ts, ok)
+	}
+	tc.remove(stalest.ID)
+	tc.lock.Lock()
+	_, ok = tc.sizes[stalest.ID]
+	tc.lock.Unlock()
+	if ok {
+		t.Fatal("removed table still cached")
+	}
+}
@@ -0,0 +1,79 @@
+package lsm
+
+import "TLKV/utils"
+
+// CompactionFilter drops or rewrites entries by application logic while
+// compaction rewrites them, so they can be purged without writing deletes.
+//
+// Filter sees one entry per user key and compaction: the newest version at
+// or below the discard version, once it is live and its merge operands are
+// folded into it. It is not called for
+//   - versions above the discard version, open scans and iterators may
+//     still read them and must not see them change under them
+//   - older versions, compaction drops them anyway
+//   - deletes and expired entries, there is nothing left to purge
+//   - merge operands that can't be folded yet for lack of a base, the filter
+//     would judge a partial value
+//
+// An entry is filtered again by every compaction that rewrites it, so
+// decisions must not depend on how often Filter was called.
+type CompactionFilter interface {
+	// Name identifies the filter in logs
+	Name() string
+	// Filter is called with the user key and the entry, its value read from
+	// the value log if it is stored there. For ChangeValue the new value is
+	// returned too, it is written into the table.
+	Filter(ctx CompactionFilterContext, key []byte, e *utils.Entry) (CompactionDecision, []byte)
+}
+
+// CompactionFilterContext tells the filter where the entry is written
+type CompactionFilterContext struct {
+	Level      int
+	Bottommost bool
+}
+
+// CompactionDecision is what a CompactionFilter does with an entry
+type CompactionDecision int
+
+const (
+	// CompactionKeep writes the entry unchanged
+	CompactionKeep CompactionDecision = iota
+	// CompactionDrop removes the entry. Above the bottommost level a
+	// tombstone takes its place, so older versions further down stay hidden
+	CompactionDrop
+	// CompactionChangeValue writes the entry with the returned value
+	CompactionChangeValue
+)
+
+// filterEntry applies f to e, the newest version of its key at or below the
+// discard version, and counts the decision in stats. The filter sees the
+// value, a value in the value log is read from vlog for it. It returns nil
+// when the entry is dropped.
+func filterEntry(f CompactionFilter, vlog *valueLog, cd *compactDef, e *utils.Entry, stats *CompactionStats) (*utils.Entry, error) {
+	if f == nil || e.Meta&(utils.BitDelete|utils.BitMerge) != 0 {
+		return e, nil
+	}
+	in, err := vlog.resolve(e)
+	if err != nil {
+		return nil, err
+	}
+	ctx := CompactionFilterContext{Level: cd.targetLevel, Bottommost: cd.bottommost}
+	decision, value := f.Filter(ctx, utils.ParseKey(e.Key), in)
+	switch decision {
+	case CompactionDrop:
+		stats.FilterDropped++
+		if cd.bottommost {
+			return nil, nil
+		}
+		return &utils.Entry{Key: e.Key, Meta: utils.BitDelete}, nil
+	case CompactionChangeValue:
+		stats.FilterChanged++
+		// a new value is written into the table
+		out := *in
+		out.Value = value
+		return &out, nil
+	default:
+		stats.FilterKept++
+		return e, nil
+	}
+}
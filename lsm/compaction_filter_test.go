@@ -0,0 +1,99 @@
+package lsm
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"TLKV/utils"
+)
+
+// recordingFilter keeps every entry and records what it was shown
+type recordingFilter struct {
+	lock  sync.Mutex
+	seen  map[string]string
+	ctxs  []CompactionFilterContext
+	calls int
+}
+
+func (f *recordingFilter) Name() string { return "recording" }
+
+func (f *recordingFilter) Filter(ctx CompactionFilterContext, key []byte, e *utils.Entry) (CompactionDecision, []byte) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.seen[string(key)] = string(e.Value)
+	f.ctxs = append(f.ctxs, ctx)
+	f.calls++
+	return CompactionKeep, nil
+}
+
+func (f *recordingFilter) reset() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.seen, f.ctxs, f.calls = make(map[string]string), nil, 0
+}
+
+func TestCompactionFilterContract(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	opt.MergeOperator = appendOperator{}
+	opt.ScanRetention = 10 * time.Minute
+	f := &recordingFilter{}
+	f.reset()
+	opt.CompactionFilter = f
+	db := openTestDB(t, opt)
+	set := func(e *utils.Entry) {
+		t.Helper()
+		if err := db.Set(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a base for e two levels down
+	set(utils.NewEntry([]byte("e"), []byte("e0")))
+	clock.Advance(time.Hour)
+	compactDB(t, db, 2)
+	if f.seen["e"] != "e0" || len(f.ctxs) != 1 || f.ctxs[0] != (CompactionFilterContext{Level: 2, Bottommost: true}) {
+		t.Fatalf("base: %v %+v", f.seen, f.ctxs)
+	}
+	f.reset()
+
+	set(utils.NewEntry([]byte("a"), []byte("a1")))
+	set(utils.NewEntry([]byte("c"), []byte("c1")))
+	if err := db.Del([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	set(utils.NewEntry([]byte("d"), []byte("d1")).WithTTLOnWrite(time.Minute))
+	if err := db.Merge([]byte("e"), []byte("op")); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	// within ScanRetention, open scans may still read a1
+	set(utils.NewEntry([]byte("a"), []byte("a2")))
+	set(utils.NewEntry([]byte("f"), []byte("f1")))
+	stats, err := db.CompactRange(nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a2 and f1 are above the discard version, c is deleted, d expired and
+	// the operand of e has its base below level 1
+	if f.calls != 1 || f.seen["a"] != "a1" || f.ctxs[0] != (CompactionFilterContext{Level: 1}) {
+		t.Fatalf("filter saw %v %+v", f.seen, f.ctxs)
+	}
+	if stats.FilterKept != 1 || stats.FilterDropped != 0 || stats.FilterChanged != 0 {
+		t.Fatalf("stats: %+v", stats)
+	}
+	mustGet(t, db, "a", "a2")
+	mustGet(t, db, "e", "e0,op")
+	mustGet(t, db, "f", "f1")
+	mustMiss(t, db, "c")
+	mustMiss(t, db, "d")
+
+	// once folded at the bottom, e is shown with its merged value
+	f.reset()
+	clock.Advance(time.Hour)
+	compactDB(t, db, 2)
+	if f.seen["e"] != "e0,op" || f.seen["a"] != "a2" || f.seen["f"] != "f1" || f.calls != 3 {
+		t.Fatalf("bottom: %v", f.seen)
+	}
+}
@@ -0,0 +1,863 @@
+package lsm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"TLKV/file"
+	"TLKV/pb"
+	"TLKV/utils"
+
+	"github.com/pkg/errors"
+)
+
+// compactionInterval is how often the compactor looks for due levels when
+// no flush woke it up
+const compactionInterval = 100 * time.Millisecond
+
+// DB is a key value store on an LSM tree. Writes go to a WAL and a memtable,
+// full memtables are flushed to level 0 in the background and the levels are
+// compacted down as they fill up. Every write gets a new version and reads
+// see the newest version written before they started. Keys live in column
+// families, each with its own memtables and levels; DB methods without a
+// family use the default one.
+type DB struct {
+	opt      *Options
+	kr       *KeyRegistry
+	vlog     *valueLog
+	cache    *cache
+	manifest *file.ManifestFile
+	cfs      *columnFamilies
+	def      *ColumnFamily // the default family
+	// scanSecret seals the continuation tokens of Scan
+	scanSecret []byte
+	// watermark tells the version written ScanRetention ago, nil without
+	// ScanRetention
+	watermark *versionWatermark
+
+	// lock guards the memtables and the levels of every family. Readers hold
+	// it while they use them, flushes and compactions swap them under the
+	// write lock.
+	lock sync.RWMutex
+	mt   *memTable
+	imm  []*memTable // full memtables, oldest first
+	// stall is signalled whenever a flush or compaction finishes
+	stall   *sync.Cond
+	flushed uint64 // memtables flushed so far
+	closed  bool
+	bgErr   error // a failed flush, writes fail from then on
+
+	// writeLock serializes writes, so versions are handed out in order
+	writeLock sync.Mutex
+	version   uint64 // newest visible version, atomic
+	nextFid   uint64 // atomic
+
+	// compactLock runs one compaction at a time
+	compactLock sync.Mutex
+	flushC      chan struct{}
+	compactC    chan struct{}
+	closer      chan struct{}
+	wg          sync.WaitGroup
+
+	// nextTTLCompaction is when compactOnce looks for expired tables next,
+	// guarded by compactLock
+	nextTTLCompaction time.Time
+}
+
+// Open opens the DB in opt.WorkDir, creating it if needed. The levels come
+// from the manifest, memtables that weren't flushed are replayed from their
+// WALs and tables no level refers to, left by a crash, are deleted.
+func Open(opt *Options) (*DB, error) {
+	opt = opt.withDefaults()
+	if err := os.MkdirAll(opt.WorkDir, 0700); err != nil {
+		return nil, err
+	}
+	db := &DB{
+		opt:      opt,
+		flushC:   make(chan struct{}, 1),
+		compactC: make(chan struct{}, 1),
+		closer:   make(chan struct{}),
+	}
+	db.stall = sync.NewCond(&db.lock)
+	if err := db.open(); err != nil {
+		db.closeFiles()
+		return nil, err
+	}
+	db.wg.Add(2)
+	go db.flushLoop()
+	go db.compactLoop()
+	if len(db.imm) > 0 {
+		db.signal(db.flushC)
+	}
+	return db, nil
+}
+
+func (db *DB) open() error {
+	var err error
+	if db.kr, err = OpenKeyRegistry(db.opt); err != nil {
+		return err
+	}
+	if db.vlog, err = openValueLog(db.opt, db.kr); err != nil {
+		return err
+	}
+	if db.scanSecret, err = openScanSecret(db.opt.WorkDir); err != nil {
+		return err
+	}
+	if db.manifest, err = file.OpenManifestFile(&file.Options{Dir: db.opt.WorkDir}); err != nil {
+		return err
+	}
+	m := db.manifest.Manifest()
+	db.cache = newCache(db.opt)
+	if err := db.openColumnFamilies(m); err != nil {
+		return err
+	}
+	db.def, _ = db.cfs.getByID(0)
+
+	var maxFid uint64
+	for fid := range m.Tables {
+		if _, err := os.Stat(utils.FileNameSSTable(db.opt.WorkDir, fid)); err != nil {
+			return errors.Wrapf(err, "table %d of the manifest", fid)
+		}
+		maxFid = max(maxFid, fid)
+	}
+	for fid := range utils.LoadIDMap(db.opt.WorkDir) {
+		if _, ok := m.Tables[fid]; !ok {
+			if err := os.Remove(utils.FileNameSSTable(db.opt.WorkDir, fid)); err != nil {
+				return err
+			}
+		}
+	}
+	var version uint64
+	for _, cf := range db.cfs.all() {
+		v, err := cf.lm.maxVersion()
+		if err != nil {
+			return err
+		}
+		version = max(version, v)
+	}
+
+	imm, walVersion, walFid, err := replayMemTables(db.opt, db.kr, db.cfs)
+	if err != nil {
+		return err
+	}
+	db.imm = imm
+	db.version = max(version, walVersion)
+	if r := db.opt.ScanRetention; r > 0 {
+		// every version so far was written by now
+		db.watermark = newVersionWatermark(r)
+		db.watermark.record(db.opt.clock().Now(), db.version)
+	}
+	db.nextFid = max(maxFid, walFid)
+	db.mt, err = newMemTable(db.opt, db.kr, db.newFid())
+	return err
+}
+
+func (db *DB) newFid() uint64 {
+	return atomic.AddUint64(&db.nextFid, 1)
+}
+
+// signal wakes the loop reading c without blocking
+func (db *DB) signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Close waits for the running flush and compaction, then closes every file.
+// Memtables not flushed yet are replayed from their WAL by the next Open.
+// Iterators have to be closed before.
+func (db *DB) Close() error {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+	db.lock.Lock()
+	if db.closed {
+		db.lock.Unlock()
+		return nil
+	}
+	db.closed = true
+	db.stall.Broadcast()
+	db.lock.Unlock()
+
+	close(db.closer)
+	db.wg.Wait()
+	var err error
+	if db.mt != nil {
+		err = db.mt.wal.Sync()
+	}
+	if serr := db.vlog.sync(); err == nil {
+		err = serr
+	}
+	if cerr := db.closeFiles(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// closeFiles closes whatever open got to open
+func (db *DB) closeFiles() error {
+	var err error
+	keep := func(cerr error) {
+		if cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for _, mt := range append(db.imm, db.mt) {
+		if mt != nil {
+			keep(mt.close())
+		}
+	}
+	if db.cfs != nil {
+		for _, cf := range db.cfs.all() {
+			keep(cf.lm.tc.Close())
+		}
+	}
+	if db.manifest != nil {
+		keep(db.manifest.Close())
+	}
+	if db.vlog != nil {
+		keep(db.vlog.close())
+	}
+	if db.kr != nil {
+		keep(db.kr.Close())
+	}
+	return err
+}
+
+// Set writes e at a new version to the family e.ColumnFamily. A nil value
+// without meta is a delete, as it always was.
+func (db *DB) Set(e *utils.Entry) error {
+	return db.write([]*utils.Entry{e})
+}
+
+// WriteBatch applies entries atomically, they may belong to different
+// families
+func (db *DB) WriteBatch(entries []*utils.Entry) error {
+	return db.write(entries)
+}
+
+// Del deletes key
+func (db *DB) Del(key []byte) error {
+	return db.write([]*utils.Entry{utils.NewDeleteEntry(key)})
+}
+
+// Merge adds operand to key without reading it. Operands are folded by
+// Options.MergeOperator when the key is read or compacted.
+func (db *DB) Merge(key, operand []byte) error {
+	if db.opt.MergeOperator == nil {
+		return utils.ErrNoMergeOperator
+	}
+	return db.write([]*utils.Entry{utils.NewMergeEntry(key, operand)})
+}
+
+// write applies entries atomically: they share one version, which becomes
+// visible once all of them are in the memtable
+func (db *DB) write(entries []*utils.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := db.waitForL0(); err != nil {
+		return err
+	}
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+	return db.apply(entries)
+}
+
+// CompareAndSet sets key to value if it holds expected, nil for absent.
+// Otherwise it returns a ConflictError with the value key holds.
+func (db *DB) CompareAndSet(key, expected, value []byte) error {
+	return db.update(key, func(cur []byte, found bool) (*utils.Entry, error) {
+		if found != (expected != nil) || !bytes.Equal(cur, expected) {
+			return nil, &utils.ConflictError{Key: utils.SafeCopy(nil, key), Current: cur}
+		}
+		return utils.NewEntry(key, value), nil
+	})
+}
+
+// SetIfAbsent sets key to value if it is absent, deleted or expired.
+// Otherwise it returns a ConflictError with the value key holds.
+func (db *DB) SetIfAbsent(key, value []byte) error {
+	return db.CompareAndSet(key, nil, value)
+}
+
+// Increment adds delta to the varint at key, an absent key counts as 0, and
+// returns the new value. ErrNotInteger is returned if key holds anything
+// else.
+func (db *DB) Increment(key []byte, delta int64) (int64, error) {
+	var n int64
+	err := db.update(key, func(cur []byte, found bool) (*utils.Entry, error) {
+		val, sum, err := utils.IncrementVarint(cur, delta)
+		if err != nil {
+			return nil, err
+		}
+		n = sum
+		return utils.NewEntry(key, val), nil
+	})
+	return n, err
+}
+
+// update writes the entry fn makes of the current value of key. It holds
+// writeLock from the read to the write, so no other write can come between.
+func (db *DB) update(key []byte, fn func(cur []byte, found bool) (*utils.Entry, error)) error {
+	if len(key) == 0 {
+		return utils.ErrEmptyKey
+	}
+	if err := db.waitForL0(); err != nil {
+		return err
+	}
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+	var cur []byte
+	e, err := db.read(db.def, key, db.readTs())
+	switch err {
+	case nil:
+		cur = e.Value
+	case utils.ErrKeyNotFound:
+	default:
+		return err
+	}
+	ne, err := fn(cur, err == nil)
+	if err != nil {
+		return err
+	}
+	return db.apply([]*utils.Entry{ne})
+}
+
+// apply writes entries at the next version, writeLock is held. Big values
+// go to the value log first, the WAL and memtable get pointers to them.
+func (db *DB) apply(entries []*utils.Entry) error {
+	clock := db.opt.clock()
+	now := clock.Now()
+	version := max(atomic.LoadUint64(&db.version)+1, utils.NewVersion(clock))
+	batch := make([]*utils.Entry, len(entries))
+	for i, e := range entries {
+		if len(e.Key) == 0 {
+			return utils.ErrEmptyKey
+		}
+		// dropped is only set while writeLock is held
+		if cf, err := db.cfs.getByID(e.ColumnFamily); err != nil {
+			return err
+		} else if cf.dropped {
+			return fmt.Errorf("column family %s: %w", cf.Name, utils.ErrColumnFamilyNotFound)
+		}
+		ie := &utils.Entry{
+			Key:          utils.KeyWithTs(e.Key, version),
+			Value:        e.Value,
+			ExpiresAt:    e.ExpiresAt,
+			Meta:         e.Meta,
+			UserMeta:     e.UserMeta,
+			ColumnFamily: e.ColumnFamily,
+			TTL:          e.TTL,
+		}
+		ie.ResolveTTL(now)
+		if ie.Value == nil && ie.Meta&(utils.BitValuePointer|utils.BitMerge) == 0 {
+			ie.Meta |= utils.BitDelete
+		}
+		batch[i] = ie
+	}
+	// sized as stored, with pointers in place of the values moved to the
+	// value log, which is only written once the batch can go in
+	sizes := batchSizes(batch, db.vlog.arenaSize)
+	if err := db.makeRoom(sizes); err != nil {
+		return err
+	}
+	// Close holds writeLock too, the value log is still open
+	if err := db.vlog.write(batch); err != nil {
+		return err
+	}
+	// only writers change db.mt and they hold writeLock
+	mt := db.mt
+	if err := mt.wal.Write(batch...); err != nil {
+		return err
+	}
+	if db.opt.SyncWrites {
+		if err := mt.wal.Sync(); err != nil {
+			return err
+		}
+	}
+	mt.add(batch, sizes)
+	atomic.StoreUint64(&db.version, version)
+	if db.watermark != nil {
+		db.watermark.record(clock.Now(), version)
+	}
+	return nil
+}
+
+// waitForL0 stalls a write while level 0 has NumLevelZeroTablesStall
+// tables. It waits without writeLock, so Flush and CompactRange can still
+// run.
+func (db *DB) waitForL0() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	for {
+		switch {
+		case db.closed:
+			return utils.ErrDBClosed
+		case db.bgErr != nil:
+			return db.bgErr
+		case !db.l0Stalled():
+			return nil
+		}
+		db.signal(db.compactC)
+		db.stall.Wait()
+	}
+}
+
+// makeRoom makes sure a batch of arena sizes, by family, fits into the
+// memtable, rotating it when it is full. Writes stall while NumMemtables
+// memtables wait for their flush.
+func (db *DB) makeRoom(sizes map[uint32]int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	for {
+		switch {
+		case db.closed:
+			return utils.ErrDBClosed
+		case db.bgErr != nil:
+			return db.bgErr
+		case db.mt.fits(db.cfs, sizes):
+			return nil
+		case db.mt.empty():
+			return utils.ErrTxnTooBig
+		case len(db.imm) >= db.opt.NumMemtables:
+			db.stall.Wait()
+		default:
+			if err := db.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// l0Stalled reports whether level 0 of some family has too many tables to
+// take writes
+func (db *DB) l0Stalled() bool {
+	n := db.opt.NumLevelZeroTablesStall
+	if n <= 0 {
+		return false
+	}
+	for _, cf := range db.cfs.all() {
+		if len(cf.lm.levels[0]) >= n {
+			return true
+		}
+	}
+	return false
+}
+
+// rotate queues the memtable for its flush and starts a new one, db.lock is
+// held
+func (db *DB) rotate() error {
+	mt, err := newMemTable(db.opt, db.kr, db.newFid())
+	if err != nil {
+		return err
+	}
+	db.imm = append(db.imm, db.mt)
+	db.mt = mt
+	db.signal(db.flushC)
+	return nil
+}
+
+// memTables returns the memtables newest first, db.lock is held
+func (db *DB) memTables() []*memTable {
+	mts := make([]*memTable, 0, len(db.imm)+1)
+	mts = append(mts, db.mt)
+	for i := len(db.imm) - 1; i >= 0; i-- {
+		mts = append(mts, db.imm[i])
+	}
+	return mts
+}
+
+// Get returns the newest version of key, ErrKeyNotFound if it has none or
+// it is deleted or expired
+func (db *DB) Get(key []byte) (*utils.Entry, error) {
+	if len(key) == 0 {
+		return nil, utils.ErrEmptyKey
+	}
+	return db.read(db.def, key, db.readTs())
+}
+
+// readTs is the version reads starting now see
+func (db *DB) readTs() uint64 {
+	return atomic.LoadUint64(&db.version)
+}
+
+// read returns the live value of key of the family cf at readTs
+func (db *DB) read(cf *ColumnFamily, key []byte, readTs uint64) (*utils.Entry, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if err := cf.readable(); err != nil {
+		return nil, err
+	}
+	e, err := db.get(cf, utils.KeyWithTs(key, readTs))
+	if err != nil {
+		return nil, err
+	}
+	return db.liveValue(e, key)
+}
+
+// liveValue returns e, the newest version of key, with the value it points
+// to in the value log, ErrKeyNotFound if it is deleted or expired
+func (db *DB) liveValue(e *utils.Entry, key []byte) (*utils.Entry, error) {
+	if e.IsDeletedOrExpiredAt(db.opt.clock().Now()) {
+		return nil, utils.ErrKeyNotFound
+	}
+	e, err := db.vlog.resolve(e)
+	if err != nil {
+		return nil, err
+	}
+	e.Key = utils.SafeCopy(nil, key)
+	return e, nil
+}
+
+// get returns the newest version of the internal key's user key at or below
+// its version, deleted or not. Merge operands are folded with the versions
+// below them. db.lock is held.
+func (db *DB) get(cf *ColumnFamily, ikey []byte) (*utils.Entry, error) {
+	versions, ikey, done := db.memTableVersions(cf, ikey)
+	if done {
+		return cf.fold(versions)
+	}
+	e, err := cf.lm.tc.get(cf.lm.levels, ikey)
+	switch {
+	case err == nil:
+		versions = append(versions, e)
+	case err != utils.ErrKeyNotFound:
+		return nil, err
+	case len(versions) == 0:
+		return nil, utils.ErrKeyNotFound
+	}
+	return cf.fold(versions)
+}
+
+// memTableVersions returns the versions of the internal key's user key at
+// or below its version in the memtables, newest first, up to the first one
+// that is no merge operand. done is false if the tables may hold older
+// versions still, next is the key to look them up with. db.lock is held.
+func (db *DB) memTableVersions(cf *ColumnFamily, ikey []byte) (versions []*utils.Entry, next []byte, done bool) {
+	key := utils.ParseKey(ikey)
+	for _, mt := range db.memTables() {
+		sl := mt.skiplist(cf.ID)
+		if sl == nil {
+			continue
+		}
+		// a memtable may hold several operands of key
+		for e := sl.SearchEntry(ikey); e != nil; e = sl.SearchEntry(ikey) {
+			versions = append(versions, copyEntry(e))
+			ts := utils.ParseTs(e.Key)
+			if e.Meta&utils.BitMerge == 0 || ts == 0 {
+				return versions, nil, true
+			}
+			ikey = utils.KeyWithTs(key, ts-1)
+		}
+	}
+	return versions, ikey, false
+}
+
+// fold applies the merge operands among versions, newest first, and
+// returns the value they add up to
+func (cf *ColumnFamily) fold(versions []*utils.Entry) (*utils.Entry, error) {
+	merged, err := mergeEntries(cf.Opt.MergeOperator, cf.db.vlog, versions, true, cf.Opt.clock().Now())
+	if err != nil {
+		return nil, err
+	}
+	return merged[0], nil
+}
+
+// CacheMetrics returns the hits and misses of the block and index caches
+func (db *DB) CacheMetrics() CacheMetrics {
+	return db.cache.metrics()
+}
+
+// Tables describes the tables of every level of the default family
+func (db *DB) Tables() ([]TableInfo, error) {
+	return db.def.Tables()
+}
+
+// flushLoop flushes the immutable memtables, oldest first, whenever rotate
+// queues one
+func (db *DB) flushLoop() {
+	defer db.wg.Done()
+	for {
+		select {
+		case <-db.closer:
+			return
+		case <-db.flushC:
+		}
+		for {
+			db.lock.RLock()
+			if len(db.imm) == 0 || db.closed || db.bgErr != nil {
+				db.lock.RUnlock()
+				break
+			}
+			mt := db.imm[0]
+			db.lock.RUnlock()
+			if err := db.flushMemTable(mt); err != nil {
+				db.lock.Lock()
+				db.bgErr = errors.Wrap(err, "while flushing memtable")
+				db.stall.Broadcast()
+				db.lock.Unlock()
+				break
+			}
+		}
+	}
+}
+
+// flushMemTable writes the skiplists of the oldest immutable memtable mt to
+// level 0 of their families, then deletes its WAL
+func (db *DB) flushMemTable(mt *memTable) error {
+	outputs := make(map[*ColumnFamily]uint64)
+	removeOutputs := func() {
+		for _, fid := range outputs {
+			os.Remove(utils.FileNameSSTable(db.opt.WorkDir, fid))
+		}
+	}
+	for _, cf := range db.cfs.all() {
+		sl := mt.skiplist(cf.ID)
+		if sl == nil {
+			continue
+		}
+		fid, _, err := cf.lm.tc.flushMemTable(sl, db.newFid)
+		if err != nil {
+			removeOutputs()
+			return err
+		}
+		if fid != 0 {
+			outputs[cf] = fid
+		}
+	}
+	cd := &compactDef{targetLevel: 0}
+	db.lock.Lock()
+	var changes []*pb.ManifestChange
+	for cf, fid := range outputs {
+		changes = append(changes, cf.lm.compactionChanges(cd, []uint64{fid})...)
+	}
+	if len(changes) > 0 {
+		if err := db.manifest.AddChanges(changes); err != nil {
+			db.lock.Unlock()
+			removeOutputs()
+			return err
+		}
+		for cf, fid := range outputs {
+			cf.lm.levels = applyCompaction(cf.lm.levels, cd, []uint64{fid})
+		}
+	}
+	db.imm = db.imm[1:]
+	db.flushed++
+	db.stall.Broadcast()
+	db.lock.Unlock()
+
+	db.signal(db.compactC)
+	mt.decrRef()
+	// a WAL left behind is replayed and flushed again, which is harmless
+	return mt.wal.Delete()
+}
+
+// Flush writes every memtable to level 0 and waits until they are on disk.
+// Like a write, it waits while NumMemtables memtables are queued already.
+func (db *DB) Flush() error {
+	db.writeLock.Lock()
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	err := db.rotateForFlush()
+	db.writeLock.Unlock()
+	if err != nil {
+		return err
+	}
+	return db.waitForFlush(db.flushed + uint64(len(db.imm)))
+}
+
+// waitForFlush waits until target memtables were flushed, db.lock is held
+func (db *DB) waitForFlush(target uint64) error {
+	for db.flushed < target {
+		if db.closed {
+			return utils.ErrDBClosed
+		}
+		if db.bgErr != nil {
+			return db.bgErr
+		}
+		db.stall.Wait()
+	}
+	return nil
+}
+
+// rotateForFlush queues the memtable for its flush unless it is empty,
+// writeLock and db.lock are held
+func (db *DB) rotateForFlush() error {
+	for {
+		switch {
+		case db.closed:
+			return utils.ErrDBClosed
+		case db.bgErr != nil:
+			return db.bgErr
+		case db.mt.empty():
+			return nil
+		case len(db.imm) >= db.opt.NumMemtables:
+			db.stall.Wait()
+		default:
+			return db.rotate()
+		}
+	}
+}
+
+// CompactRange flushes the memtables, then merges every table holding user
+// keys in [start, end) down to targetLevel, nil leaves that side open. It
+// runs as a job of the background compactor would, after the one in
+// progress, and wakes the writes it stalled once it is done. It compacts the
+// default family.
+func (db *DB) CompactRange(start, end []byte, targetLevel int) (*CompactionStats, error) {
+	return db.compactRange(db.def, start, end, targetLevel)
+}
+
+func (db *DB) compactRange(cf *ColumnFamily, start, end []byte, targetLevel int) (*CompactionStats, error) {
+	// level 0 outputs would count as newer than tables flushed meanwhile
+	if targetLevel < 1 {
+		return nil, utils.ErrInvalidRequest
+	}
+	if err := db.Flush(); err != nil {
+		return nil, err
+	}
+
+	db.compactLock.Lock()
+	defer db.compactLock.Unlock()
+	db.lock.RLock()
+	if err := cf.readable(); err != nil {
+		db.lock.RUnlock()
+		return nil, err
+	}
+	if targetLevel >= len(cf.lm.levels) {
+		db.lock.RUnlock()
+		return nil, utils.ErrInvalidRequest
+	}
+	cd, err := cf.lm.tc.planCompactRange(cf.lm.levels, start, end, 0, targetLevel, db.discardTs())
+	db.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(cd.inputs) == 0 {
+		return &CompactionStats{}, nil
+	}
+	return db.runCompaction(cf, cd)
+}
+
+// discardTs is the version below which only the newest version of a key is
+// still read: the newest version, or the one written ScanRetention ago for
+// the scans resuming a token. It never goes down while the DB is open.
+func (db *DB) discardTs() uint64 {
+	ts := atomic.LoadUint64(&db.version)
+	if db.watermark != nil {
+		ts = min(ts, db.watermark.versionAt(db.opt.clock().Now().Add(-db.opt.ScanRetention)))
+	}
+	return ts
+}
+
+// runCompaction runs cd on the tables of cf, records it in the manifest and
+// swaps the tables. compactLock is held, so cf isn't dropped meanwhile.
+func (db *DB) runCompaction(cf *ColumnFamily, cd *compactDef) (*CompactionStats, error) {
+	lm := cf.lm
+	outputs, stats, err := lm.tc.runCompaction(cd, db.newFid)
+	if err != nil {
+		return stats, err
+	}
+	db.lock.Lock()
+	if err := db.manifest.AddChanges(lm.compactionChanges(cd, outputs)); err != nil {
+		db.lock.Unlock()
+		lm.removeTables(outputs)
+		return stats, err
+	}
+	lm.levels = applyCompaction(lm.levels, cd, outputs)
+	db.stall.Broadcast()
+	db.lock.Unlock()
+	// readers holding the inputs keep them open until they release them
+	lm.removeTables(cd.inputs)
+	return stats, nil
+}
+
+// compactLoop runs compactions while some level is due for one
+func (db *DB) compactLoop() {
+	defer db.wg.Done()
+	ticker := time.NewTicker(compactionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.closer:
+			return
+		case <-ticker.C:
+		case <-db.compactC:
+		}
+		for {
+			select {
+			case <-db.closer:
+				return
+			default:
+			}
+			done, err := db.compactOnce()
+			if err != nil {
+				utils.Err(err)
+			}
+			if !done {
+				break
+			}
+		}
+	}
+}
+
+// compactOnce runs the compaction of the level most due for one in the
+// first family that has one, it returns false if no level is. Every
+// TTLCompactionInterval it also looks for tables whose entries have all
+// expired and compacts each of them once. Tables out of seek budget go last,
+// one per call, like in LevelDB size compactions come first.
+func (db *DB) compactOnce() (bool, error) {
+	db.compactLock.Lock()
+	defer db.compactLock.Unlock()
+	now := db.opt.clock().Now()
+	if db.opt.TTLCompactionInterval > 0 && !now.Before(db.nextTTLCompaction) {
+		// the next look is an interval away whatever this one finds
+		db.nextTTLCompaction = now.Add(db.opt.TTLCompactionInterval)
+		for _, cf := range db.cfs.all() {
+			db.lock.RLock()
+			levels, dropped := cf.lm.levels, cf.dropped
+			db.lock.RUnlock()
+			if dropped {
+				continue
+			}
+			if err := cf.lm.findExpired(levels, now, db.discardTs()); err != nil {
+				return false, err
+			}
+		}
+	}
+	for _, cf := range db.cfs.all() {
+		db.lock.RLock()
+		levels, dropped := cf.lm.levels, cf.dropped
+		db.lock.RUnlock()
+		if dropped {
+			continue
+		}
+		cd, err := cf.lm.pickCompaction(levels, db.discardTs())
+		if err != nil {
+			return false, err
+		}
+		if cd == nil || len(cd.inputs) == 0 {
+			if cd, err = cf.lm.pickExpiredCompaction(levels, db.discardTs()); err != nil {
+				return false, err
+			}
+		}
+		if cd == nil || len(cd.inputs) == 0 {
+			if cd, err = cf.lm.pickSeekCompaction(levels, db.discardTs()); err != nil {
+				return false, err
+			}
+		}
+		if cd == nil || len(cd.inputs) == 0 {
+			continue
+		}
+		if _, err := db.runCompaction(cf, cd); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,165 @@
+package lsm
+
+import (
+	"bytes"
+	"time"
+
+	"TLKV/utils"
+)
+
+// Iterator iterates the live user keys of the DB in ascending order, each
+// at its newest version written before the iterator was created. Items hold
+// the user key.
+type Iterator struct {
+	mi       *mergeIterator
+	opt      utils.Options
+	readTs   uint64
+	now      time.Time
+	op       MergeOperator
+	vlog     *valueLog
+	item     *Item
+	err      error
+	versions []*utils.Entry
+}
+
+// NewIterator returns an iterator over the user keys of the default family
+// inside the bounds and prefix of opt, nil for all of them. IsAsc is
+// ignored, the memtables can only be iterated forwards. The iterator holds
+// the memtables and tables it reads until it is closed.
+func (db *DB) NewIterator(opt *utils.Options) (*Iterator, error) {
+	return db.newIterator(db.def, opt)
+}
+
+func (db *DB) newIterator(cf *ColumnFamily, opt *utils.Options) (*Iterator, error) {
+	if opt == nil {
+		opt = &utils.Options{}
+	}
+	readTs := db.readTs()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if err := cf.readable(); err != nil {
+		return nil, err
+	}
+	iters, err := db.iterators(cf, opt)
+	if err != nil {
+		return nil, err
+	}
+	return &Iterator{
+		mi:     newMergeIterator(iters),
+		opt:    *opt,
+		readTs: readTs,
+		now:    cf.Opt.clock().Now(),
+		op:     cf.Opt.MergeOperator,
+		vlog:   db.vlog,
+	}, nil
+}
+
+// iterators returns ascending iterators over the internal keys of family cf
+// in the memtables and the tables inside opt, newest first as mergeIterator
+// wants them. db.lock is held.
+func (db *DB) iterators(cf *ColumnFamily, opt *utils.Options) ([]utils.Iterator, error) {
+	var iters []utils.Iterator
+	for _, mt := range db.memTables() {
+		if sl := mt.skiplist(cf.ID); sl != nil {
+			iters = append(iters, sl.NewSkipListIterator())
+		}
+	}
+	tableOpt := *opt
+	tableOpt.IsAsc = true
+	for _, fids := range cf.lm.levels {
+		its, err := cf.lm.tc.newIterators(fids, &tableOpt)
+		if err != nil {
+			newMergeIterator(iters).Close()
+			return nil, err
+		}
+		iters = append(iters, its...)
+	}
+	return iters, nil
+}
+
+// Rewind moves to the first key
+func (it *Iterator) Rewind() {
+	it.Seek(nil)
+}
+
+// Seek moves to the first key at or after key
+func (it *Iterator) Seek(key []byte) {
+	from := key
+	if bytes.Compare(it.opt.LowerBound, from) > 0 {
+		from = it.opt.LowerBound
+	}
+	if bytes.Compare(it.opt.Prefix, from) > 0 {
+		from = it.opt.Prefix
+	}
+	if len(from) == 0 {
+		it.mi.Rewind()
+	} else {
+		it.mi.Seek(utils.KeyWithTs(from, it.readTs))
+	}
+	it.settle()
+}
+
+// Next moves to the next key
+func (it *Iterator) Next() {
+	it.settle()
+}
+
+// settle moves to the first live key from the merge iterator on, leaving
+// the merge iterator at the key after it
+func (it *Iterator) settle() {
+	it.item = nil
+	for it.err == nil && it.mi.Valid() {
+		key := utils.ParseKey(it.mi.Item().Entry().Key)
+		if !it.opt.InBounds(key) {
+			// keys start inside the lower bound and the prefix, this is the end
+			return
+		}
+		key = utils.SafeCopy(nil, key)
+		it.versions = it.versions[:0]
+		for ; it.mi.Valid(); it.mi.Next() {
+			e := it.mi.Item().Entry()
+			if !bytes.Equal(utils.ParseKey(e.Key), key) {
+				break
+			}
+			if utils.ParseTs(e.Key) <= it.readTs {
+				it.versions = append(it.versions, copyEntry(e))
+			}
+		}
+		if len(it.versions) == 0 {
+			continue
+		}
+		merged, err := mergeEntries(it.op, it.vlog, it.versions, true, it.now)
+		if err != nil {
+			it.err = err
+			return
+		}
+		if e := merged[0]; !e.IsDeletedOrExpiredAt(it.now) {
+			if e, it.err = it.vlog.resolve(e); it.err != nil {
+				return
+			}
+			e.Key = key
+			it.item = &Item{e: e}
+			return
+		}
+	}
+}
+
+// Valid reports whether the iterator is at a key
+func (it *Iterator) Valid() bool {
+	return it.item != nil
+}
+
+// Item returns the entry the iterator is at
+func (it *Iterator) Item() utils.Item {
+	return it.item
+}
+
+// Error returns the error that stopped the iteration, if any
+func (it *Iterator) Error() error {
+	return it.err
+}
+
+// Close releases the memtables and tables of the iterator
+func (it *Iterator) Close() error {
+	return it.mi.Close()
+}
@@ -0,0 +1,776 @@
+package lsm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"TLKV/utils"
+)
+
+// testDBOptions leaves background compaction off so tests decide when tables move
+func testDBOptions(t *testing.T) (*Options, *utils.ManualClock) {
+	clock := utils.NewManualClock(time.Unix(1_700_000_000, 0))
+	opt := testOptions(t)
+	opt.MemTableSize = 1 << 16
+	opt.Clock = clock
+	return opt, clock
+}
+
+func openTestDB(t *testing.T, opt *Options) *DB {
+	db, err := Open(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func mustGet(t *testing.T, db *DB, key, want string) {
+	t.Helper()
+	e, err := db.Get([]byte(key))
+	if err != nil {
+		t.Fatalf("get %s: %v", key, err)
+	}
+	if string(e.Key) != key || string(e.Value) != want {
+		t.Fatalf("get %s = %s:%q, want %q", key, e.Key, e.Value, want)
+	}
+}
+
+func mustMiss(t *testing.T, db *DB, key string) {
+	t.Helper()
+	if _, err := db.Get([]byte(key)); err != utils.ErrKeyNotFound {
+		t.Fatalf("get %s: %v, want ErrKeyNotFound", key, err)
+	}
+}
+
+// flushDB writes the memtables of db to level 0 and waits for it
+func flushDB(t *testing.T, db *DB) {
+	t.Helper()
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// compactDB merges every table of the default family down to level
+func compactDB(t *testing.T, db *DB, level int) {
+	t.Helper()
+	compactFamily(t, db.def, level)
+}
+
+// compactFamily merges every table of cf down to level
+func compactFamily(t *testing.T, cf *ColumnFamily, level int) {
+	t.Helper()
+	if _, err := cf.CompactRange(nil, nil, level); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDBSetGetDel(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	if err := db.Set(utils.NewEntry([]byte("a"), []byte("1"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(utils.NewEntry([]byte("empty"), []byte{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(utils.NewEntry([]byte("legacy"), nil)); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "a", "1")
+	mustGet(t, db, "empty", "")
+	mustMiss(t, db, "legacy")
+	mustMiss(t, db, "b")
+
+	if err := db.Set(utils.NewEntry([]byte("a"), []byte("2"))); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "a", "2")
+	if err := db.Del([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	mustMiss(t, db, "a")
+	if err := db.Set(utils.NewEntry(nil, []byte("x"))); err != utils.ErrEmptyKey {
+		t.Fatalf("empty key: %v", err)
+	}
+}
+
+func TestDBTTL(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	db := openTestDB(t, opt)
+	if err := db.Set(utils.NewEntry([]byte("k"), []byte("v")).WithTTLOnWrite(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(59 * time.Second)
+	mustGet(t, db, "k", "v")
+	flushDB(t, db)
+	mustGet(t, db, "k", "v")
+	clock.Advance(time.Second)
+	mustMiss(t, db, "k")
+}
+
+// drainCompactions runs compactions until none is due
+func drainCompactions(t *testing.T, db *DB) {
+	t.Helper()
+	for {
+		done, err := db.compactOnce()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !done {
+			return
+		}
+	}
+}
+
+func TestDBTTLCompaction(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	opt.TTLCompactionInterval = time.Hour
+	db := openTestDB(t, opt)
+	set := func(key, val string, ttl time.Duration) {
+		t.Helper()
+		if err := db.Set(utils.NewEntry([]byte(key), []byte(val)).WithTTLOnWrite(ttl)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tableIDs := func() map[uint64]int {
+		t.Helper()
+		tables, err := db.Tables()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[uint64]int)
+		for _, ti := range tables {
+			ids[ti.ID] = ti.Level
+		}
+		return ids
+	}
+	// an old version without TTL at the bottom, shadowed by the sessions
+	set("s00", "old", 0)
+	compactDB(t, db, 3)
+	for i := 0; i < 50; i++ {
+		set(fmt.Sprintf("s%02d", i), "session", time.Minute)
+	}
+	flushDB(t, db)
+	set("z-keep", "forever", 0)
+	flushDB(t, db)
+	before := tableIDs()
+	if len(before) != 3 {
+		t.Fatalf("tables %v", before)
+	}
+	// nothing expired yet, the next look is an interval away
+	drainCompactions(t, db)
+	if got := tableIDs(); len(got) != 3 {
+		t.Fatalf("compacted live tables: %v", got)
+	}
+
+	clock.Advance(time.Hour)
+	drainCompactions(t, db)
+	mustMiss(t, db, "s00")
+	mustMiss(t, db, "s49")
+	mustGet(t, db, "z-keep", "forever")
+	tables, err := db.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the sessions and the version they shadowed are gone, the table
+	// holding z-keep never expires and stays where it is
+	if len(tables) != 1 || tables[0].Level != 0 || tables[0].KeyCount != 1 {
+		t.Fatalf("tables after the ttl compaction %+v", tables)
+	}
+
+	// expired tables wait for the next look
+	set("s01", "session", time.Minute)
+	flushDB(t, db)
+	clock.Advance(2 * time.Minute)
+	mustMiss(t, db, "s01")
+	drainCompactions(t, db)
+	if got := tableIDs(); len(got) != 2 {
+		t.Fatalf("compacted before the interval: %v", got)
+	}
+	clock.Advance(time.Hour)
+	drainCompactions(t, db)
+	if got := tableIDs(); len(got) != 1 {
+		t.Fatalf("expired table left: %v", got)
+	}
+}
+
+func TestDBTTLCompactionRetention(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	opt.TTLCompactionInterval = time.Minute
+	opt.ScanRetention = 10 * time.Minute
+	db := openTestDB(t, opt)
+	set := func(key, val string, ttl time.Duration) {
+		t.Helper()
+		if err := db.Set(utils.NewEntry([]byte(key), []byte(val)).WithTTLOnWrite(ttl)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tables := func() []TableInfo {
+		t.Helper()
+		tables, err := db.Tables()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tables
+	}
+	set("s00", "old", 0)
+	set("s99", "keep", 0)
+	compactDB(t, db, 3)
+	for i := 0; i < 50; i++ {
+		set(fmt.Sprintf("s%02d", i), "session", time.Minute)
+	}
+	flushDB(t, db)
+	if got := tables(); len(got) != 2 {
+		t.Fatalf("tables %+v", got)
+	}
+
+	// expired, but scans may still read the sessions: nothing to compact
+	fid := atomic.LoadUint64(&db.nextFid)
+	clock.Advance(2 * time.Minute)
+	drainCompactions(t, db)
+	mustMiss(t, db, "s49")
+	if got := tables(); len(got) != 2 || atomic.LoadUint64(&db.nextFid) != fid {
+		t.Fatalf("compacted tables scans still read: %+v", got)
+	}
+
+	// past the retention the table is compacted exactly once, into the
+	// single table left holding s99
+	clock.Advance(10 * time.Minute)
+	drainCompactions(t, db)
+	got := tables()
+	if len(got) != 1 || got[0].KeyCount != 1 || atomic.LoadUint64(&db.nextFid) != fid+1 {
+		t.Fatalf("tables after the ttl compaction %+v, %d tables written", got, atomic.LoadUint64(&db.nextFid)-fid)
+	}
+	mustMiss(t, db, "s00")
+	mustGet(t, db, "s99", "keep")
+	clock.Advance(time.Hour)
+	drainCompactions(t, db)
+	if atomic.LoadUint64(&db.nextFid) != fid+1 {
+		t.Fatalf("compacted again: %+v", tables())
+	}
+}
+
+func TestDBReopen(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db, err := Open(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2000; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%04d", i)), []byte(fmt.Sprintf("v%d", i)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Del([]byte("k0007")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get([]byte("k0001")); err != utils.ErrDBClosed {
+		t.Fatalf("get after close: %v", err)
+	}
+
+	db = openTestDB(t, opt)
+	mustGet(t, db, "k0001", "v1")
+	mustGet(t, db, "k1999", "v1999")
+	mustMiss(t, db, "k0007")
+	// versions keep growing after a restart, so new writes win
+	if err := db.Set(utils.NewEntry([]byte("k0001"), []byte("new"))); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "k0001", "new")
+}
+
+func TestDBRotatesFullMemTable(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	val := bytes.Repeat([]byte("x"), 1000)
+	for i := 0; i < 200; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%04d", i)), val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	flushDB(t, db)
+	tables, err := db.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) < 2 {
+		t.Fatalf("%d tables, want a flush per full memtable", len(tables))
+	}
+	for i := 0; i < 200; i++ {
+		mustGet(t, db, fmt.Sprintf("k%04d", i), string(val))
+	}
+	big := bytes.Repeat([]byte("x"), int(opt.MemTableSize))
+	if err := db.Set(utils.NewEntry([]byte("big"), big)); err != utils.ErrTxnTooBig {
+		t.Fatalf("oversized entry: %v", err)
+	}
+}
+
+func TestDBFlushSkipsEmptyMemTable(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := db.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 0 {
+		t.Fatalf("flushing nothing wrote %d tables", len(tables))
+	}
+
+	tc := newTableCache(opt, nil, nil)
+	fid, _, err := tc.flushMemTable(opt.newSkipList(), func() uint64 { return 99 })
+	if err != nil || fid != 0 {
+		t.Fatalf("empty skiplist: fid %d, %v", fid, err)
+	}
+	if _, err := os.Stat(utils.FileNameSSTable(opt.WorkDir, 99)); !os.IsNotExist(err) {
+		t.Fatalf("empty skiplist left a table: %v", err)
+	}
+}
+
+func TestDBCompactRange(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 300; i++ {
+			key := []byte(fmt.Sprintf("k%04d", i))
+			if err := db.Set(utils.NewEntry(key, []byte(fmt.Sprintf("v%d-%d", i, round)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := db.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 300; i += 2 {
+		if err := db.Del([]byte(fmt.Sprintf("k%04d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.CompactRange(nil, nil, 0); err != utils.ErrInvalidRequest {
+		t.Fatalf("target level 0: %v", err)
+	}
+	stats, err := db.CompactRange(nil, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TablesRead != 4 || stats.BytesRead == 0 || stats.BytesWritten == 0 || stats.BytesWritten >= stats.BytesRead {
+		t.Fatalf("stats %+v", stats)
+	}
+	tables, err := db.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys uint32
+	for _, ti := range tables {
+		if ti.Level != 2 {
+			t.Fatalf("table %d left at level %d", ti.ID, ti.Level)
+		}
+		keys += ti.KeyCount
+	}
+	// the bottommost compaction drops tombstones and old versions
+	if keys != 150 {
+		t.Fatalf("%d keys after compaction, want 150", keys)
+	}
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("k%04d", i)
+		if i%2 == 0 {
+			mustMiss(t, db, key)
+		} else {
+			mustGet(t, db, key, fmt.Sprintf("v%d-2", i))
+		}
+	}
+	ssts, _ := filepath.Glob(filepath.Join(opt.WorkDir, "*.sst"))
+	if len(ssts) != len(tables) {
+		t.Fatalf("%d table files for %d tables", len(ssts), len(tables))
+	}
+
+	// the levels survive a restart through the manifest
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db = openTestDB(t, opt)
+	mustGet(t, db, "k0001", "v1-2")
+	mustMiss(t, db, "k0000")
+}
+
+type failingMerge struct{}
+
+func (failingMerge) Name() string { return "failing" }
+
+func (failingMerge) FullMerge(key, existing []byte, operands [][]byte) ([]byte, error) {
+	return nil, errors.New("merge failed")
+}
+
+func (failingMerge) PartialMerge(key, left, right []byte) ([]byte, bool) { return nil, false }
+
+func TestCompactionRemovesOutputsOnError(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	opt.SSTableMaxSz = 4 << 10
+	opt.MergeOperator = failingMerge{}
+	db := openTestDB(t, opt)
+	for i := 0; i < 500; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%04d", i)), []byte("value"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the last key fails to merge once many output tables are written
+	if err := db.Set(utils.NewMergeEntry([]byte("k9999"), []byte("op"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := filepath.Glob(filepath.Join(opt.WorkDir, "*.sst"))
+	if _, err := db.CompactRange(nil, nil, 1); err == nil {
+		t.Fatal("compaction with a failing merge succeeded")
+	}
+	after, _ := filepath.Glob(filepath.Join(opt.WorkDir, "*.sst"))
+	if len(after) != len(before) {
+		t.Fatalf("%d table files before the failed compaction, %d after", len(before), len(after))
+	}
+	mustGet(t, db, "k0001", "value")
+}
+
+func TestDBWriteStall(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	opt.NumLevelZeroTablesStall = 1
+	db := openTestDB(t, opt)
+	if err := db.Set(utils.NewEntry([]byte("a"), []byte("1"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- db.Set(utils.NewEntry([]byte("b"), []byte("2"))) }()
+	select {
+	case err := <-done:
+		t.Fatalf("write went through a stalled level 0: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// nothing compacts level 0 in the background, the manual compaction
+	// has to release the write
+	if _, err := db.CompactRange(nil, nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write still stalled after the compaction")
+	}
+	mustGet(t, db, "b", "2")
+}
+
+func TestDBBackgroundCompaction(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	opt.NumLevelZeroTables = 2
+	db := openTestDB(t, opt)
+	for round := 0; round < 4; round++ {
+		for i := 0; i < 100; i++ {
+			key := []byte(fmt.Sprintf("k%04d", i))
+			if err := db.Set(utils.NewEntry(key, []byte(fmt.Sprint(round)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		flushDB(t, db)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tables, err := db.Tables()
+		if err != nil {
+			t.Fatal(err)
+		}
+		l0 := 0
+		for _, ti := range tables {
+			if ti.Level == 0 {
+				l0++
+			}
+		}
+		if l0 < 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("level 0 still has %d tables", l0)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mustGet(t, db, "k0042", "3")
+}
+
+func TestDBIterator(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	for i := 0; i < 100; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%04d", i)), []byte("old"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	flushDB(t, db)
+	for i := 0; i < 100; i += 3 {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%04d", i)), []byte("new"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Del([]byte("k0010")); err != nil {
+		t.Fatal(err)
+	}
+	itr, err := db.NewIterator(&utils.Options{LowerBound: []byte("k0005"), UpperBound: []byte("k0020")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// written after the iterator was created, it isn't seen
+	if err := db.Set(utils.NewEntry([]byte("k0006"), []byte("later"))); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		e := itr.Item().Entry()
+		got = append(got, string(e.Key)+"="+string(e.Value))
+	}
+	if err := itr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for i := 5; i < 20; i++ {
+		if i == 10 {
+			continue
+		}
+		v := "old"
+		if i%3 == 0 {
+			v = "new"
+		}
+		want = append(want, fmt.Sprintf("k%04d=%s", i, v))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestDBConditionalWrites(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	db := openTestDB(t, opt)
+	if err := db.SetIfAbsent([]byte("k"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	err := db.SetIfAbsent([]byte("k"), []byte("2"))
+	var ce *utils.ConflictError
+	if !errors.As(err, &ce) || !errors.Is(err, utils.ErrConflict) || string(ce.Current) != "1" {
+		t.Fatalf("set if present: %v", err)
+	}
+	if err := db.CompareAndSet([]byte("k"), []byte("x"), []byte("2")); !errors.Is(err, utils.ErrConflict) {
+		t.Fatalf("compare with the wrong value: %v", err)
+	}
+	if err := db.CompareAndSet([]byte("k"), []byte("1"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "k", "2")
+	err = db.CompareAndSet([]byte("missing"), []byte("1"), []byte("2"))
+	if !errors.As(err, &ce) || ce.Current != nil {
+		t.Fatalf("compare an absent key: %v", err)
+	}
+	// an empty value is present
+	if err := db.Set(utils.NewEntry([]byte("empty"), []byte{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetIfAbsent([]byte("empty"), []byte("x")); !errors.Is(err, utils.ErrConflict) {
+		t.Fatalf("set if absent over an empty value: %v", err)
+	}
+	if err := db.CompareAndSet([]byte("empty"), []byte{}, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	// expired and deleted keys are absent
+	if err := db.Set(utils.NewEntry([]byte("ttl"), []byte("v")).WithTTLOnWrite(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Second)
+	if err := db.SetIfAbsent([]byte("ttl"), []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Del([]byte("k")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetIfAbsent([]byte("k"), []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	// the current value is read from the tables too
+	flushDB(t, db)
+	if err := db.CompareAndSet([]byte("k"), []byte("3"), []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "k", "4")
+	if err := db.SetIfAbsent(nil, []byte("x")); err != utils.ErrEmptyKey {
+		t.Fatalf("empty key: %v", err)
+	}
+}
+
+func TestDBIncrement(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	if n, err := db.Increment([]byte("n"), 5); err != nil || n != 5 {
+		t.Fatalf("increment an absent key: %d %v", n, err)
+	}
+	if n, err := db.Increment([]byte("n"), -7); err != nil || n != -2 {
+		t.Fatalf("decrement: %d %v", n, err)
+	}
+	if err := db.Set(utils.NewEntry([]byte("s"), []byte("text"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Increment([]byte("s"), 1); err != utils.ErrNotInteger {
+		t.Fatalf("increment a string: %v", err)
+	}
+	mustGet(t, db, "s", "text")
+
+	// concurrent increments are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := db.Increment([]byte("c"), 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n, err := db.Increment([]byte("c"), 0); err != nil || n != 400 {
+		t.Fatalf("counter %d %v, want 400", n, err)
+	}
+}
+
+// modelValue is what a key of TestDBRoundTrip should read as
+type modelValue struct {
+	value     string
+	expiresAt uint64
+}
+
+// TestDBRoundTrip runs random writes with TTLs against a DB using every
+// table feature and checks it against a map through flushes, compactions,
+// reopens and the clock moving on.
+func TestDBRoundTrip(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	opt.MemTableSize = 1 << 15
+	opt.BlockRestartInterval = 8
+	opt.BlockHashIndex = true
+	opt.IndexPartitionSize = 256
+	opt.IndexCacheSize = 1 << 20
+	opt.BlockCacheSize = 1 << 20
+	opt.FilterPolicies = []utils.FilterPolicy{utils.NewBlockedBloomPolicy(10), utils.NewXorPolicy()}
+	opt.PrefixExtractor = utils.NewFixedPrefix(2)
+	opt.BlockCompression = []utils.CompressionType{utils.NoCompression, utils.LZCompression, utils.FlateCompression}
+	opt.MemTableBloomBitsPerKey = 10
+	opt.ValueThreshold = 128
+	opt.ValueLogFileSize = 16 << 10
+	opt.EncryptionKey = bytes.Repeat([]byte{9}, 16)
+	db := openTestDB(t, opt)
+
+	rng := rand.New(rand.NewSource(1))
+	model := make(map[string]modelValue)
+	check := func(stage string) {
+		t.Helper()
+		now := uint64(clock.Now().Unix())
+		var want []string
+		for i := 0; i < 300; i++ {
+			key := fmt.Sprintf("%02d/%04d", i%7, i)
+			mv, ok := model[key]
+			live := ok && (mv.expiresAt == 0 || mv.expiresAt > now)
+			e, err := db.Get([]byte(key))
+			switch {
+			case !live && err != utils.ErrKeyNotFound:
+				t.Fatalf("%s: get %s: %v, want ErrKeyNotFound", stage, key, err)
+			case live && err != nil:
+				t.Fatalf("%s: get %s: %v", stage, key, err)
+			case live && string(e.Value) != mv.value:
+				t.Fatalf("%s: get %s = %.20q, want %.20q", stage, key, e.Value, mv.value)
+			}
+			if live && key[:2] == "03" {
+				want = append(want, key)
+			}
+		}
+		sort.Strings(want)
+		itr, err := db.NewIterator(&utils.Options{Prefix: []byte("03")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer itr.Close()
+		var got []string
+		for itr.Rewind(); itr.Valid(); itr.Next() {
+			got = append(got, string(itr.Item().Entry().Key))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: prefix scan got %v\nwant %v", stage, got, want)
+		}
+	}
+
+	for round := 0; round < 6; round++ {
+		for n := 0; n < 400; n++ {
+			i := rng.Intn(300)
+			key := fmt.Sprintf("%02d/%04d", i%7, i)
+			switch op := rng.Intn(10); {
+			case op == 0:
+				if err := db.Del([]byte(key)); err != nil {
+					t.Fatal(err)
+				}
+				delete(model, key)
+			default:
+				value := fmt.Sprintf("%s-%d-%d", key, round, n)
+				if op < 3 {
+					// some values go to the value log
+					value += strings.Repeat("v", 150)
+				}
+				e := utils.NewEntry([]byte(key), []byte(value))
+				mv := modelValue{value: value}
+				if op == 9 {
+					ttl := time.Duration(1+rng.Intn(120)) * time.Second
+					e.WithTTLOnWrite(ttl)
+					mv.expiresAt = uint64(clock.Now().Add(ttl).Unix())
+				}
+				if err := db.Set(e); err != nil {
+					t.Fatal(err)
+				}
+				model[key] = mv
+			}
+		}
+		check(fmt.Sprintf("round %d written", round))
+		clock.Advance(time.Duration(rng.Intn(90)) * time.Second)
+		check(fmt.Sprintf("round %d later", round))
+
+		switch round % 3 {
+		case 0:
+			flushDB(t, db)
+		case 1:
+			compactDB(t, db, 1+round%3)
+		case 2:
+			if err := db.Close(); err != nil {
+				t.Fatal(err)
+			}
+			db = openTestDB(t, opt)
+		}
+		check(fmt.Sprintf("round %d after step %d", round, round%3))
+	}
+	clock.Advance(time.Hour)
+	compactDB(t, db, opt.withDefaults().MaxLevelNum-1)
+	check("bottom")
+}
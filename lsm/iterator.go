@@ -0,0 +1,13 @@
+package lsm
+
+import "TLKV/utils"
+
+// Item is the entry an iterator is positioned at
+type Item struct {
+	e *utils.Entry
+}
+
+// Entry returns the entry of the item
+func (it *Item) Entry() *utils.Entry {
+	return it.e
+}
@@ -0,0 +1,248 @@
+package lsm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"TLKV/pb"
+	"TLKV/utils"
+
+	"github.com/pkg/errors"
+)
+
+// sanityText is sealed with the master key at the head of the registry file
+// so that opening with a wrong key fails instead of producing garbage
+var sanityText = []byte("Hello TLKV")
+
+// KeyRegistry keeps the data keys that SST, WAL and value log files are
+// encrypted with. Data keys are stored in the KEYREGISTRY file sealed with
+// the user supplied master key and a new one is created every rotation
+// period; old keys are kept so files written with them stay readable.
+//
+// KEYREGISTRY: | iv | sanityText sealed | record ... |
+// record:      | len(DataKey) | crc32(DataKey) | DataKey with data sealed |
+type KeyRegistry struct {
+	lock        sync.RWMutex
+	dataKeys    map[uint64]*pb.DataKey
+	lastCreated int64 // unix seconds the latest data key was created at
+	nextKeyID   uint64
+	fd          *os.File
+	opt         *Options
+}
+
+// OpenKeyRegistry opens or creates the key registry in opt.WorkDir. Without
+// an EncryptionKey it returns an empty registry and nothing is encrypted.
+func OpenKeyRegistry(opt *Options) (*KeyRegistry, error) {
+	kr := &KeyRegistry{
+		dataKeys:  make(map[uint64]*pb.DataKey),
+		nextKeyID: 1,
+		opt:       opt,
+	}
+	if len(opt.EncryptionKey) == 0 {
+		return kr, nil
+	}
+	if !utils.ValidEncryptionKey(opt.EncryptionKey) {
+		return nil, utils.ErrInvalidEncryptionKey
+	}
+
+	path := filepath.Join(opt.WorkDir, utils.KeyRegistryFileName)
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while opening key registry: %s", path)
+	}
+	kr.fd = fd
+	fi, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if fi.Size() == 0 {
+		err = kr.writeHeader()
+	} else {
+		err = kr.replay()
+	}
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	return kr, nil
+}
+
+func (kr *KeyRegistry) writeHeader() error {
+	iv, err := utils.GenerateIV()
+	if err != nil {
+		return err
+	}
+	sealed, err := utils.XORBlockAllocate(sanityText, kr.opt.EncryptionKey, iv)
+	if err != nil {
+		return err
+	}
+	if _, err := kr.fd.Write(append(iv, sealed...)); err != nil {
+		return err
+	}
+	if err := kr.fd.Sync(); err != nil {
+		return err
+	}
+	return utils.SyncDir(kr.opt.WorkDir)
+}
+
+func (kr *KeyRegistry) replay() error {
+	r := io.Reader(kr.fd)
+	header := make([]byte, aes.BlockSize+len(sanityText))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return errors.Wrap(err, "while reading key registry header")
+	}
+	text, err := utils.XORBlockAllocate(header[aes.BlockSize:], kr.opt.EncryptionKey, header[:aes.BlockSize])
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(text, sanityText) {
+		return utils.ErrEncryptionKeyMismatch
+	}
+
+	var offset = int64(len(header))
+	var lenCrc [8]byte
+	for {
+		if _, err := io.ReadFull(r, lenCrc[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return err
+		}
+		buf := make([]byte, binary.BigEndian.Uint32(lenCrc[:4]))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				break
+			}
+			return err
+		}
+		if crc32.Checksum(buf, utils.CastagnoliCrcTable) != binary.BigEndian.Uint32(lenCrc[4:]) {
+			// torn write at the tail, everything before it is intact
+			break
+		}
+		dk := &pb.DataKey{}
+		if err := dk.Unmarshal(buf); err != nil {
+			return err
+		}
+		if dk.Data, err = utils.XORBlockAllocate(dk.Data, kr.opt.EncryptionKey, dk.Iv); err != nil {
+			return err
+		}
+		kr.add(dk)
+		offset += int64(len(lenCrc) + len(buf))
+	}
+	// drop a torn tail so new keys are appended after the last good one
+	if err := kr.fd.Truncate(offset); err != nil {
+		return err
+	}
+	_, err = kr.fd.Seek(offset, io.SeekStart)
+	return err
+}
+
+func (kr *KeyRegistry) add(dk *pb.DataKey) {
+	kr.dataKeys[dk.KeyId] = dk
+	if dk.KeyId >= kr.nextKeyID {
+		kr.nextKeyID = dk.KeyId + 1
+	}
+	if dk.CreatedAt > kr.lastCreated {
+		kr.lastCreated = dk.CreatedAt
+	}
+}
+
+func (kr *KeyRegistry) store(dk *pb.DataKey) error {
+	sealed := *dk
+	var err error
+	if sealed.Data, err = utils.XORBlockAllocate(dk.Data, kr.opt.EncryptionKey, dk.Iv); err != nil {
+		return err
+	}
+	buf, err := sealed.Marshal()
+	if err != nil {
+		return err
+	}
+	var lenCrc [8]byte
+	binary.BigEndian.PutUint32(lenCrc[:4], uint32(len(buf)))
+	binary.BigEndian.PutUint32(lenCrc[4:], crc32.Checksum(buf, utils.CastagnoliCrcTable))
+	if _, err := kr.fd.Write(append(lenCrc[:], buf...)); err != nil {
+		return err
+	}
+	return kr.fd.Sync()
+}
+
+// DataKey returns the data key with id, nil for id 0 which means unencrypted
+func (kr *KeyRegistry) DataKey(id uint64) (*pb.DataKey, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	kr.lock.RLock()
+	defer kr.lock.RUnlock()
+	dk, ok := kr.dataKeys[id]
+	if !ok {
+		return nil, errors.Wrapf(utils.ErrInvalidDataKeyID, "key id: %d", id)
+	}
+	return dk, nil
+}
+
+// LatestDataKey returns the key new files are encrypted with, rotating it
+// once it is older than EncryptionKeyRotationDuration unless that is 0. It
+// is nil when encryption is off.
+func (kr *KeyRegistry) LatestDataKey() (*pb.DataKey, error) {
+	if len(kr.opt.EncryptionKey) == 0 {
+		return nil, nil
+	}
+	now := kr.opt.clock().Now()
+	valid := func() bool {
+		if len(kr.dataKeys) == 0 {
+			return false
+		}
+		d := kr.opt.EncryptionKeyRotationDuration
+		return d <= 0 || now.Sub(time.Unix(kr.lastCreated, 0)) < d
+	}
+	kr.lock.RLock()
+	if valid() {
+		dk := kr.dataKeys[kr.nextKeyID-1]
+		kr.lock.RUnlock()
+		return dk, nil
+	}
+	kr.lock.RUnlock()
+
+	kr.lock.Lock()
+	defer kr.lock.Unlock()
+	// someone may have rotated while we waited for the lock
+	if valid() {
+		return kr.dataKeys[kr.nextKeyID-1], nil
+	}
+	key := make([]byte, len(kr.opt.EncryptionKey))
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	iv, err := utils.GenerateIV()
+	if err != nil {
+		return nil, err
+	}
+	dk := &pb.DataKey{
+		KeyId:     kr.nextKeyID,
+		Data:      key,
+		Iv:        iv,
+		CreatedAt: now.Unix(),
+	}
+	if err := kr.store(dk); err != nil {
+		return nil, err
+	}
+	kr.add(dk)
+	return dk, nil
+}
+
+// Close closes the registry file
+func (kr *KeyRegistry) Close() error {
+	if kr.fd == nil {
+		return nil
+	}
+	return kr.fd.Close()
+}
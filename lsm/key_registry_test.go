@@ -0,0 +1,64 @@
+package lsm
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"TLKV/utils"
+)
+
+func TestKeyRegistryRotation(t *testing.T) {
+	if d := DefaultOptions("").EncryptionKeyRotationDuration; d != 10*24*time.Hour {
+		t.Fatalf("default rotation %v", d)
+	}
+	opt, clock := testDBOptions(t)
+	opt.EncryptionKey = bytes.Repeat([]byte{1}, 16)
+	opt.EncryptionKeyRotationDuration = time.Hour
+	kr, err := OpenKeyRegistry(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := func() uint64 {
+		t.Helper()
+		dk, err := kr.LatestDataKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dk.KeyId
+	}
+	if id := latest(); id != 1 {
+		t.Fatalf("first key %d", id)
+	}
+	clock.Advance(59 * time.Minute)
+	if id := latest(); id != 1 {
+		t.Fatalf("rotated early to %d", id)
+	}
+	clock.Advance(time.Minute)
+	if id := latest(); id != 2 {
+		t.Fatalf("key %d after an hour, want 2", id)
+	}
+	kr.Close()
+
+	// 0 never rotates, the keys survive a reopen
+	opt.EncryptionKeyRotationDuration = 0
+	if kr, err = OpenKeyRegistry(opt); err != nil {
+		t.Fatal(err)
+	}
+	defer kr.Close()
+	clock.Advance(1000 * 24 * time.Hour)
+	if id := latest(); id != 2 {
+		t.Fatalf("key %d with rotation off, want 2", id)
+	}
+	if dk, err := kr.DataKey(1); err != nil || dk.KeyId != 1 {
+		t.Fatalf("old key: %+v %v", dk, err)
+	}
+	if _, err := kr.DataKey(3); err == nil {
+		t.Fatal("unknown key id found")
+	}
+
+	opt.EncryptionKey = bytes.Repeat([]byte{2}, 16)
+	if _, err := OpenKeyRegistry(opt); err != utils.ErrEncryptionKeyMismatch {
+		t.Fatalf("wrong master key: %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+package lsm
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"time"
+
+	"TLKV/file"
+	"TLKV/pb"
+	"TLKV/utils"
+)
+
+// levelManager holds the tables of every level of a column family and picks
+// what to compact
+type levelManager struct {
+	opt *Options
+	tc  *tableCache
+	cf  uint32
+	// levels are the table ids of each level, level 0 newest first. They are
+	// replaced, never changed in place, so readers may keep the slices they
+	// got under DB.lock
+	levels [][]uint64
+	// expired are the tables the last TTL look found expired, not compacted
+	// yet, guarded by DB.compactLock
+	expired []uint64
+}
+
+// newLevelManager places the tables of family cf in the manifest m in their
+// levels. Level 0 tables are ordered by fid, a flush always takes a bigger
+// one.
+func newLevelManager(opt *Options, tc *tableCache, m *file.Manifest, cf uint32) (*levelManager, error) {
+	lm := &levelManager{opt: opt, tc: tc, cf: cf, levels: make([][]uint64, opt.MaxLevelNum)}
+	for fid, tm := range m.Tables {
+		if tm.ColumnFamily != cf {
+			continue
+		}
+		if int(tm.Level) >= len(lm.levels) {
+			return nil, utils.ErrInvalidRequest
+		}
+		lm.levels[tm.Level] = append(lm.levels[tm.Level], fid)
+	}
+	l0 := lm.levels[0]
+	for i := 1; i < len(l0); i++ {
+		for j := i; j > 0 && l0[j] > l0[j-1]; j-- {
+			l0[j], l0[j-1] = l0[j-1], l0[j]
+		}
+	}
+	return lm, nil
+}
+
+// targets returns the target size of each level past 0, BaseLevelSize for
+// level 1 and LevelSizeMultiplier times more for each level below
+func (lm *levelManager) targets() []int64 {
+	targets := make([]int64, lm.opt.MaxLevelNum)
+	size := lm.opt.BaseLevelSize
+	for l := 1; l < len(targets); l++ {
+		targets[l] = size
+		size *= int64(lm.opt.LevelSizeMultiplier)
+	}
+	return targets
+}
+
+// maxVersion returns the newest version stored in the tables
+func (lm *levelManager) maxVersion() (uint64, error) {
+	var version uint64
+	for _, fids := range lm.levels {
+		for _, fid := range fids {
+			t, err := lm.tc.acquire(fid)
+			if err != nil {
+				return 0, err
+			}
+			index, err := t.getIndex()
+			lm.tc.release(t)
+			if err != nil {
+				return 0, err
+			}
+			version = max(version, index.GetMaxVersion())
+		}
+	}
+	return version, nil
+}
+
+// pickCompaction plans the compaction of the level most due for one, nil if
+// none is. Level 0 goes to level 1 along with the level 1 tables it
+// overlaps. Other levels move their stalest table down, or the oldest one if
+// none holds stale data. The last level is only compacted in place to drop
+// stale data.
+func (lm *levelManager) pickCompaction(levels [][]uint64, discardTs uint64) (*compactDef, error) {
+	prios, err := lm.tc.compactionPriorities(levels, lm.targets())
+	if err != nil {
+		return nil, err
+	}
+	last := len(levels) - 1
+	for _, p := range prios {
+		if p.adjusted < 1 {
+			break
+		}
+		switch {
+		case len(levels[p.level]) == 0:
+			continue
+		case p.level == 0:
+			start, end, err := lm.levelRange(levels[0])
+			if err != nil {
+				return nil, err
+			}
+			return lm.tc.planCompactRange(levels, start, end, 0, 1, discardTs)
+		case p.level == last:
+			if p.staleRatio == 0 {
+				continue
+			}
+			kr, err := lm.tc.tableRange(p.stalest)
+			if err != nil {
+				return nil, err
+			}
+			return lm.tc.planCompactRange(levels, kr.smallest, keyAfter(kr.biggest), last, last, discardTs)
+		default:
+			fid := p.stalest
+			if p.staleRatio == 0 {
+				fid = oldest(levels[p.level])
+			}
+			return lm.tc.planSeekCompaction(levels, p.level, fid, discardTs)
+		}
+	}
+	return nil, nil
+}
+
+// pickSeekCompaction plans the compaction of the first table out of seek
+// budget into the next level, nil if there is none. Tables of the last
+// level have nowhere to go and are dropped from the queue, the ones after
+// the table picked stay queued.
+func (lm *levelManager) pickSeekCompaction(levels [][]uint64, discardTs uint64) (*compactDef, error) {
+	fids := lm.tc.takeSeekCompactions()
+	for i, fid := range fids {
+		level := tableLevel(levels, fid)
+		if level < 0 || level == len(levels)-1 {
+			continue
+		}
+		lm.tc.requeueSeekCompactions(fids[i+1:])
+		return lm.tc.planSeekCompaction(levels, level, fid, discardTs)
+	}
+	return nil, nil
+}
+
+// tableLevel returns the level of levels holding fid, -1 if none does
+func tableLevel(levels [][]uint64, fid uint64) int {
+	for l, fids := range levels {
+		for _, f := range fids {
+			if f == fid {
+				return l
+			}
+		}
+	}
+	return -1
+}
+
+// findExpired queues the tables whose entries have all expired by now, by
+// the MaxExpiresAt of their index, the first to expire first. Tables holding
+// versions above discardTs are left out, compaction has to keep those.
+func (lm *levelManager) findExpired(levels [][]uint64, now time.Time, discardTs uint64) error {
+	infos, err := lm.tc.tableInfos(levels)
+	if err != nil {
+		return err
+	}
+	var expired []TableInfo
+	for _, ti := range infos {
+		if ti.MaxExpiresAt == 0 || ti.MaxExpiresAt > uint64(now.Unix()) || ti.MaxVersion > discardTs {
+			continue
+		}
+		expired = append(expired, ti)
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].MaxExpiresAt < expired[j].MaxExpiresAt })
+	lm.expired = lm.expired[:0]
+	for _, ti := range expired {
+		lm.expired = append(lm.expired, ti.ID)
+	}
+	return nil
+}
+
+// pickExpiredCompaction plans the compaction of the next table queued by
+// findExpired, nil once the queue is empty. Every table is tried once per
+// look, so a table compaction can't clean is not rewritten over and over.
+func (lm *levelManager) pickExpiredCompaction(levels [][]uint64, discardTs uint64) (*compactDef, error) {
+	for len(lm.expired) > 0 {
+		fid := lm.expired[0]
+		lm.expired = lm.expired[1:]
+		level := tableLevel(levels, fid)
+		if level < 0 {
+			// compacted since the look
+			continue
+		}
+		cd, err := lm.planExpiredCompaction(levels, level, fid, discardTs)
+		if err != nil {
+			return nil, err
+		}
+		// above the bottom the expired entries would only be written again
+		if cd.bottommost && len(cd.inputs) > 0 {
+			return cd, nil
+		}
+	}
+	return nil, nil
+}
+
+// planExpiredCompaction plans the compaction of the expired table fid of
+// level down to the deepest level holding its keys, where the expired
+// entries are dropped along with the older versions they shadow.
+func (lm *levelManager) planExpiredCompaction(levels [][]uint64, level int, fid uint64, discardTs uint64) (*compactDef, error) {
+	kr, err := lm.tc.tableRange(fid)
+	if err != nil {
+		return nil, err
+	}
+	start, end := kr.smallest, keyAfter(kr.biggest)
+	target := max(level, 1)
+	for l := target + 1; l < len(levels); l++ {
+		for _, fid := range levels[l] {
+			r, err := lm.tc.tableRange(fid)
+			if err != nil {
+				return nil, err
+			}
+			if r.overlaps(start, end) {
+				target = l
+				break
+			}
+		}
+	}
+	return lm.tc.planCompactRange(levels, start, end, level, target, discardTs)
+}
+
+// levelRange returns the user key range [start, end) holding the tables fids
+func (lm *levelManager) levelRange(fids []uint64) (start, end []byte, err error) {
+	for i, fid := range fids {
+		kr, err := lm.tc.tableRange(fid)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i == 0 || bytes.Compare(kr.smallest, start) < 0 {
+			start = kr.smallest
+		}
+		if i == 0 || bytes.Compare(kr.biggest, end) >= 0 {
+			end = keyAfter(kr.biggest)
+		}
+	}
+	return start, end, nil
+}
+
+// keyAfter returns the smallest user key after key
+func keyAfter(key []byte) []byte {
+	return append(utils.SafeCopy(nil, key), 0)
+}
+
+// oldest returns the table written first, the one with the smallest fid
+func oldest(fids []uint64) uint64 {
+	fid := fids[0]
+	for _, f := range fids[1:] {
+		fid = min(fid, f)
+	}
+	return fid
+}
+
+// applyCompaction returns levels with the inputs of cd replaced by outputs,
+// written to cd.targetLevel. It only copies the levels that change.
+func applyCompaction(levels [][]uint64, cd *compactDef, outputs []uint64) [][]uint64 {
+	removed := make(map[uint64]bool, len(cd.inputs))
+	for _, fid := range cd.inputs {
+		removed[fid] = true
+	}
+	next := make([][]uint64, len(levels))
+	for l, fids := range levels {
+		next[l] = fids
+		changed := l == cd.targetLevel
+		for _, fid := range fids {
+			changed = changed || removed[fid]
+		}
+		if !changed {
+			continue
+		}
+		var kept []uint64
+		if l == cd.targetLevel {
+			// level 0 outputs are newer than what is left, the rest is disjoint
+			kept = append(kept, outputs...)
+		}
+		for _, fid := range fids {
+			if !removed[fid] {
+				kept = append(kept, fid)
+			}
+		}
+		next[l] = kept
+	}
+	return next
+}
+
+// compactionChanges records a compaction in the manifest
+func (lm *levelManager) compactionChanges(cd *compactDef, outputs []uint64) []*pb.ManifestChange {
+	changes := make([]*pb.ManifestChange, 0, len(outputs)+len(cd.inputs))
+	for _, fid := range outputs {
+		mc := file.NewCreateChange(fid, cd.targetLevel)
+		mc.ColumnFamily = lm.cf
+		changes = append(changes, mc)
+	}
+	for _, fid := range cd.inputs {
+		changes = append(changes, file.NewDeleteChange(fid))
+	}
+	return changes
+}
+
+// removeTables deletes the files of tables no level refers to
+func (lm *levelManager) removeTables(fids []uint64) {
+	for _, fid := range fids {
+		lm.tc.remove(fid)
+		// a leftover file is deleted on the next Open
+		_ = os.Remove(utils.FileNameSSTable(lm.opt.WorkDir, fid))
+	}
+}
@@ -1,14 +1,66 @@
 package lsm
 
+import (
+	"time"
+
+	"TLKV/utils"
+)
+
 // Options _
 type Options struct {
 	WorkDir      string
 	MemTableSize int64
 	SSTableMaxSz int64
+	// MemTableBloomBitsPerKey gives every memtable a bloom filter checked
+	// before its skiplist is searched, 0 disables it
+	MemTableBloomBitsPerKey int
 	// BlockSize is the size of each block inside SSTable in bytes
 	BlockSize int
+	// BlockRestartInterval is the number of keys between restart points, which
+	// store the whole key instead of the part that differs from the previous one
+	BlockRestartInterval int
+	// BlockHashIndex adds a hash index from user key to restart interval to
+	// every block, point lookups then skip the binary search
+	BlockHashIndex bool
 	// BloomFalsePositive is the false positive probability of bloom filter
 	BloomFalsePositive float64
+	// FilterPolicies is the filter of each level like BlockCompression, for
+	// example blocked bloom on top and xor at the bottom. Empty means a bloom
+	// filter sized by BloomFalsePositive
+	FilterPolicies []utils.FilterPolicy
+	// PrefixExtractor adds the prefix of every key to the table filters so
+	// prefix scans skip tables without the prefix, nil disables it
+	PrefixExtractor utils.PrefixExtractor
+	// BlockCacheSize is the memory in bytes for decoded blocks, 0 disables it
+	BlockCacheSize int64
+	// IndexCacheSize moves table indexes and their bloom filters out of the
+	// tables into a cache of this many bytes, where they are kept with higher
+	// priority than blocks. 0 pins every index in memory
+	IndexCacheSize int64
+	// IndexPartitionSize splits the index and bloom filter of each table into
+	// partitions of about this many bytes, loaded on demand. 0 keeps a single
+	// index that is decoded when the table opens
+	IndexPartitionSize int
+	// MaxOpenTables bounds the tables kept open and mmapped, idle ones are
+	// closed first. 0 keeps every table open
+	MaxOpenTables int
+	// BlockCompression is the codec used for blocks of each level, levels
+	// past the end of the slice use its last entry. Empty means no compression
+	BlockCompression []utils.CompressionType
+
+	// NumMemtables is the number of full memtables that may wait for their
+	// flush, writes stall once it is reached
+	NumMemtables int
+	// SyncWrites syncs the WAL before a write returns
+	SyncWrites bool
+	// ValueThreshold moves values of at least this many bytes, of every
+	// family, out of the LSM tree into the value log; the tree keeps a
+	// pointer to them. 0, the default, keeps every value in the tree: the
+	// value log has no GC, overwritten values keep their space
+	ValueThreshold int
+	// ValueLogFileSize is the size a value log file grows to before the next
+	// one is started
+	ValueLogFileSize int64
 
 	// compact
 	NumCompactors       int
@@ -18,4 +70,158 @@ type Options struct {
 	BaseTableSize       int64
 	NumLevelZeroTables  int
 	MaxLevelNum         int
+	// NumLevelZeroTablesStall stalls writes while level 0 has this many
+	// tables, 0 disables it
+	NumLevelZeroTablesStall int
+
+	// StaleDataCompactionRatio makes a level due for compaction as soon as one
+	// of its tables has this share of stale versions, 0 disables it
+	StaleDataCompactionRatio float64
+	// TTLCompactionInterval is how often the compactor looks for tables whose
+	// entries have all expired and compacts them away, 0 disables it
+	TTLCompactionInterval time.Duration
+	// SeekCompactionBytesPerSeek allows a table one lookup that finds nothing
+	// in it per this many bytes of its size, then it is compacted into the
+	// next level. 0 means 16KB, a negative value disables seek compaction
+	SeekCompactionBytesPerSeek int64
+
+	// ScanRetention keeps the versions overwritten within this long, so Scan
+	// tokens reading at them stay valid; older tokens fail with
+	// ErrScanTokenExpired. 0 keeps only the newest version. Write times are
+	// not persisted, after Open every older version counts as just written
+	ScanRetention time.Duration
+
+	// Clock is used for TTLs and versions, nil means the system clock
+	Clock utils.Clock
+
+	// MergeOperator folds entries written by Merge, required if Merge is used
+	MergeOperator MergeOperator
+
+	// CompactionFilter drops or rewrites entries during compaction, nil keeps
+	// them. See CompactionFilter for the entries it is shown.
+	CompactionFilter CompactionFilter
+
+	// EncryptionKey is the AES master key (16, 24 or 32 bytes) sealing the
+	// data keys, empty disables encryption at rest
+	EncryptionKey []byte
+	// EncryptionKeyRotationDuration is how long a data key encrypts new files
+	// before the next one is created, 0 never rotates it
+	EncryptionKeyRotationDuration time.Duration
+}
+
+// DefaultOptions returns the options for a DB in dir, Open takes the sizes
+// left at 0 from them
+func DefaultOptions(dir string) *Options {
+	return &Options{
+		WorkDir:                 dir,
+		MemTableSize:            64 << 20,
+		SSTableMaxSz:            64 << 20,
+		BlockSize:               4 << 10,
+		BloomFalsePositive:      0.01,
+		NumMemtables:            5,
+		ValueLogFileSize:        1 << 30,
+		NumCompactors:           1,
+		BaseLevelSize:           10 << 20,
+		LevelSizeMultiplier:     10,
+		TableSizeMultiplier:     2,
+		BaseTableSize:           2 << 20,
+		NumLevelZeroTables:      5,
+		NumLevelZeroTablesStall: 15,
+		MaxLevelNum:             utils.MaxLevelNum,
+		TTLCompactionInterval:   time.Hour,
+		ScanRetention:           10 * time.Minute,
+
+		EncryptionKeyRotationDuration: 10 * 24 * time.Hour,
+		SeekCompactionBytesPerSeek:    16 << 10,
+	}
+}
+
+// withDefaults returns a copy of opt with the sizes a DB can't run without
+// taken from DefaultOptions when they are 0. Options where 0 disables a
+// feature are left alone.
+func (opt *Options) withDefaults() *Options {
+	def := DefaultOptions(opt.WorkDir)
+	out := *opt
+	if out.MemTableSize <= 0 {
+		out.MemTableSize = def.MemTableSize
+	}
+	if out.SSTableMaxSz <= 0 {
+		out.SSTableMaxSz = def.SSTableMaxSz
+	}
+	if out.BlockSize <= 0 {
+		out.BlockSize = def.BlockSize
+	}
+	if out.ValueLogFileSize <= 0 {
+		out.ValueLogFileSize = def.ValueLogFileSize
+	}
+	if out.NumMemtables <= 0 {
+		out.NumMemtables = def.NumMemtables
+	}
+	if out.LevelSizeMultiplier <= 0 {
+		out.LevelSizeMultiplier = def.LevelSizeMultiplier
+	}
+	if out.MaxLevelNum < 2 {
+		out.MaxLevelNum = def.MaxLevelNum
+	}
+	if out.SeekCompactionBytesPerSeek == 0 {
+		out.SeekCompactionBytesPerSeek = def.SeekCompactionBytesPerSeek
+	}
+	return &out
+}
+
+func (opt *Options) compressionFor(level int) utils.CompressionType {
+	if len(opt.BlockCompression) == 0 {
+		return utils.NoCompression
+	}
+	if level >= len(opt.BlockCompression) {
+		level = len(opt.BlockCompression) - 1
+	}
+	return opt.BlockCompression[level]
+}
+
+// filterPolicyFor returns nil when tables of level get no filter
+func (opt *Options) filterPolicyFor(level int) utils.FilterPolicy {
+	if len(opt.FilterPolicies) == 0 {
+		if opt.BloomFalsePositive <= 0 {
+			return nil
+		}
+		// BloomBitsPerKey doesn't depend on the number of keys
+		return utils.NewBloomPolicy(utils.BloomBitsPerKey(1, opt.BloomFalsePositive))
+	}
+	if level >= len(opt.FilterPolicies) {
+		level = len(opt.FilterPolicies) - 1
+	}
+	return opt.FilterPolicies[level]
+}
+
+// filterPrefix returns the extracted prefix a scan over scanPrefix can test
+// filters with, nil if the scan prefix is too short to have one
+func (opt *Options) filterPrefix(scanPrefix []byte) []byte {
+	pe := opt.PrefixExtractor
+	if pe == nil || !pe.InDomain(scanPrefix) {
+		return nil
+	}
+	return pe.Transform(scanPrefix)
+}
+
+// newSkipList creates the skiplist of a new memtable
+func (opt *Options) newSkipList() *utils.Skiplist {
+	if opt.MemTableBloomBitsPerKey <= 0 {
+		return utils.NewSkipList(opt.MemTableSize)
+	}
+	return utils.NewSkipListWithFilter(opt.MemTableSize, opt.MemTableBloomBitsPerKey, opt.PrefixExtractor)
+}
+
+func (opt *Options) restartInterval() int {
+	if opt.BlockRestartInterval <= 0 {
+		return 16
+	}
+	return opt.BlockRestartInterval
+}
+
+func (opt *Options) clock() utils.Clock {
+	if opt.Clock == nil {
+		return utils.SystemClock
+	}
+	return opt.Clock
 }
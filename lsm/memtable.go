@@ -0,0 +1,178 @@
+package lsm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"TLKV/file"
+	"TLKV/utils"
+)
+
+// memTable is a skiplist for every column family taking writes and the WAL
+// they are all logged to first. Once full it becomes immutable and each
+// skiplist is flushed to a level 0 table of its family, then the WAL is
+// deleted.
+type memTable struct {
+	wal *file.WalFile
+	// sls are the skiplists of the families written to, by family id. They
+	// are added under DB.lock.
+	sls map[uint32]*utils.Skiplist
+	// reserved is the arena taken in each skiplist by the entries added so
+	// far if all their nodes were as tall as possible. Node heights are
+	// random, so this and not the arena in use decides whether the WAL can
+	// be replayed into memtables of the same size.
+	reserved map[uint32]int64
+}
+
+// newMemTable creates a memtable with a new WAL fid, encrypted with the
+// latest of keys
+func newMemTable(opt *Options, keys file.DataKeys, fid uint64) (*memTable, error) {
+	wal, err := file.OpenWalFile(&file.Options{FID: fid, Dir: opt.WorkDir, Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	return &memTable{wal: wal, sls: make(map[uint32]*utils.Skiplist), reserved: make(map[uint32]int64)}, nil
+}
+
+// arenaSize is the most adding e can take from the arena of a skiplist: the
+// tallest node, its key and its value
+func arenaSize(e *utils.Entry) int64 {
+	return int64(utils.MaxNodeSize + 8 + len(e.Key) + int(e.EncodedSize()))
+}
+
+// batchSizes returns the arena size entries take in the skiplist of each
+// family, size gives the size of one entry
+func batchSizes(entries []*utils.Entry, size func(e *utils.Entry) int64) map[uint32]int64 {
+	sizes := make(map[uint32]int64, 1)
+	for _, e := range entries {
+		sizes[e.ColumnFamily] += size(e)
+	}
+	return sizes
+}
+
+// skiplist returns the skiplist of family cf, nil if it has none
+func (mt *memTable) skiplist(cf uint32) *utils.Skiplist {
+	return mt.sls[cf]
+}
+
+// fits reports whether entries of the arena sizes, by family, can still be
+// added. It creates the skiplists of the families not written to before,
+// DB.lock is held.
+func (mt *memTable) fits(cfs *columnFamilies, sizes map[uint32]int64) bool {
+	for id, sz := range sizes {
+		cf, err := cfs.getByID(id)
+		if err != nil {
+			// the write checked its families, this is only a replay
+			continue
+		}
+		sl, ok := mt.sls[id]
+		if !ok {
+			sl = cf.Opt.newSkipList()
+			mt.sls[id] = sl
+			mt.reserved[id] = sl.MemSize()
+		}
+		if mt.reserved[id]+sz > cf.Opt.MemTableSize {
+			return false
+		}
+	}
+	return true
+}
+
+// empty reports whether no entry was added yet
+func (mt *memTable) empty() bool {
+	for _, sl := range mt.sls {
+		if !sl.Empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds the entries of arena sizes, their skiplists were made by fits
+func (mt *memTable) add(entries []*utils.Entry, sizes map[uint32]int64) {
+	for id, sz := range sizes {
+		mt.reserved[id] += sz
+	}
+	for _, e := range entries {
+		mt.sls[e.ColumnFamily].Add(e)
+	}
+}
+
+// decrRef releases the skiplists
+func (mt *memTable) decrRef() {
+	for _, sl := range mt.sls {
+		sl.DecrRef()
+	}
+}
+
+// close releases the memtable without deleting its WAL
+func (mt *memTable) close() error {
+	mt.decrRef()
+	return mt.wal.Close()
+}
+
+// replayMemTables rebuilds the memtables of the WALs left in the work dir,
+// oldest first. Entries of version 1 WALs still mark deletes with a nil
+// value, they are turned into tombstones; entries of families dropped since
+// are skipped. It returns the newest version replayed and the biggest WAL
+// fid.
+func replayMemTables(opt *Options, keys file.DataKeys, cfs *columnFamilies) ([]*memTable, uint64, uint64, error) {
+	fids, err := walFids(opt.WorkDir)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var mts []*memTable
+	var version, maxFid uint64
+	fail := func(err error) ([]*memTable, uint64, uint64, error) {
+		for _, mt := range mts {
+			mt.close()
+		}
+		return nil, 0, 0, err
+	}
+	for _, fid := range fids {
+		wal, err := file.OpenWalFile(&file.Options{FID: fid, Dir: opt.WorkDir, Keys: keys})
+		if err != nil {
+			return fail(err)
+		}
+		mt := &memTable{wal: wal, sls: make(map[uint32]*utils.Skiplist), reserved: make(map[uint32]int64)}
+		mts = append(mts, mt)
+		maxFid = max(maxFid, fid)
+		err = wal.Iterate(func(e *utils.Entry) error {
+			version = max(version, utils.ParseTs(e.Key))
+			if _, err := cfs.getByID(e.ColumnFamily); err != nil {
+				return nil
+			}
+			if e.Value == nil && e.Meta&(utils.BitValuePointer|utils.BitMerge) == 0 {
+				e.Meta |= utils.BitDelete
+			}
+			batch := []*utils.Entry{e}
+			sizes := batchSizes(batch, arenaSize)
+			if !mt.fits(cfs, sizes) {
+				return fmt.Errorf("wal %d doesn't fit into the memtables of its column families", fid)
+			}
+			mt.add(batch, sizes)
+			return nil
+		})
+		if err != nil {
+			return fail(err)
+		}
+	}
+	return mts, version, maxFid, nil
+}
+
+// walFids returns the fids of the WALs in dir in ascending order
+func walFids(dir string) ([]uint64, error) {
+	infos, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var fids []uint64
+	for _, info := range infos {
+		if fid := utils.WalFID(info.Name()); fid != 0 {
+			fids = append(fids, fid)
+		}
+	}
+	sort.Slice(fids, func(i, j int) bool { return fids[i] < fids[j] })
+	return fids, nil
+}
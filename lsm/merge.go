@@ -0,0 +1,83 @@
+package lsm
+
+import (
+	"time"
+
+	"TLKV/utils"
+)
+
+// MergeOperator folds merge operands into a value so read-modify-write
+// updates (counters, appends) don't have to read the key first.
+type MergeOperator interface {
+	// Name identifies the operator, data written with one operator has to be
+	// read with the same one
+	Name() string
+	// FullMerge applies operands, oldest first, on top of existing. existing is
+	// nil when the key has no base value or it was deleted
+	FullMerge(key, existing []byte, operands [][]byte) ([]byte, error)
+	// PartialMerge combines two adjacent operands, left being the older one.
+	// It returns false if they can only be merged against a base value
+	PartialMerge(key, left, right []byte) ([]byte, bool)
+}
+
+// mergeEntries folds the versions of one key, sorted from newest to oldest.
+// Operands are collected until a base value, a tombstone or the end of the
+// versions is reached. With full set (reads, bottommost compactions) they are
+// applied with FullMerge and a single plain value is returned, otherwise
+// adjacent operands are combined with PartialMerge and the remaining operands
+// are returned followed by the base version if there is one. A base value in
+// the value log is read from vlog.
+func mergeEntries(op MergeOperator, vlog *valueLog, versions []*utils.Entry, full bool, now time.Time) ([]*utils.Entry, error) {
+	if len(versions) == 0 || versions[0].Meta&utils.BitMerge == 0 {
+		return versions, nil
+	}
+	if op == nil {
+		return nil, utils.ErrNoMergeOperator
+	}
+	key := utils.ParseKey(versions[0].Key)
+
+	var operands []*utils.Entry
+	var base *utils.Entry
+	for _, e := range versions {
+		if e.Meta&utils.BitMerge == 0 {
+			base = e
+			break
+		}
+		operands = append(operands, e)
+	}
+
+	if full || base != nil {
+		var existing []byte
+		if base != nil && !base.IsDeletedOrExpiredAt(now) {
+			var err error
+			if existing, err = vlog.value(base); err != nil {
+				return nil, err
+			}
+		}
+		vals := make([][]byte, 0, len(operands))
+		for i := len(operands) - 1; i >= 0; i-- {
+			vals = append(vals, operands[i].Value)
+		}
+		val, err := op.FullMerge(key, existing, vals)
+		if err != nil {
+			return nil, err
+		}
+		return []*utils.Entry{{Key: versions[0].Key, Value: val}}, nil
+	}
+
+	// no base value below us yet, shrink the operand run as far as possible
+	out := []*utils.Entry{operands[len(operands)-1]}
+	for i := len(operands) - 2; i >= 0; i-- {
+		last := out[len(out)-1]
+		if val, ok := op.PartialMerge(key, last.Value, operands[i].Value); ok {
+			out[len(out)-1] = &utils.Entry{Key: operands[i].Key, Value: val, Meta: utils.BitMerge}
+			continue
+		}
+		out = append(out, operands[i])
+	}
+	// back to newest first
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+package lsm
+
+import (
+	"container/heap"
+
+	"TLKV/utils"
+)
+
+// mergeIterator merges ascending iterators over internal keys into one. When
+// several hold the same internal key, the one listed first wins and the
+// others skip it, so newer sources have to come first.
+type mergeIterator struct {
+	iters []utils.Iterator
+	h     mergeHeap
+}
+
+// mergeHeap orders the valid iterators by their key, then by their position
+type mergeHeap struct {
+	iters []utils.Iterator
+	idx   []int
+}
+
+func (h *mergeHeap) Len() int { return len(h.idx) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.idx[i], h.idx[j]
+	if cmp := utils.CompareKeys(h.iters[a].Item().Entry().Key, h.iters[b].Item().Entry().Key); cmp != 0 {
+		return cmp < 0
+	}
+	return a < b
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.idx[i], h.idx[j] = h.idx[j], h.idx[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.idx = append(h.idx, x.(int)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	x := h.idx[len(h.idx)-1]
+	h.idx = h.idx[:len(h.idx)-1]
+	return x
+}
+
+func newMergeIterator(iters []utils.Iterator) *mergeIterator {
+	return &mergeIterator{iters: iters, h: mergeHeap{iters: iters}}
+}
+
+func (mi *mergeIterator) init() {
+	mi.h.idx = mi.h.idx[:0]
+	for i, itr := range mi.iters {
+		if itr.Valid() {
+			mi.h.idx = append(mi.h.idx, i)
+		}
+	}
+	heap.Init(&mi.h)
+}
+
+func (mi *mergeIterator) Rewind() {
+	for _, itr := range mi.iters {
+		itr.Rewind()
+	}
+	mi.init()
+}
+
+func (mi *mergeIterator) Seek(key []byte) {
+	for _, itr := range mi.iters {
+		itr.Seek(key)
+	}
+	mi.init()
+}
+
+func (mi *mergeIterator) Valid() bool { return len(mi.h.idx) > 0 }
+
+func (mi *mergeIterator) Item() utils.Item { return mi.iters[mi.h.idx[0]].Item() }
+
+// Next moves past the current key in every iterator holding it
+func (mi *mergeIterator) Next() {
+	key := utils.SafeCopy(nil, mi.Item().Entry().Key)
+	for len(mi.h.idx) > 0 {
+		top := mi.iters[mi.h.idx[0]]
+		if utils.CompareKeys(top.Item().Entry().Key, key) != 0 {
+			return
+		}
+		top.Next()
+		if top.Valid() {
+			heap.Fix(&mi.h, 0)
+		} else {
+			heap.Pop(&mi.h)
+		}
+	}
+}
+
+func (mi *mergeIterator) Close() error {
+	var err error
+	for _, itr := range mi.iters {
+		if cerr := itr.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+package lsm
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"TLKV/utils"
+)
+
+// appendOperator joins operands with commas
+type appendOperator struct{}
+
+func (appendOperator) Name() string { return "append" }
+
+func (appendOperator) FullMerge(key, existing []byte, operands [][]byte) ([]byte, error) {
+	parts := operands
+	if existing != nil {
+		parts = append([][]byte{existing}, operands...)
+	}
+	return bytes.Join(parts, []byte(",")), nil
+}
+
+func (appendOperator) PartialMerge(key, left, right []byte) ([]byte, bool) {
+	return append(append(append([]byte{}, left...), ','), right...), true
+}
+
+func TestMergeEntries(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	k := func(ts uint64) []byte { return utils.KeyWithTs([]byte("k"), ts) }
+	versions := []*utils.Entry{
+		{Key: k(4), Value: []byte("d"), Meta: utils.BitMerge},
+		{Key: k(3), Value: []byte("c"), Meta: utils.BitMerge},
+		{Key: k(2), Value: []byte("b")},
+		{Key: k(1), Value: []byte("a")},
+	}
+	out, err := mergeEntries(appendOperator{}, nil, versions, false, now)
+	if err != nil || len(out) != 1 || string(out[0].Value) != "b,c,d" || out[0].Meta != 0 {
+		t.Fatalf("fold onto a base: %+v %v", out, err)
+	}
+	// no base: partial merges keep an operand
+	out, err = mergeEntries(appendOperator{}, nil, versions[:2], false, now)
+	if err != nil || len(out) != 1 || string(out[0].Value) != "c,d" || out[0].Meta != utils.BitMerge {
+		t.Fatalf("partial merge: %+v %v", out, err)
+	}
+	out, err = mergeEntries(appendOperator{}, nil, versions[:2], true, now)
+	if err != nil || len(out) != 1 || string(out[0].Value) != "c,d" || out[0].Meta != 0 {
+		t.Fatalf("full merge without base: %+v %v", out, err)
+	}
+	// a tombstone is no base value
+	tomb := []*utils.Entry{versions[0], {Key: k(3), Meta: utils.BitDelete}}
+	if out, err = mergeEntries(appendOperator{}, nil, tomb, true, now); err != nil || string(out[0].Value) != "d" {
+		t.Fatalf("merge over a tombstone: %+v %v", out, err)
+	}
+	if _, err := mergeEntries(nil, nil, versions, true, now); err != utils.ErrNoMergeOperator {
+		t.Fatalf("no operator: %v", err)
+	}
+}
+
+func TestDBMerge(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	if err := db.Merge([]byte("k"), []byte("x")); err != utils.ErrNoMergeOperator {
+		t.Fatalf("merge without operator: %v", err)
+	}
+	db.Close()
+
+	opt.MergeOperator = appendOperator{}
+	db = openTestDB(t, opt)
+	if err := db.Set(utils.NewEntry([]byte("list"), []byte("a"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge([]byte("list"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	// base and operands spread over two levels and the memtables
+	compactDB(t, db, 1)
+	for _, op := range []string{"c", "d"} {
+		if err := db.Merge([]byte("list"), []byte(op)); err != nil {
+			t.Fatal(err)
+		}
+		flushDB(t, db)
+	}
+	if err := db.Merge([]byte("list"), []byte("e")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge([]byte("list"), []byte("f")); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "list", "a,b,c,d,e,f")
+
+	// operands without a base
+	if err := db.Merge([]byte("fresh"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "fresh", "1")
+
+	// a delete resets the list
+	if err := db.Del([]byte("list")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge([]byte("list"), []byte("z")); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "list", "z")
+
+	itr, err := db.NewIterator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		e := itr.Item().Entry()
+		got = append(got, fmt.Sprintf("%s=%s", e.Key, e.Value))
+	}
+	itr.Close()
+	if fmt.Sprint(got) != "[fresh=1 list=z]" {
+		t.Fatalf("iterated %v", got)
+	}
+
+	// compaction folds the operands for good
+	compactDB(t, db, 2)
+	mustGet(t, db, "list", "z")
+	mustGet(t, db, "fresh", "1")
+}
+
+func TestTableCacheMultiGetFoldsMerges(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	opt.MergeOperator = appendOperator{}
+	db := openTestDB(t, opt)
+	for i := 0; i < 10; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%d", i)), []byte("base"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	compactDB(t, db, 1)
+	for i := 0; i < 10; i += 2 {
+		if err := db.Merge([]byte(fmt.Sprintf("k%d", i)), []byte("op")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	flushDB(t, db)
+	readTs := db.discardTs()
+	keys := [][]byte{
+		utils.KeyWithTs([]byte("k2"), readTs), utils.KeyWithTs([]byte("k1"), readTs),
+		utils.KeyWithTs([]byte("k0"), readTs), utils.KeyWithTs([]byte("zz"), readTs),
+	}
+	db.lock.RLock()
+	entries, errs := db.def.lm.tc.multiGet(db.def.lm.levels, keys)
+	db.lock.RUnlock()
+	want := []string{"base,op", "base", "base,op", ""}
+	for i, w := range want {
+		if w == "" {
+			if errs[i] != utils.ErrKeyNotFound {
+				t.Fatalf("key %d: %v", i, errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil || string(entries[i].Value) != w || entries[i].Meta&utils.BitMerge != 0 {
+			t.Fatalf("key %d: %+v %v, want %q", i, entries[i], errs[i], w)
+		}
+	}
+}
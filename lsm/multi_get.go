@@ -0,0 +1,286 @@
+package lsm
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"TLKV/utils"
+)
+
+// MultiGet looks up keys in the default family like Get, all at the same
+// version. The memtables are probed first, the keys they don't settle are
+// looked up in every level in parallel, reading each block once. Results and
+// errors are in the order of keys.
+func (db *DB) MultiGet(keys [][]byte) ([]*utils.Entry, []error) {
+	return db.multiGet(db.def, keys, db.readTs())
+}
+
+func (db *DB) multiGet(cf *ColumnFamily, keys [][]byte, readTs uint64) ([]*utils.Entry, []error) {
+	entries := make([]*utils.Entry, len(keys))
+	errs := make([]error, len(keys))
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if err := cf.readable(); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return entries, errs
+	}
+	versions := make([][]*utils.Entry, len(keys))
+	var pending []int
+	var ikeys [][]byte
+	for i, key := range keys {
+		if len(key) == 0 {
+			errs[i] = utils.ErrEmptyKey
+			continue
+		}
+		vs, next, done := db.memTableVersions(cf, utils.KeyWithTs(key, readTs))
+		versions[i] = vs
+		if !done {
+			pending = append(pending, i)
+			ikeys = append(ikeys, next)
+		}
+	}
+	if len(ikeys) > 0 {
+		found, ferrs := cf.lm.tc.multiGet(cf.lm.levels, ikeys)
+		for j, i := range pending {
+			switch ferrs[j] {
+			case nil:
+				versions[i] = append(versions[i], found[j])
+			case utils.ErrKeyNotFound:
+			default:
+				errs[i] = ferrs[j]
+			}
+		}
+	}
+	for i, key := range keys {
+		if errs[i] != nil {
+			continue
+		}
+		if len(versions[i]) == 0 {
+			errs[i] = utils.ErrKeyNotFound
+			continue
+		}
+		e, err := cf.fold(versions[i])
+		if err == nil {
+			e, err = db.liveValue(e, key)
+		}
+		entries[i], errs[i] = e, err
+	}
+	return entries, errs
+}
+
+// multiGet looks up the keys, sorted by CompareKeys, like get. Sorted keys
+// falling in the same block follow each other, so every block is read once.
+// A key missing from the table gets ErrKeyNotFound.
+func (t *table) multiGet(keys [][]byte) ([]*utils.Entry, []error) {
+	entries := make([]*utils.Entry, len(keys))
+	errs := make([]error, len(keys))
+	index, err := t.getIndex()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return entries, errs
+	}
+	lastIdx, last := -1, (*block)(nil)
+	load := func(idx int) (*block, error) {
+		if idx != lastIdx {
+			b, err := t.blockAt(index, idx)
+			if err != nil {
+				return nil, err
+			}
+			lastIdx, last = idx, b
+		}
+		return last, nil
+	}
+	for i, key := range keys {
+		entries[i], errs[i] = t.getIn(index, key, load)
+	}
+	return entries, errs
+}
+
+// multiGet looks up keys in levels, the table ids of each level in the order
+// they are probed: newest first for overlapping level 0 tables. Levels are
+// probed in parallel and for each key the newest level holding it wins, a
+// merge operand is folded with the versions below it. Results are in the
+// order of keys, a key found nowhere gets ErrKeyNotFound.
+func (tc *tableCache) multiGet(levels [][]uint64, keys [][]byte) ([]*utils.Entry, []error) {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return utils.CompareKeys(keys[order[i]], keys[order[j]]) < 0 })
+	sorted := make([][]byte, len(keys))
+	for i, o := range order {
+		sorted[i] = keys[o]
+	}
+
+	type result struct {
+		entries []*utils.Entry
+		errs    []error
+		seeks   []seekStat
+	}
+	results := make([]result, len(levels))
+	var wg sync.WaitGroup
+	for l, fids := range levels {
+		wg.Add(1)
+		go func(l int, fids []uint64) {
+			defer wg.Done()
+			entries, errs, seeks := tc.multiGetLevel(fids, sorted)
+			results[l] = result{entries, errs, seeks}
+		}(l, fids)
+	}
+	wg.Wait()
+
+	entries := make([]*utils.Entry, len(keys))
+	errs := make([]error, len(keys))
+	for i, o := range order {
+		errs[o] = utils.ErrKeyNotFound
+		// the levels below the one holding the key are probed in parallel
+		// only, a serial lookup would not have consulted them
+		var seeks seekStat
+		for _, r := range results {
+			seeks = seeks.add(r.seeks[i])
+			if r.errs[i] != utils.ErrKeyNotFound {
+				entries[o], errs[o] = r.entries[i], r.errs[i]
+				break
+			}
+		}
+		tc.chargeSeek(seeks)
+		if errs[o] == nil {
+			entries[o], errs[o] = tc.resolveMerge(levels, entries[o])
+		}
+	}
+	return entries, errs
+}
+
+// get looks key up in levels like multiGet, one level after the other
+func (tc *tableCache) get(levels [][]uint64, key []byte) (*utils.Entry, error) {
+	e, err := tc.getVersion(levels, key)
+	if err != nil {
+		return nil, err
+	}
+	return tc.resolveMerge(levels, e)
+}
+
+// getVersion returns the newest version of key's user key at or below its
+// version, without folding merge operands
+func (tc *tableCache) getVersion(levels [][]uint64, key []byte) (*utils.Entry, error) {
+	keys := [][]byte{key}
+	var seeks seekStat
+	defer func() { tc.chargeSeek(seeks) }()
+	for _, fids := range levels {
+		entries, errs, s := tc.multiGetLevel(fids, keys)
+		seeks = seeks.add(s[0])
+		if errs[0] != utils.ErrKeyNotFound {
+			return entries[0], errs[0]
+		}
+	}
+	return nil, utils.ErrKeyNotFound
+}
+
+// resolveMerge folds a merge operand e found in levels with the older
+// versions of its key, down to a base value or the oldest version. Other
+// entries are returned as they are.
+func (tc *tableCache) resolveMerge(levels [][]uint64, e *utils.Entry) (*utils.Entry, error) {
+	if e.Meta&utils.BitMerge == 0 {
+		return e, nil
+	}
+	versions := []*utils.Entry{e}
+	for last := e; last.Meta&utils.BitMerge != 0; {
+		ts := utils.ParseTs(last.Key)
+		if ts == 0 {
+			break
+		}
+		older, err := tc.getVersion(levels, utils.KeyWithTs(utils.ParseKey(last.Key), ts-1))
+		if err == utils.ErrKeyNotFound {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, older)
+		last = older
+	}
+	merged, err := mergeEntries(tc.opt.MergeOperator, tc.vlog, versions, true, tc.opt.clock().Now())
+	if err != nil {
+		return nil, err
+	}
+	return merged[0], nil
+}
+
+// multiGetLevel probes the tables fids in order for the sorted keys. A key
+// is done with the first table that has it or fails on it, tables are only
+// asked for the keys inside their key range. seeks holds the tables each key
+// consulted.
+func (tc *tableCache) multiGetLevel(fids []uint64, keys [][]byte) ([]*utils.Entry, []error, []seekStat) {
+	entries := make([]*utils.Entry, len(keys))
+	errs := make([]error, len(keys))
+	seeks := make([]seekStat, len(keys))
+	pending := make([]int, len(keys))
+	for i := range keys {
+		errs[i] = utils.ErrKeyNotFound
+		pending[i] = i
+	}
+	for _, fid := range fids {
+		if len(pending) == 0 {
+			break
+		}
+		tc.lock.Lock()
+		kr, known := tc.ranges[fid]
+		tc.lock.Unlock()
+		if known && len(inRange(kr, keys, pending)) == 0 {
+			continue
+		}
+		t, err := tc.acquire(fid)
+		if err != nil {
+			for _, i := range pending {
+				errs[i] = err
+			}
+			return entries, errs, seeks
+		}
+		probe := inRange(keyRange{utils.ParseKey(t.smallest), utils.ParseKey(t.biggest)}, keys, pending)
+		if len(probe) == 0 {
+			tc.release(t)
+			continue
+		}
+		batch := make([][]byte, len(probe))
+		for j, i := range probe {
+			batch[j] = keys[i]
+		}
+		found, ferrs := t.multiGet(batch)
+		tc.release(t)
+		for _, i := range probe {
+			seeks[i] = seeks[i].add(seekStat{first: fid, n: 1})
+		}
+
+		next := pending[:0]
+		j := 0
+		for _, i := range pending {
+			if j < len(probe) && probe[j] == i {
+				entries[i], errs[i] = found[j], ferrs[j]
+				j++
+				if errs[i] != utils.ErrKeyNotFound {
+					continue
+				}
+			}
+			next = append(next, i)
+		}
+		pending = next
+	}
+	return entries, errs, seeks
+}
+
+// inRange returns the pending keys whose user key falls inside kr
+func inRange(kr keyRange, keys [][]byte, pending []int) []int {
+	var in []int
+	for _, i := range pending {
+		k := utils.ParseKey(keys[i])
+		if bytes.Compare(k, kr.smallest) >= 0 && bytes.Compare(k, kr.biggest) <= 0 {
+			in = append(in, i)
+		}
+	}
+	return in
+}
@@ -0,0 +1,98 @@
+package lsm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"TLKV/utils"
+)
+
+func TestDBMultiGet(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	opt.MergeOperator = appendOperator{}
+	opt.ValueThreshold = 64
+	db := openTestDB(t, opt)
+	set := func(e *utils.Entry) {
+		t.Helper()
+		if err := db.Set(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		set(utils.NewEntry([]byte(fmt.Sprintf("k%03d", i)), []byte(fmt.Sprintf("v%d", i))))
+	}
+	set(utils.NewEntry([]byte("big"), []byte(strings.Repeat("b", 100))))
+	compactDB(t, db, 2)
+	for i := 0; i < 200; i += 10 {
+		set(utils.NewEntry([]byte(fmt.Sprintf("k%03d", i)), []byte("flushed")))
+	}
+	flushDB(t, db)
+	// memtable versions shadow the tables or fold onto them
+	set(utils.NewEntry([]byte("k020"), []byte("memtable")))
+	if err := db.Del([]byte("k005")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge([]byte("k007"), []byte("op")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Merge([]byte("fresh"), []byte("only")); err != nil {
+		t.Fatal(err)
+	}
+	set(utils.NewEntry([]byte("k009"), []byte("short-lived")).WithTTLOnWrite(time.Minute))
+	clock.Advance(time.Minute)
+
+	keys := []string{"k151", "k020", "k005", "k007", "missing", "k010", "fresh", "", "k009", "big", "k151"}
+	want := []string{"v151", "memtable", "", "v7,op", "", "flushed", "only", "", "", strings.Repeat("b", 100), "v151"}
+	bkeys := make([][]byte, len(keys))
+	for i, k := range keys {
+		bkeys[i] = []byte(k)
+	}
+	entries, errs := db.MultiGet(bkeys)
+	for i, k := range keys {
+		switch {
+		case k == "":
+			if errs[i] != utils.ErrEmptyKey {
+				t.Fatalf("empty key: %v", errs[i])
+			}
+		case want[i] == "":
+			if errs[i] != utils.ErrKeyNotFound {
+				t.Fatalf("%s: %+v %v, want ErrKeyNotFound", k, entries[i], errs[i])
+			}
+		default:
+			if errs[i] != nil || string(entries[i].Key) != k || string(entries[i].Value) != want[i] {
+				t.Fatalf("%s: %+v %v, want %q", k, entries[i], errs[i], want[i])
+			}
+		}
+	}
+	// it agrees with Get on every key
+	all := make([][]byte, 200)
+	for i := range all {
+		all[i] = []byte(fmt.Sprintf("k%03d", 199-i))
+	}
+	entries, errs = db.MultiGet(all)
+	for i, k := range all {
+		e, err := db.Get(k)
+		if err != errs[i] || (err == nil && string(e.Value) != string(entries[i].Value)) {
+			t.Fatalf("%s: MultiGet %+v %v, Get %+v %v", k, entries[i], errs[i], e, err)
+		}
+	}
+
+	cf, err := db.CreateColumnFamily("other", &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.Set(utils.NewEntry([]byte("k150"), []byte("other"))); err != nil {
+		t.Fatal(err)
+	}
+	entries, errs = cf.MultiGet([][]byte{[]byte("k150"), []byte("k151")})
+	if errs[0] != nil || string(entries[0].Value) != "other" || errs[1] != utils.ErrKeyNotFound {
+		t.Fatalf("family: %+v %v", entries, errs)
+	}
+
+	db.Close()
+	if _, errs := db.MultiGet([][]byte{[]byte("k001")}); errs[0] != utils.ErrDBClosed {
+		t.Fatalf("after close: %v", errs[0])
+	}
+}
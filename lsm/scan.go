@@ -0,0 +1,234 @@
+package lsm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"time"
+
+	"TLKV/utils"
+)
+
+// scanTokenVersion is the first byte of every continuation token
+const scanTokenVersion = 1
+
+// scanTokenMACSize is how much of the HMAC-SHA256 a token carries
+const scanTokenMACSize = 16
+
+// scanSecretSize is the size of the HMAC key kept in the work dir
+const scanSecretSize = 32
+
+// ScanOptions restricts a paginated scan
+type ScanOptions struct {
+	// Prefix and UpperBound limit the scanned user keys like utils.Options
+	Prefix     []byte
+	UpperBound []byte
+	// Token continues the scan that returned it, start is ignored then
+	Token []byte
+}
+
+// ScanPage is one page of a scan
+type ScanPage struct {
+	Entries []*utils.Entry
+	// Token continues the scan after the last entry at the same read version,
+	// nil once no key is left
+	Token []byte
+}
+
+// Scan returns a page of up to limit live keys of the default family from
+// start on in ascending order, each at its newest version, and a token if
+// more keys follow. Passing the token in opt reads the next page at the
+// same version, also after a restart, while ScanRetention keeps it;
+// ErrScanTokenExpired is returned after that.
+func (db *DB) Scan(start []byte, limit int, opt *ScanOptions) (*ScanPage, error) {
+	return db.scan(db.def, start, limit, opt)
+}
+
+func (db *DB) scan(cf *ColumnFamily, start []byte, limit int, opt *ScanOptions) (*ScanPage, error) {
+	if limit <= 0 {
+		return nil, utils.ErrInvalidRequest
+	}
+	if opt == nil {
+		opt = &ScanOptions{}
+	}
+	cur, err := newScanCursor(db.scanSecret, start, opt, db.readTs())
+	if err != nil {
+		return nil, err
+	}
+	db.lock.RLock()
+	if err := cf.readable(); err != nil {
+		db.lock.RUnlock()
+		return nil, err
+	}
+	iters, err := db.iterators(cf, &utils.Options{Prefix: opt.Prefix, UpperBound: opt.UpperBound})
+	db.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	itr := newMergeIterator(iters)
+	defer itr.Close()
+	// compactions swapped in before the tables were taken discarded at most
+	// up to discardTs, the ones swapped in later don't touch them
+	if cur.readTs < db.discardTs() {
+		return nil, utils.ErrScanTokenExpired
+	}
+	return scanPage(itr, cur, limit, opt, cf.Opt.MergeOperator, db.vlog, db.scanSecret, cf.Opt.clock().Now())
+}
+
+// openScanSecret returns the HMAC key of the tokens of the DB in dir,
+// creating it on the first open. It is replaced through a rename, so a
+// crash never leaves a torn one behind.
+func openScanSecret(dir string) ([]byte, error) {
+	path := filepath.Join(dir, utils.ScanSecretFileName)
+	secret, err := os.ReadFile(path)
+	if err == nil && len(secret) == scanSecretSize {
+		return secret, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	secret = make([]byte, scanSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, secret, 0600); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(tmp)
+	if err != nil {
+		return nil, err
+	}
+	err = f.Sync()
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return secret, utils.SyncDir(dir)
+}
+
+// scanCursor is where a page starts: at key, or after it when a token
+// resumes the scan, reading versions up to readTs
+type scanCursor struct {
+	key    []byte
+	after  bool
+	readTs uint64
+}
+
+// scanTokenMAC seals the token body with secret
+func scanTokenMAC(secret, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(body)
+	return h.Sum(nil)[:scanTokenMACSize]
+}
+
+// encodeScanToken encodes | version | uvarint readTs | last key | mac |.
+// It only depends on the secret kept in the work dir, so tokens survive
+// restarts, and a client can't make one up to read at another version.
+func encodeScanToken(secret, lastKey []byte, readTs uint64) []byte {
+	buf := make([]byte, 1, 1+binary.MaxVarintLen64+len(lastKey)+scanTokenMACSize)
+	buf[0] = scanTokenVersion
+	buf = binary.AppendUvarint(buf, readTs)
+	buf = append(buf, lastKey...)
+	return append(buf, scanTokenMAC(secret, buf)...)
+}
+
+func decodeScanToken(secret, token []byte) (scanCursor, error) {
+	if len(token) < 1+1+scanTokenMACSize || token[0] != scanTokenVersion {
+		return scanCursor{}, utils.ErrInvalidScanToken
+	}
+	n := len(token) - scanTokenMACSize
+	if !hmac.Equal(scanTokenMAC(secret, token[:n]), token[n:]) {
+		return scanCursor{}, utils.ErrInvalidScanToken
+	}
+	readTs, sz := binary.Uvarint(token[1:n])
+	if sz <= 0 {
+		return scanCursor{}, utils.ErrInvalidScanToken
+	}
+	return scanCursor{key: token[1+sz : n], after: true, readTs: readTs}, nil
+}
+
+// newScanCursor starts a scan at start reading at readTs, or resumes the
+// scan of opt.Token
+func newScanCursor(secret, start []byte, opt *ScanOptions, readTs uint64) (scanCursor, error) {
+	if opt.Token == nil {
+		return scanCursor{key: start, readTs: readTs}, nil
+	}
+	return decodeScanToken(secret, opt.Token)
+}
+
+// scanPage reads up to limit live keys from cur on, the newest version of
+// each at or below cur.readTs with merge operands folded in. itr iterates
+// internal keys in ascending order. The page gets a token only if a live
+// key follows its last one.
+func scanPage(itr utils.Iterator, cur scanCursor, limit int, opt *ScanOptions, op MergeOperator, vlog *valueLog, secret []byte, now time.Time) (*ScanPage, error) {
+	bounds := &utils.Options{Prefix: opt.Prefix, UpperBound: opt.UpperBound, IsAsc: true}
+	from := cur.key
+	if bytes.Compare(from, opt.Prefix) < 0 {
+		from, cur.after = opt.Prefix, false
+	}
+	switch {
+	case len(from) == 0:
+		itr.Rewind()
+	case cur.after:
+		// the oldest version of the last key, the next key is right after it
+		itr.Seek(utils.KeyWithTs(from, 0))
+	default:
+		itr.Seek(utils.KeyWithTs(from, cur.readTs))
+	}
+
+	page := &ScanPage{}
+	var versions []*utils.Entry
+	for itr.Valid() {
+		key := utils.ParseKey(itr.Item().Entry().Key)
+		if cur.after && bytes.Equal(key, from) {
+			itr.Next()
+			continue
+		}
+		if !bounds.InBounds(key) {
+			// keys start at or past the prefix, so this is past the end
+			break
+		}
+		key = utils.SafeCopy(nil, key)
+		versions = versions[:0]
+		for ; itr.Valid(); itr.Next() {
+			e := itr.Item().Entry()
+			if !bytes.Equal(utils.ParseKey(e.Key), key) {
+				break
+			}
+			if utils.ParseTs(e.Key) <= cur.readTs {
+				versions = append(versions, copyEntry(e))
+			}
+		}
+		if len(versions) == 0 {
+			continue
+		}
+		merged, err := mergeEntries(op, vlog, versions, true, now)
+		if err != nil {
+			return nil, err
+		}
+		e := merged[0]
+		if e.IsDeletedOrExpiredAt(now) {
+			continue
+		}
+		if len(page.Entries) == limit {
+			// another key follows the page
+			last := page.Entries[limit-1].Key
+			page.Token = encodeScanToken(secret, last, cur.readTs)
+			break
+		}
+		if e, err = vlog.resolve(e); err != nil {
+			return nil, err
+		}
+		e.Key = key
+		page.Entries = append(page.Entries, e)
+	}
+	return page, nil
+}
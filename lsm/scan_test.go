@@ -0,0 +1,183 @@
+package lsm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"TLKV/utils"
+)
+
+// scanAll pages through the scan from start, returning the key=value pairs
+// and the number of pages
+func scanAll(t *testing.T, db *DB, start string, limit int, opt *ScanOptions) ([]string, int) {
+	t.Helper()
+	if opt == nil {
+		opt = &ScanOptions{}
+	}
+	var got []string
+	pages := 0
+	for {
+		page, err := db.Scan([]byte(start), limit, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, e := range page.Entries {
+			got = append(got, fmt.Sprintf("%s=%s", e.Key, e.Value))
+		}
+		if page.Token == nil {
+			return got, pages
+		}
+		next := *opt
+		next.Token = page.Token
+		opt = &next
+	}
+}
+
+func TestDBScan(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	opt.ScanRetention = 10 * time.Minute
+	opt.MergeOperator = appendOperator{}
+	db := openTestDB(t, opt)
+	for i := 0; i < 25; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%02d", i)), []byte("v"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Merge([]byte("k03"), []byte("op")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Del([]byte("k04")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Scan(nil, 0, nil); err != utils.ErrInvalidRequest {
+		t.Fatalf("limit 0: %v", err)
+	}
+	got, pages := scanAll(t, db, "", 8, nil)
+	if len(got) != 24 || pages != 3 || got[3] != "k03=v,op" || got[4] != "k05=v" {
+		t.Fatalf("%d pages: %v", pages, got)
+	}
+	// a full last page gets no token, nor does one followed only by deletes
+	if got, pages = scanAll(t, db, "k13", 6, nil); len(got) != 12 || pages != 2 {
+		t.Fatalf("%d pages: %v", pages, got)
+	}
+	if err := db.Del([]byte("k24")); err != nil {
+		t.Fatal(err)
+	}
+	if got, pages = scanAll(t, db, "k20", 4, nil); len(got) != 4 || pages != 1 {
+		t.Fatalf("%d pages: %v", pages, got)
+	}
+	if got, _ = scanAll(t, db, "k1", 3, &ScanOptions{Prefix: []byte("k1")}); len(got) != 10 || got[0] != "k10=v" {
+		t.Fatalf("prefix: %v", got)
+	}
+	if got, _ = scanAll(t, db, "k05", 2, &ScanOptions{UpperBound: []byte("k08")}); len(got) != 3 {
+		t.Fatalf("upper bound: %v", got)
+	}
+
+	// the next page reads at the version of the first one, through
+	// overwrites, a compaction and a restart
+	page, err := db.Scan(nil, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"k00", "k15", "k16"} {
+		if err := db.Set(utils.NewEntry([]byte(k), []byte("new"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Del([]byte("k17")); err != nil {
+		t.Fatal(err)
+	}
+	compactDB(t, db, 3)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db = openTestDB(t, opt)
+	next, err := db.Scan(nil, 10, &ScanOptions{Token: page.Token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Entries) != 10 || string(next.Entries[0].Key) != "k11" ||
+		string(next.Entries[4].Value) != "v" || string(next.Entries[6].Key) != "k17" {
+		t.Fatalf("resumed page %v", next.Entries)
+	}
+	mustGet(t, db, "k15", "new")
+
+	// forged or foreign tokens are refused
+	forged := append([]byte{}, page.Token...)
+	forged[1]++
+	if _, err := db.Scan(nil, 10, &ScanOptions{Token: forged}); err != utils.ErrInvalidScanToken {
+		t.Fatalf("forged token: %v", err)
+	}
+	other := openTestDB(t, testOptions(t))
+	if err := other.Set(utils.NewEntry([]byte("k"), []byte("v"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Scan(nil, 10, &ScanOptions{Token: page.Token}); err != utils.ErrInvalidScanToken {
+		t.Fatalf("token of another DB: %v", err)
+	}
+
+	// once ScanRetention passed, the old versions may be gone
+	clock.Advance(11 * time.Minute)
+	if _, err := db.Scan(nil, 10, &ScanOptions{Token: next.Token}); err != utils.ErrScanTokenExpired {
+		t.Fatalf("expired token: %v", err)
+	}
+}
+
+func TestScanRetentionWriteBurst(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	opt.ScanRetention = 10 * time.Minute
+	db := openTestDB(t, opt)
+	for _, k := range []string{"a", "c"} {
+		if err := db.Set(utils.NewEntry([]byte(k), []byte("v"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// versions run ahead of the clock, far past the retention in seconds
+	for i := 0; i < 5000; i++ {
+		if err := db.Set(utils.NewEntry([]byte("b"), []byte(fmt.Sprint(i)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old, err := db.Scan(nil, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if err := db.Set(utils.NewEntry([]byte("b"), []byte("late"))); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(54 * time.Minute)
+	recent, err := db.Scan(nil, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(5 * time.Minute)
+	stats, err := db.CompactRange(nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables, err := db.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys uint32
+	for _, ti := range tables {
+		keys += ti.KeyCount
+	}
+	if keys != 3 {
+		t.Fatalf("%d versions left of 3 keys, %+v", keys, stats)
+	}
+	if _, err := db.Scan(nil, 1, &ScanOptions{Token: old.Token}); err != utils.ErrScanTokenExpired {
+		t.Fatalf("token of an hour ago: %v", err)
+	}
+	page, err := db.Scan(nil, 1, &ScanOptions{Token: recent.Token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(page.Entries[0].Key) != "b" || string(page.Entries[0].Value) != "late" {
+		t.Fatalf("token of 5 minutes ago read %v", page.Entries)
+	}
+}
@@ -0,0 +1,91 @@
+package lsm
+
+// minAllowedSeeks keeps small tables from being compacted for a few misses
+const minAllowedSeeks = 100
+
+// seekBudget is the number of lookups finding nothing a table of size bytes
+// may answer. Like LevelDB, a wasted seek is taken to cost about as much as
+// compacting bytesPerSeek bytes, so after that many the table is better
+// merged into the level below.
+func seekBudget(size, bytesPerSeek int64) int64 {
+	if n := size / bytesPerSeek; n > minAllowedSeeks {
+		return n
+	}
+	return minAllowedSeeks
+}
+
+// seekStat is what a lookup of one key cost: the number of tables whose
+// filter or blocks it consulted and the first of them
+type seekStat struct {
+	first uint64
+	n     int
+}
+
+// add returns the cost of a lookup going on with o after s
+func (s seekStat) add(o seekStat) seekStat {
+	if s.n == 0 {
+		return o
+	}
+	s.n += o.n
+	return s
+}
+
+// chargeSeek charges the first table a lookup consulted if the lookup had to
+// go on to another one, like LevelDB: that seek was wasted. Only the first
+// is charged, a key found in neither would otherwise use up the budget of
+// every table it overlaps at once. The table is queued for compaction once
+// its budget runs out, the compactor picks it up on its next tick.
+func (tc *tableCache) chargeSeek(s seekStat) {
+	if s.n < 2 {
+		return
+	}
+	fid := s.first
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	left, ok := tc.allowedSeeks[fid]
+	if !ok || left <= 0 {
+		return
+	}
+	left--
+	tc.allowedSeeks[fid] = left
+	if left <= 0 {
+		tc.seekCompactions = append(tc.seekCompactions, fid)
+	}
+}
+
+// takeSeekCompactions returns and forgets the tables out of seek budget that
+// are still live
+func (tc *tableCache) takeSeekCompactions() []uint64 {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	var fids []uint64
+	for _, fid := range tc.seekCompactions {
+		if _, ok := tc.allowedSeeks[fid]; ok {
+			fids = append(fids, fid)
+		}
+	}
+	tc.seekCompactions = nil
+	return fids
+}
+
+// requeueSeekCompactions puts back tables takeSeekCompactions returned but
+// that weren't compacted yet, ahead of the ones queued since
+func (tc *tableCache) requeueSeekCompactions(fids []uint64) {
+	if len(fids) == 0 {
+		return
+	}
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	tc.seekCompactions = append(append([]uint64{}, fids...), tc.seekCompactions...)
+}
+
+// planSeekCompaction plans the compaction of the table fid of level into
+// the next level, along with the tables it overlaps there
+func (tc *tableCache) planSeekCompaction(levels [][]uint64, level int, fid uint64, discardTs uint64) (*compactDef, error) {
+	kr, err := tc.tableRange(fid)
+	if err != nil {
+		return nil, err
+	}
+	end := append(append([]byte{}, kr.biggest...), 0)
+	return tc.planCompactRange(levels, kr.smallest, end, level, level+1, discardTs)
+}
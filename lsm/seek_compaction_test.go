@@ -0,0 +1,117 @@
+package lsm
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"TLKV/utils"
+)
+
+func allowedSeeks(tc *tableCache, fid uint64) (int64, bool) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	left, ok := tc.allowedSeeks[fid]
+	return left, ok
+}
+
+func TestChargeFirstWastedSeek(t *testing.T) {
+	opt := testOptions(t)
+	opt.SeekCompactionBytesPerSeek = 1 << 30
+	buildTestTable(t, opt, 1, 0, 1000)
+	buildTestTable(t, opt, 2, 0, 1000)
+	tc := newTableCache(opt, nil, newCache(opt))
+	levels := [][]uint64{{1}, {2}}
+	miss := utils.KeyWithTs([]byte("k0100x"), math.MaxUint64)
+
+	// a key found in the first table wastes nothing
+	if _, err := tc.get(levels, utils.KeyWithTs([]byte("k0100"), math.MaxUint64)); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := allowedSeeks(tc, 1); left != minAllowedSeeks {
+		t.Fatalf("hit charged: %d left", left)
+	}
+	// a missing key charges the first table only
+	if _, err := tc.get(levels, miss); err != utils.ErrKeyNotFound {
+		t.Fatal(err)
+	}
+	if left, _ := allowedSeeks(tc, 1); left != minAllowedSeeks-1 {
+		t.Fatalf("first table: %d left", left)
+	}
+	if left, _ := allowedSeeks(tc, 2); left != minAllowedSeeks {
+		t.Fatalf("second table: %d left", left)
+	}
+	// so does a batch, once per key
+	keys := [][]byte{miss, utils.KeyWithTs([]byte("k0200x"), math.MaxUint64), utils.KeyWithTs([]byte("k0300"), math.MaxUint64)}
+	if _, errs := tc.multiGet(levels, keys); errs[2] != nil {
+		t.Fatal(errs[2])
+	}
+	if left, _ := allowedSeeks(tc, 1); left != minAllowedSeeks-3 {
+		t.Fatalf("after batch: %d left", left)
+	}
+
+	for i := 0; i < minAllowedSeeks; i++ {
+		tc.get(levels, miss)
+	}
+	if fids := tc.takeSeekCompactions(); len(fids) != 1 || fids[0] != 1 {
+		t.Fatalf("queued %v", fids)
+	}
+	if fids := tc.takeSeekCompactions(); len(fids) != 0 {
+		t.Fatalf("queue not consumed: %v", fids)
+	}
+}
+
+func TestSeekCompactionBudgetDefault(t *testing.T) {
+	for _, c := range []struct {
+		bytesPerSeek int64
+		enabled      bool
+	}{{0, true}, {-1, false}} {
+		opt := testOptions(t)
+		opt.SeekCompactionBytesPerSeek = c.bytesPerSeek
+		opt = opt.withDefaults()
+		buildTestTable(t, opt, 1, 0, 100)
+		tc := newTableCache(opt, nil, nil)
+		tbl, err := tc.acquire(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.release(tbl)
+		if _, ok := allowedSeeks(tc, 1); ok != c.enabled {
+			t.Fatalf("SeekCompactionBytesPerSeek %d: budget %v", c.bytesPerSeek, ok)
+		}
+	}
+}
+
+func TestDBSeekCompaction(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	set := func(from, to int) {
+		t.Helper()
+		for i := from; i < to; i += 2 {
+			if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%04d", i)), []byte("v"))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	set(0, 400)
+	compactDB(t, db, 1)
+	set(1, 400)
+	flushDB(t, db)
+
+	// every miss consults the level 0 table, then level 1
+	for i := 0; i < minAllowedSeeks; i++ {
+		mustMiss(t, db, "k0100x")
+	}
+	drainCompactions(t, db)
+	tables, err := db.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ti := range tables {
+		if ti.Level == 0 {
+			t.Fatalf("table %d still in level 0", ti.ID)
+		}
+	}
+	mustGet(t, db, "k0100", "v")
+	mustGet(t, db, "k0101", "v")
+}
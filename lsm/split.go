@@ -0,0 +1,113 @@
+package lsm
+
+import (
+	"bytes"
+	"sort"
+
+	"TLKV/utils"
+)
+
+// blockSample is the user key a data block starts with and its size
+type blockSample struct {
+	key  []byte
+	size int64
+}
+
+// blockSamples returns the blocks of the table overlapping the user key
+// range [start, end), read from the index only
+func (t *table) blockSamples(start, end []byte) ([]blockSample, error) {
+	index, first, last, err := t.blockRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	var samples []blockSample
+	for idx := first; idx <= last; idx++ {
+		ko, err := t.blockOffset(index, idx)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, blockSample{
+			key:  utils.SafeCopy(nil, utils.ParseKey(ko.GetKey())),
+			size: int64(ko.GetLen()),
+		})
+	}
+	return samples, nil
+}
+
+// suggestSplitKeys returns up to n ascending user keys strictly inside
+// (start, end) that split the data of levels, the table ids of each level,
+// and of the memtables skls into n+1 parts of about the same size. Split
+// keys are block base keys or memtable keys, so fewer are returned when the
+// range spans too few blocks.
+func (tc *tableCache) suggestSplitKeys(levels [][]uint64, skls []*utils.Skiplist, start, end []byte, n int) ([][]byte, error) {
+	bounds := &utils.Options{LowerBound: start, UpperBound: end}
+	var samples []blockSample
+	for _, skl := range skls {
+		walkMemTable(skl, start, end, func(key []byte, size int64) {
+			samples = append(samples, blockSample{key: utils.SafeCopy(nil, utils.ParseKey(key)), size: size})
+		})
+	}
+	for _, fids := range levels {
+		for _, fid := range fids {
+			tc.lock.Lock()
+			kr, known := tc.ranges[fid]
+			tc.lock.Unlock()
+			if known && !bounds.Overlaps(kr.smallest, kr.biggest) {
+				continue
+			}
+			t, err := tc.acquire(fid)
+			if err != nil {
+				return nil, err
+			}
+			s, err := t.blockSamples(start, end)
+			tc.release(t)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, s...)
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool { return bytes.Compare(samples[i].key, samples[j].key) < 0 })
+	var total int64
+	for _, s := range samples {
+		total += s.size
+	}
+
+	var keys [][]byte
+	var cum int64
+	next := 1
+	for _, s := range samples {
+		// a part ends where the block reaching its share of total starts,
+		// next only moves on once a key is taken so skipped blocks don't use
+		// up boundaries
+		if next <= n && cum >= total*int64(next)/int64(n+1) &&
+			bytes.Compare(s.key, start) > 0 &&
+			(len(keys) == 0 || !bytes.Equal(keys[len(keys)-1], s.key)) {
+			keys = append(keys, s.key)
+			next++
+		}
+		cum += s.size
+	}
+	return keys, nil
+}
+
+// SuggestSplitKeys returns up to n ascending user keys that divide the data
+// of the default family in the user key range [start, end) into n+1 parts
+// of about the same size, nil leaves a side open. Like ApproximateRange it
+// reads table indexes only, fewer keys are returned if the range holds too
+// few blocks.
+func (db *DB) SuggestSplitKeys(start, end []byte, n int) ([][]byte, error) {
+	return db.suggestSplitKeys(db.def, start, end, n)
+}
+
+func (db *DB) suggestSplitKeys(cf *ColumnFamily, start, end []byte, n int) ([][]byte, error) {
+	if n <= 0 {
+		return nil, utils.ErrInvalidRequest
+	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if err := cf.readable(); err != nil {
+		return nil, err
+	}
+	return cf.lm.tc.suggestSplitKeys(cf.lm.levels, db.skiplists(cf), start, end, n)
+}
@@ -0,0 +1,146 @@
+package lsm
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"TLKV/utils"
+)
+
+func checkSplitKeys(t *testing.T, keys [][]byte, start, end []byte) {
+	t.Helper()
+	prev := start
+	for _, k := range keys {
+		if prev != nil && bytes.Compare(k, prev) <= 0 {
+			t.Fatalf("split key %q not after %q", k, prev)
+		}
+		prev = k
+	}
+	if end != nil && len(keys) > 0 && bytes.Compare(keys[len(keys)-1], end) >= 0 {
+		t.Fatalf("split key %q not before %q", keys[len(keys)-1], end)
+	}
+}
+
+func TestSuggestSplitKeys(t *testing.T) {
+	opt := testOptions(t)
+	buildTestTable(t, opt, 1, 0, 1000)
+	buildTestTable(t, opt, 2, 0, 1000)
+	tc := newTableCache(opt, nil, newCache(opt))
+	// both levels hold the same blocks, every sample key shows up twice
+	levels := [][]uint64{{1}, {2}}
+
+	for _, n := range []int{1, 3, 7} {
+		keys, err := tc.suggestSplitKeys(levels, nil, nil, nil, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != n {
+			t.Fatalf("n=%d: got %d keys", n, len(keys))
+		}
+		checkSplitKeys(t, keys, nil, nil)
+	}
+
+	start, end := []byte("k0100"), []byte("k0300")
+	keys, err := tc.suggestSplitKeys(levels, nil, start, end, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 4 {
+		t.Fatalf("bounded: got %d keys", len(keys))
+	}
+	checkSplitKeys(t, keys, start, end)
+
+	// parts come out about the same size
+	keys, _ = tc.suggestSplitKeys(levels, nil, nil, nil, 3)
+	bounds := append(append([][]byte{nil}, keys...), nil)
+	var sizes []int64
+	for i := 0; i+1 < len(bounds); i++ {
+		st, err := tc.approximateRange(levels, nil, bounds[i], bounds[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, st.Size)
+	}
+	for _, s := range sizes {
+		if s < sizes[0]/2 || s > sizes[0]*2 {
+			t.Fatalf("uneven parts %v", sizes)
+		}
+	}
+
+	// with few blocks in range every block boundary gets used, keys shared
+	// by both levels or not after start must not eat up the split points
+	tbl, err := tc.acquire(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := tbl.blockSamples(nil, nil)
+	tc.release(tbl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end = samples[5].key, append(append([]byte{}, samples[7].key...), 0)
+	keys, err = tc.suggestSplitKeys(levels, nil, start, end, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || !bytes.Equal(keys[0], samples[6].key) || !bytes.Equal(keys[1], samples[7].key) {
+		t.Fatalf("got %q, want the keys of blocks 6 and 7", keys)
+	}
+
+	// a range inside a single block has no key to split at
+	if keys, _ := tc.suggestSplitKeys(levels, nil, []byte("k0100"), []byte("k0101"), 3); len(keys) != 0 {
+		t.Fatalf("got %d keys inside one block", len(keys))
+	}
+}
+
+func TestDBSuggestSplitKeys(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	set := func(from, to int) {
+		t.Helper()
+		for i := from; i < to; i++ {
+			if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%04d", i)), []byte(fmt.Sprintf("value-%d", i)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	set(0, 600)
+	compactDB(t, db, 1)
+	// the memtable holds a quarter of the data, past the tables
+	set(600, 800)
+
+	keys, err := db.SuggestSplitKeys(nil, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("got %d keys", len(keys))
+	}
+	checkSplitKeys(t, keys, nil, nil)
+	if bytes.Compare(keys[2], []byte("k0500")) < 0 {
+		t.Fatalf("last split %q ignores the memtable", keys[2])
+	}
+
+	// a range only in the memtable still splits
+	start, end := []byte("k0600"), []byte("k0700")
+	keys, err = db.SuggestSplitKeys(start, end, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || bytes.Compare(keys[0], []byte("k0640")) < 0 || bytes.Compare(keys[0], []byte("k0660")) > 0 {
+		t.Fatalf("memtable split %q", keys)
+	}
+	checkSplitKeys(t, keys, start, end)
+
+	if _, err := db.SuggestSplitKeys(nil, nil, 0); err != utils.ErrInvalidRequest {
+		t.Fatalf("n=0: %v", err)
+	}
+	cf, err := db.CreateColumnFamily("empty", &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := cf.SuggestSplitKeys(nil, nil, 2); err != nil || len(keys) != 0 {
+		t.Fatalf("empty family: %q, %v", keys, err)
+	}
+}
@@ -0,0 +1,212 @@
+package lsm
+
+import (
+	"bytes"
+	"math"
+
+	"TLKV/pb"
+	"TLKV/utils"
+)
+
+// RangeStats estimates the data in a user key range from table metadata
+// and the memtables
+type RangeStats struct {
+	// Size and KeyCount are the totals over Levels and the memtables
+	Size     int64
+	KeyCount int64
+	// Levels holds the estimate of each level
+	Levels []LevelRangeStats
+	// MemTableSize and MemTableKeyCount are the encoded size and the number
+	// of the memtable entries in the range
+	MemTableSize     int64
+	MemTableKeyCount int64
+}
+
+// LevelRangeStats estimates the part of a key range stored in one level
+type LevelRangeStats struct {
+	Size     int64
+	KeyCount int64
+}
+
+// approximateRange estimates the bytes and keys of the table in the user key
+// range [start, end), nil leaves that side open. Whole blocks overlapping the
+// range are counted and the keys are the share of KeyCount they hold.
+func (t *table) approximateRange(start, end []byte) (size, keys int64, err error) {
+	index, first, last, err := t.blockRange(start, end)
+	if err != nil || first > last {
+		return 0, 0, err
+	}
+	from, err := t.blockOffset(index, first)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := t.blockOffset(index, last)
+	if err != nil {
+		return 0, 0, err
+	}
+	size = int64(to.GetOffset()) + int64(to.GetLen()) - int64(from.GetOffset())
+
+	lastBlock, err := t.blockOffset(index, numBlocks(index)-1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if total := int64(lastBlock.GetOffset()) + int64(lastBlock.GetLen()); total > 0 {
+		keys = int64(index.GetKeyCount()) * size / total
+	}
+	return size, keys, nil
+}
+
+// blockRange returns the first and last block overlapping the user key range
+// [start, end), first > last if there is none
+func (t *table) blockRange(start, end []byte) (index *pb.TableIndex, first, last int, err error) {
+	bounds := &utils.Options{LowerBound: start, UpperBound: end}
+	if !bounds.Overlaps(utils.ParseKey(t.smallest), utils.ParseKey(t.biggest)) {
+		return nil, 0, -1, nil
+	}
+	if index, err = t.getIndex(); err != nil {
+		return nil, 0, -1, err
+	}
+	first, last = 0, numBlocks(index)-1
+	if last < 0 {
+		return index, 0, -1, nil
+	}
+	if len(start) > 0 {
+		if first, err = t.seekBlock(index, utils.KeyWithTs(start, math.MaxUint64)); err != nil {
+			return nil, 0, -1, err
+		}
+	}
+	if len(end) > 0 {
+		if last, err = t.seekBlock(index, utils.KeyWithTs(end, math.MaxUint64)); err != nil {
+			return nil, 0, -1, err
+		}
+		ko, err := t.blockOffset(index, last)
+		if err != nil {
+			return nil, 0, -1, err
+		}
+		if bytes.Compare(utils.ParseKey(ko.GetKey()), end) >= 0 {
+			// the last block starts at end, only the ones before it count
+			last--
+		}
+	}
+	return index, first, last, nil
+}
+
+// approximateRange estimates the data of levels, the table ids of each level,
+// and of the memtables skls in the user key range [start, end) without
+// reading any block. Tables whose known key range is outside are not opened.
+func (tc *tableCache) approximateRange(levels [][]uint64, skls []*utils.Skiplist, start, end []byte) (*RangeStats, error) {
+	bounds := &utils.Options{LowerBound: start, UpperBound: end}
+	stats := &RangeStats{Levels: make([]LevelRangeStats, len(levels))}
+	for l, fids := range levels {
+		for _, fid := range fids {
+			tc.lock.Lock()
+			kr, known := tc.ranges[fid]
+			tc.lock.Unlock()
+			if known && !bounds.Overlaps(kr.smallest, kr.biggest) {
+				continue
+			}
+			t, err := tc.acquire(fid)
+			if err != nil {
+				return nil, err
+			}
+			size, keys, err := t.approximateRange(start, end)
+			tc.release(t)
+			if err != nil {
+				return nil, err
+			}
+			stats.Levels[l].Size += size
+			stats.Levels[l].KeyCount += keys
+		}
+		stats.Size += stats.Levels[l].Size
+		stats.KeyCount += stats.Levels[l].KeyCount
+	}
+	for _, skl := range skls {
+		size, keys := memTableRange(skl, start, end)
+		stats.MemTableSize += size
+		stats.MemTableKeyCount += keys
+	}
+	stats.Size += stats.MemTableSize
+	stats.KeyCount += stats.MemTableKeyCount
+	return stats, nil
+}
+
+// memTableRange returns the encoded size and the number of the entries of
+// skl in the user key range [start, end)
+func memTableRange(skl *utils.Skiplist, start, end []byte) (size, keys int64) {
+	walkMemTable(skl, start, end, func(_ []byte, n int64) {
+		size += n
+		keys++
+	})
+	return size, keys
+}
+
+// walkMemTable calls fn with the internal key and the encoded size of each
+// entry of skl in the user key range [start, end). Memtables have no index
+// to estimate from, but they are in memory and bounded by MemTableSize, so
+// the range is walked.
+func walkMemTable(skl *utils.Skiplist, start, end []byte, fn func(key []byte, size int64)) {
+	itr := skl.NewSkipListIterator()
+	defer itr.Close()
+	if len(start) > 0 {
+		itr.Seek(utils.KeyWithTs(start, math.MaxUint64))
+	} else {
+		itr.Rewind()
+	}
+	for ; itr.Valid(); itr.Next() {
+		e := itr.Item().Entry()
+		if len(end) > 0 && bytes.Compare(utils.ParseKey(e.Key), end) >= 0 {
+			return
+		}
+		vs := utils.ValueStruct{Meta: e.Meta, UserMeta: e.UserMeta, ExpiresAt: e.ExpiresAt, Value: e.Value}
+		fn(e.Key, int64(len(e.Key))+int64(vs.EncodedSize()))
+	}
+}
+
+// ApproximateRange estimates the size and the number of entries of the
+// default family in the user key range [start, end) with a breakdown by
+// level, nil leaves a side open. Tables are estimated from their indexes
+// without reading blocks, so versions and deletes not compacted yet count.
+func (db *DB) ApproximateRange(start, end []byte) (*RangeStats, error) {
+	return db.approximateRange(db.def, start, end)
+}
+
+// ApproximateSize estimates the bytes of the user key range [start, end),
+// see ApproximateRange
+func (db *DB) ApproximateSize(start, end []byte) (int64, error) {
+	stats, err := db.ApproximateRange(start, end)
+	if err != nil {
+		return 0, err
+	}
+	return stats.Size, nil
+}
+
+// ApproximateKeyCount estimates the entries in the user key range
+// [start, end), see ApproximateRange
+func (db *DB) ApproximateKeyCount(start, end []byte) (int64, error) {
+	stats, err := db.ApproximateRange(start, end)
+	if err != nil {
+		return 0, err
+	}
+	return stats.KeyCount, nil
+}
+
+func (db *DB) approximateRange(cf *ColumnFamily, start, end []byte) (*RangeStats, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if err := cf.readable(); err != nil {
+		return nil, err
+	}
+	return cf.lm.tc.approximateRange(cf.lm.levels, db.skiplists(cf), start, end)
+}
+
+// skiplists returns the skiplists of the family in the memtables, db.lock
+// is held
+func (db *DB) skiplists(cf *ColumnFamily) []*utils.Skiplist {
+	var skls []*utils.Skiplist
+	for _, mt := range db.memTables() {
+		if sl := mt.skiplist(cf.ID); sl != nil {
+			skls = append(skls, sl)
+		}
+	}
+	return skls
+}
@@ -0,0 +1,86 @@
+package lsm
+
+import (
+	"fmt"
+	"testing"
+
+	"TLKV/utils"
+)
+
+func TestDBApproximateRange(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	db := openTestDB(t, opt)
+	for i := 0; i < 400; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%03d", i)), []byte(fmt.Sprintf("value-%d", i)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	compactDB(t, db, 1)
+	// the memtable holds 50 keys, only the ones in the range count
+	for i := 0; i < 50; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("m%03d", i)), []byte("memtable"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := db.ApproximateRange(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.MemTableKeyCount != 50 || all.MemTableSize <= 0 {
+		t.Fatalf("memtable: %d keys, %d bytes", all.MemTableKeyCount, all.MemTableSize)
+	}
+	if all.Levels[1].KeyCount != 400 || all.Levels[1].Size <= 0 {
+		t.Fatalf("level 1: %+v", all.Levels[1])
+	}
+	if all.KeyCount != 450 || all.Size != all.Levels[1].Size+all.MemTableSize {
+		t.Fatalf("totals: %+v", all)
+	}
+
+	mem, err := db.ApproximateRange([]byte("m010"), []byte("m020"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem.MemTableKeyCount != 10 || mem.KeyCount != 10 || mem.Levels[1].Size != 0 {
+		t.Fatalf("memtable range: %+v", mem)
+	}
+
+	half, err := db.ApproximateSize([]byte("k000"), []byte("k200"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if half <= 0 || half >= all.Levels[1].Size {
+		t.Fatalf("half of the table is %d bytes of %d", half, all.Levels[1].Size)
+	}
+	keys, err := db.ApproximateKeyCount([]byte("k000"), []byte("k200"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// whole blocks are counted
+	if keys < 150 || keys > 300 {
+		t.Fatalf("%d keys estimated for 200", keys)
+	}
+	if n, err := db.ApproximateKeyCount([]byte("x"), nil); err != nil || n != 0 {
+		t.Fatalf("empty range: %d, %v", n, err)
+	}
+
+	cf, err := db.CreateColumnFamily("other", &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.Set(utils.NewEntry([]byte("k001"), []byte("v"))); err != nil {
+		t.Fatal(err)
+	}
+	st, err := cf.ApproximateRange(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.KeyCount != 1 || st.MemTableKeyCount != 1 {
+		t.Fatalf("family: %+v", st)
+	}
+
+	db.Close()
+	if _, err := db.ApproximateSize(nil, nil); err != utils.ErrDBClosed {
+		t.Fatalf("closed: %v", err)
+	}
+}
@@ -0,0 +1,581 @@
+package lsm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"io"
+	"os"
+	"sort"
+
+	"TLKV/pb"
+	"TLKV/utils"
+	"TLKV/utils/mmap"
+
+	"github.com/pkg/errors"
+)
+
+// table is an SST file opened for reading through mmap
+// | block ... | index | index len | checksum | checksum len | MagicText | MagicVersion |
+// version 1 tables end at checksum len, they have no footer
+type table struct {
+	fid     uint64
+	fd      *os.File
+	data    []byte
+	version uint32
+	index   *pb.TableIndex // nil when indexes live in the index cache
+	dataKey *pb.DataKey    // nil when the table is not encrypted
+	kr      *KeyRegistry
+	cache   *cache
+	opt     *Options
+	// smallest and biggest keys, kept out of the index so ranges can be
+	// compared while the index is not in memory
+	smallest, biggest []byte
+	// staleDataSize is TableIndex.StaleDataSize, for the same reason
+	staleDataSize int64
+}
+
+// openTable opens the SST fid, kr provides the data key of encrypted tables
+// and c caches its blocks and index, both may be nil
+func openTable(opt *Options, kr *KeyRegistry, c *cache, fid uint64) (*table, error) {
+	fd, err := os.Open(utils.FileNameSSTable(opt.WorkDir, fid))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	data, err := mmap.Mmap(fd, false, fi.Size())
+	if err != nil {
+		fd.Close()
+		return nil, errors.Wrapf(err, "while mmapping table: %d", fid)
+	}
+	t := &table{fid: fid, fd: fd, data: data, kr: kr, cache: c, opt: opt}
+	if err := t.initIndex(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// read returns sz bytes at off without copying
+func (t *table) read(off, sz int) ([]byte, error) {
+	if off < 0 || sz < 0 || off+sz > len(t.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return t.data[off : off+sz], nil
+}
+
+func (t *table) initIndex() error {
+	readPos := len(t.data) - footerSize
+	footer, err := t.read(readPos, footerSize)
+	if err != nil {
+		return errors.Wrapf(utils.ErrBadMagic, "table: %d", t.fid)
+	}
+	t.version = 1
+	if bytes.Equal(footer[:len(utils.MagicText)], utils.MagicText[:]) {
+		t.version = utils.BytesToU32(footer[len(utils.MagicText):])
+		if t.version < 2 || t.version > utils.MagicVersion {
+			return errors.Wrapf(utils.ErrBadMagic, "table: %d unsupported version %d", t.fid, t.version)
+		}
+	}
+	index, err := t.readIndex()
+	if err != nil {
+		if t.version == 1 {
+			// no footer and no index behind it either, not a table
+			return errors.Wrapf(utils.ErrBadMagic, "table: %d: %v", t.fid, err)
+		}
+		return err
+	}
+	if err := t.initKeyRange(index); err != nil {
+		return err
+	}
+	t.staleDataSize = int64(index.GetStaleDataSize())
+	if t.cache != nil && t.cache.indexs != nil {
+		t.cache.indexs.SetHighPriority(t.fid, index, int64(index.Size()))
+		return nil
+	}
+	t.index = index
+	return nil
+}
+
+func (t *table) initKeyRange(index *pb.TableIndex) error {
+	n := numBlocks(index)
+	if n == 0 {
+		return errors.Errorf("table: %d has no blocks", t.fid)
+	}
+	first := index.GetOffsets()
+	if parts := index.GetIndexPartitions(); len(parts) > 0 {
+		first = parts
+	}
+	t.smallest = utils.SafeCopy(nil, first[0].GetKey())
+	if len(index.GetBiggestKey()) > 0 {
+		t.biggest = utils.SafeCopy(nil, index.GetBiggestKey())
+		return nil
+	}
+	// tables before biggestKey was recorded, walk the last block
+	b, err := t.blockAt(index, n-1)
+	if err != nil {
+		return err
+	}
+	itr := &blockIterator{tableID: t.fid, blockID: n - 1}
+	itr.setBlock(b)
+	for itr.seekToFirst(); itr.Valid(); itr.Next() {
+		t.biggest = append(t.biggest[:0], itr.key...)
+	}
+	if err := itr.Error(); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// readIndex decodes the index in front of the footer
+func (t *table) readIndex() (*pb.TableIndex, error) {
+	readPos := len(t.data) - 4
+	if t.version > 1 {
+		readPos -= footerSize
+	}
+	buf, err := t.read(readPos, 4)
+	if err != nil {
+		return nil, err
+	}
+	checksumLen := int(utils.BytesToU32(buf))
+	readPos -= checksumLen
+	checksum, err := t.read(readPos, checksumLen)
+	if err != nil {
+		return nil, err
+	}
+
+	readPos -= 4
+	if buf, err = t.read(readPos, 4); err != nil {
+		return nil, err
+	}
+	indexLen := int(utils.BytesToU32(buf))
+	readPos -= indexLen
+	data, err := t.read(readPos, indexLen)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.VerifyChecksum(data, checksum); err != nil {
+		return nil, errors.Wrapf(err, "failed to verify checksum for table: %d", t.fid)
+	}
+	index := &pb.TableIndex{}
+	if err := index.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	if index.GetKeyId() != 0 {
+		if index, err = t.openIndex(t.kr, index); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+// getIndex returns the pinned index or the cached one, decoding it again if
+// the index cache evicted it
+func (t *table) getIndex() (*pb.TableIndex, error) {
+	if t.index != nil {
+		return t.index, nil
+	}
+	if v, ok := t.cache.indexs.Get(t.fid); ok {
+		return v.(*pb.TableIndex), nil
+	}
+	index, err := t.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	t.cache.indexs.SetHighPriority(t.fid, index, int64(index.Size()))
+	return index, nil
+}
+
+// openIndex decrypts the index sealed by the builder
+func (t *table) openIndex(kr *KeyRegistry, outer *pb.TableIndex) (*pb.TableIndex, error) {
+	if kr == nil {
+		return nil, errors.Wrapf(utils.ErrInvalidDataKeyID, "table: %d is encrypted", t.fid)
+	}
+	dk, err := kr.DataKey(outer.GetKeyId())
+	if err != nil {
+		return nil, errors.Wrapf(err, "table: %d", t.fid)
+	}
+	data, err := t.decrypt(dk, outer.GetEncryptedIndex())
+	if err != nil {
+		return nil, err
+	}
+	index := &pb.TableIndex{}
+	if err := index.Unmarshal(data); err != nil {
+		return nil, errors.Wrapf(err, "table: %d wrong data key", t.fid)
+	}
+	t.dataKey = dk
+	return index, nil
+}
+
+// decrypt opens | encrypted | iv | into a new slice, the mmap is read only
+func (t *table) decrypt(dk *pb.DataKey, data []byte) ([]byte, error) {
+	if len(data) < aes.BlockSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	n := len(data) - aes.BlockSize
+	return utils.XORBlockAllocate(data[:n], dk.Data, data[n:])
+}
+
+// block returns the idx-th block from the block cache, or reads, decrypts,
+// decompresses and verifies it on a miss
+func (t *table) block(idx int) (*block, error) {
+	index, err := t.getIndex()
+	if err != nil {
+		return nil, err
+	}
+	return t.blockAt(index, idx)
+}
+
+func (t *table) blockAt(index *pb.TableIndex, idx int) (*block, error) {
+	ko, err := t.blockOffset(index, idx)
+	if err != nil {
+		return nil, err
+	}
+	var key uint64
+	if t.cache != nil && t.cache.blocks != nil {
+		key = blockCacheKey(t.fid, ko.GetOffset())
+		if b, ok := t.cache.blocks.Get(key); ok {
+			return b.(*block), nil
+		}
+	}
+	b, err := t.readBlock(idx, ko)
+	if err != nil {
+		return nil, err
+	}
+	if t.cache != nil && t.cache.blocks != nil {
+		t.cache.blocks.Set(key, b, int64(len(b.data)))
+	}
+	return b, nil
+}
+
+func (t *table) readBlock(idx int, ko *pb.BlockOffset) (*block, error) {
+	raw, err := t.read(int(ko.GetOffset()), int(ko.GetLen()))
+	if err != nil {
+		return nil, err
+	}
+	data := raw
+	if t.dataKey != nil {
+		if data, err = t.decrypt(t.dataKey, data); err != nil {
+			return nil, err
+		}
+	}
+	if t.version >= 3 {
+		if len(data) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		ct := utils.CompressionType(data[len(data)-1])
+		if data, err = utils.DecompressBlock(ct, data[:len(data)-1]); err != nil {
+			return nil, errors.Wrapf(err, "table: %d block %d", t.fid, idx)
+		}
+	}
+	if t.cache != nil && t.cache.blocks != nil && len(data) > 0 && &data[0] == &raw[0] {
+		// cached blocks outlive the mmap, don't let them point into it
+		data = append([]byte{}, data...)
+	}
+	return decodeBlock(data, int(ko.GetOffset()), t.version)
+}
+
+// get returns the first version of key's user key at or below key's version,
+// ErrKeyNotFound if the table has none. It checks the bloom filter, then
+// uses the blocks' hash index
+func (t *table) get(key []byte) (*utils.Entry, error) {
+	index, err := t.getIndex()
+	if err != nil {
+		return nil, err
+	}
+	return t.getIn(index, key, func(idx int) (*block, error) { return t.blockAt(index, idx) })
+}
+
+// getIn is get with the blocks coming from load
+func (t *table) getIn(index *pb.TableIndex, key []byte, load func(idx int) (*block, error)) (*utils.Entry, error) {
+	idx, err := t.seekBlock(index, key)
+	if err != nil {
+		return nil, err
+	}
+	for n := numBlocks(index); idx < n; idx++ {
+		ok, err := t.mayContain(index, idx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			b, err := load(idx)
+			if err != nil {
+				return nil, err
+			}
+			itr := &blockIterator{tableID: t.fid, blockID: idx}
+			itr.setBlock(b)
+			itr.seekPoint(key)
+			if itr.Valid() {
+				if e := itr.Item().Entry(); utils.SameKey(e.Key, key) {
+					return copyEntry(e), nil
+				}
+			}
+			if itr.err != nil && itr.err != io.EOF {
+				return nil, itr.err
+			}
+		}
+		// the versions below key may start the next block
+		if idx+1 >= n {
+			break
+		}
+		next, err := t.blockOffset(index, idx+1)
+		if err != nil {
+			return nil, err
+		}
+		if !utils.SameKey(next.GetKey(), key) {
+			break
+		}
+	}
+	return nil, utils.ErrKeyNotFound
+}
+
+// numBlocks is the number of data blocks in the table
+func numBlocks(index *pb.TableIndex) int {
+	if len(index.GetIndexPartitions()) > 0 {
+		return int(index.GetBlockCount())
+	}
+	return len(index.GetOffsets())
+}
+
+// partitionOf returns the index partition holding the idx-th block
+func partitionOf(index *pb.TableIndex, idx int) int {
+	firsts := index.GetPartitionFirstBlocks()
+	return sort.Search(len(firsts), func(i int) bool { return int(firsts[i]) > idx }) - 1
+}
+
+// blockOffset returns where the idx-th data block is, loading its index
+// partition if the index is partitioned
+func (t *table) blockOffset(index *pb.TableIndex, idx int) (*pb.BlockOffset, error) {
+	if idx < 0 || idx >= numBlocks(index) {
+		return nil, errors.Errorf("table: %d block %d out of index", t.fid, idx)
+	}
+	if len(index.GetIndexPartitions()) == 0 {
+		return index.GetOffsets()[idx], nil
+	}
+	p := partitionOf(index, idx)
+	part, err := t.indexPartition(index, p)
+	if err != nil {
+		return nil, err
+	}
+	local := idx - int(index.GetPartitionFirstBlocks()[p])
+	if local >= len(part.GetOffsets()) {
+		return nil, errors.Errorf("table: %d block %d out of partition %d", t.fid, idx, p)
+	}
+	return part.GetOffsets()[local], nil
+}
+
+// seekBlock returns the last block starting at or before key, 0 if key is
+// before the first one
+func (t *table) seekBlock(index *pb.TableIndex, key []byte) (int, error) {
+	search := func(offsets []*pb.BlockOffset) int {
+		i := sort.Search(len(offsets), func(i int) bool {
+			return utils.CompareKeys(offsets[i].GetKey(), key) > 0
+		}) - 1
+		if i < 0 {
+			return 0
+		}
+		return i
+	}
+	if len(index.GetIndexPartitions()) == 0 {
+		return search(index.GetOffsets()), nil
+	}
+	p := search(index.GetIndexPartitions())
+	part, err := t.indexPartition(index, p)
+	if err != nil {
+		return 0, err
+	}
+	return int(index.GetPartitionFirstBlocks()[p]) + search(part.GetOffsets()), nil
+}
+
+// mayContain asks the bloom filter covering the idx-th block about key
+func (t *table) mayContain(index *pb.TableIndex, idx int, key []byte) (bool, error) {
+	bf, err := t.filter(index, partitionOf(index, idx))
+	if err != nil || len(bf) == 0 {
+		return true, err
+	}
+	return bf.MayContainKey(utils.ParseKey(key)), nil
+}
+
+// filter returns the whole table filter, or the p-th filter partition
+func (t *table) filter(index *pb.TableIndex, p int) (utils.Filter, error) {
+	if len(index.GetIndexPartitions()) == 0 {
+		return index.GetBloomFilter(), nil
+	}
+	filters := index.GetFilterPartitions()
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	v, err := t.partition(filters[p], func(data []byte) (interface{}, error) {
+		return utils.Filter(append([]byte{}, data...)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(utils.Filter), nil
+}
+
+// mayContainPrefix reports whether the table may hold keys starting with
+// scanPrefix. It only knows when the table filters were built with the
+// configured PrefixExtractor and scanPrefix is long enough to have a prefix
+func (t *table) mayContainPrefix(scanPrefix []byte) (bool, error) {
+	prefix := t.opt.filterPrefix(scanPrefix)
+	if prefix == nil {
+		return true, nil
+	}
+	index, err := t.getIndex()
+	if err != nil {
+		return false, err
+	}
+	if index.GetPrefixExtractor() != t.opt.PrefixExtractor.Name() {
+		return true, nil
+	}
+	parts := index.GetIndexPartitions()
+	if len(parts) == 0 {
+		bf := utils.Filter(index.GetBloomFilter())
+		return len(bf) == 0 || bf.MayContainKey(prefix), nil
+	}
+	// the last partition starting before the prefix and the ones starting in it
+	p := sort.Search(len(parts), func(i int) bool {
+		return bytes.Compare(utils.ParseKey(parts[i].GetKey()), prefix) >= 0
+	}) - 1
+	if p < 0 {
+		p = 0
+	}
+	for ; p < len(parts); p++ {
+		if first := utils.ParseKey(parts[p].GetKey()); bytes.Compare(first, prefix) > 0 &&
+			!bytes.HasPrefix(first, prefix) {
+			break
+		}
+		bf, err := t.filter(index, p)
+		if err != nil {
+			return false, err
+		}
+		if len(bf) == 0 || bf.MayContainKey(prefix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t *table) indexPartition(index *pb.TableIndex, p int) (*pb.IndexPartition, error) {
+	v, err := t.partition(index.GetIndexPartitions()[p], func(data []byte) (interface{}, error) {
+		part := &pb.IndexPartition{}
+		return part, part.Unmarshal(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*pb.IndexPartition), nil
+}
+
+// partition reads | data | checksum | of an index or filter partition
+// through the block cache, where the decoded value is kept with high priority
+func (t *table) partition(bo *pb.BlockOffset, decode func([]byte) (interface{}, error)) (interface{}, error) {
+	var key uint64
+	if t.cache != nil && t.cache.blocks != nil {
+		key = blockCacheKey(t.fid, bo.GetOffset())
+		if v, ok := t.cache.blocks.Get(key); ok {
+			return v, nil
+		}
+	}
+	data, err := t.read(int(bo.GetOffset()), int(bo.GetLen()))
+	if err != nil {
+		return nil, err
+	}
+	if t.dataKey != nil {
+		if data, err = t.decrypt(t.dataKey, data); err != nil {
+			return nil, err
+		}
+	}
+	n := len(data) - 8
+	if n < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err := utils.VerifyChecksum(data[:n], data[n:]); err != nil {
+		return nil, errors.Wrapf(err, "table: %d partition at %d", t.fid, bo.GetOffset())
+	}
+	v, err := decode(data[:n])
+	if err != nil {
+		return nil, err
+	}
+	if t.cache != nil && t.cache.blocks != nil {
+		t.cache.blocks.SetHighPriority(key, v, int64(n))
+	}
+	return v, nil
+}
+
+// copyEntry detaches an entry from the block it was decoded from
+func copyEntry(e *utils.Entry) *utils.Entry {
+	cp := *e
+	cp.Key = utils.SafeCopy(nil, e.Key)
+	if e.Value != nil {
+		// an empty value is not a delete, keep it non-nil
+		cp.Value = append([]byte{}, e.Value...)
+	}
+	return &cp
+}
+
+// decodeBlock parses the trailer of an uncompressed block
+// | entries | [hash index] | offsets | len(offsets) | checksum | checksum len |
+// offsets are restart points since version 4 and every entry before that,
+// since version 5 the top bit of len(offsets) tells if a hash index is there
+func decodeBlock(data []byte, offset int, version uint32) (*block, error) {
+	b := &block{offset: offset, noMeta: version < 2}
+	readPos := len(data) - 4
+	if readPos < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b.chklen = int(utils.BytesToU32(data[readPos:]))
+	readPos -= b.chklen
+	if readPos < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b.checksum = data[readPos : readPos+b.chklen]
+
+	readPos -= 4
+	end := readPos + 4
+	numEntries := utils.BytesToU32(data[readPos : readPos+4])
+	withHashIndex := version >= 5 && numEntries&hasHashIndex != 0
+	numEntries &^= hasHashIndex
+	b.entriesIndexStart = readPos - int(numEntries)*4
+	if b.entriesIndexStart < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	offsets := utils.BytesToU32Slice(data[b.entriesIndexStart:readPos])
+	if version >= 4 {
+		b.restarts = offsets
+	} else {
+		b.entryOffsets = offsets
+	}
+	if withHashIndex {
+		// | buckets | len(buckets) | in front of the restarts
+		readPos = b.entriesIndexStart - 4
+		if readPos < 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		numBuckets := int(utils.BytesToU32(data[readPos:]))
+		if readPos-numBuckets < 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b.hashIndex = data[readPos-numBuckets : readPos]
+		b.entriesIndexStart = readPos - numBuckets
+	}
+	b.data = data[:end]
+	b.end = len(b.data)
+	if err := b.verifyCheckSum(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Close unmaps and closes the file
+func (t *table) Close() error {
+	if err := mmap.Munmap(t.data); err != nil {
+		t.fd.Close()
+		return err
+	}
+	return t.fd.Close()
+}
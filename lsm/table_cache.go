@@ -0,0 +1,241 @@
+package lsm
+
+import (
+	"container/list"
+	"sync"
+
+	"TLKV/utils"
+)
+
+// tableCache keeps at most Options.MaxOpenTables tables open and mapped.
+// Tables are opened on first use and reference counted; a table is only
+// closed once it is idle, so iterators never see it unmapped. When every open
+// table is in use the limit is exceeded until some of them are released.
+type tableCache struct {
+	lock     sync.Mutex
+	opt      *Options
+	kr       *KeyRegistry
+	cache    *cache
+	vlog     *valueLog // reads the values compaction folds or filters
+	tables   map[uint64]*tableEntry
+	removing map[*tableEntry]struct{} // removed but still in use
+	idle     *list.List               // idle open tables, the front was released last
+	numOpen  int
+	// ranges remembers the user key range of every table opened once, so
+	// range scans skip closed tables without opening them again
+	ranges map[uint64]keyRange
+	// sizes remembers the size and stale bytes of every table opened once,
+	// so compaction scoring doesn't open every table again
+	sizes map[uint64]tableSize
+	// allowedSeeks is the number of lookups each table may still answer
+	// with nothing before it is compacted, seekCompactions the tables out of
+	// budget in the order they ran out
+	allowedSeeks    map[uint64]int64
+	seekCompactions []uint64
+}
+
+type keyRange struct {
+	smallest, biggest []byte
+}
+
+type tableSize struct {
+	size, stale int64
+}
+
+type tableEntry struct {
+	t       *table
+	ref     int
+	elem    *list.Element // position in idle, nil while in use
+	removed bool          // closed on the last release instead of going idle
+}
+
+func newTableCache(opt *Options, kr *KeyRegistry, c *cache) *tableCache {
+	return &tableCache{
+		opt:      opt,
+		kr:       kr,
+		cache:    c,
+		tables:   make(map[uint64]*tableEntry),
+		removing: make(map[*tableEntry]struct{}),
+		idle:     list.New(),
+		ranges:   make(map[uint64]keyRange),
+		sizes:    make(map[uint64]tableSize),
+
+		allowedSeeks: make(map[uint64]int64),
+	}
+}
+
+// acquire returns the table fid, opening it if needed. Every acquire must be
+// paired with a release.
+func (tc *tableCache) acquire(fid uint64) (*table, error) {
+	tc.lock.Lock()
+	if e, ok := tc.tables[fid]; ok {
+		tc.use(e)
+		tc.lock.Unlock()
+		return e.t, nil
+	}
+	tc.lock.Unlock()
+
+	// open without the lock, mmapping a large file shouldn't block other readers
+	t, err := openTable(tc.opt, tc.kr, tc.cache, fid)
+	if err != nil {
+		return nil, err
+	}
+
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	if e, ok := tc.tables[fid]; ok {
+		// opened concurrently, keep the first one
+		t.Close()
+		tc.use(e)
+		return e.t, nil
+	}
+	e := &tableEntry{t: t, ref: 1}
+	tc.tables[fid] = e
+	tc.ranges[fid] = keyRange{utils.ParseKey(t.smallest), utils.ParseKey(t.biggest)}
+	tc.sizes[fid] = tableSize{int64(len(t.data)), t.staleDataSize}
+	if _, ok := tc.allowedSeeks[fid]; !ok && tc.opt.SeekCompactionBytesPerSeek > 0 {
+		tc.allowedSeeks[fid] = seekBudget(int64(len(t.data)), tc.opt.SeekCompactionBytesPerSeek)
+	}
+	tc.numOpen++
+	tc.evictIdle()
+	return t, nil
+}
+
+func (tc *tableCache) use(e *tableEntry) {
+	if e.elem != nil {
+		tc.idle.Remove(e.elem)
+		e.elem = nil
+	}
+	e.ref++
+}
+
+// release gives back a table returned by acquire
+func (tc *tableCache) release(t *table) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	e, ok := tc.tables[t.fid]
+	if !ok || e.t != t {
+		if e = tc.findRemoved(t); e == nil {
+			return
+		}
+	}
+	e.ref--
+	if e.ref > 0 {
+		return
+	}
+	if e.removed {
+		tc.close(e)
+		return
+	}
+	e.elem = tc.idle.PushFront(e)
+	tc.evictIdle()
+}
+
+// findRemoved looks t up among removed tables, they are few and short lived
+func (tc *tableCache) findRemoved(t *table) *tableEntry {
+	for e := range tc.removing {
+		if e.t == t {
+			return e
+		}
+	}
+	return nil
+}
+
+// remove forgets the table fid once it was deleted by compaction, it is
+// closed as soon as the last reader releases it
+func (tc *tableCache) remove(fid uint64) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	tc.cache.purge(fid)
+	delete(tc.ranges, fid)
+	delete(tc.sizes, fid)
+	delete(tc.allowedSeeks, fid)
+	e, ok := tc.tables[fid]
+	if !ok {
+		return
+	}
+	delete(tc.tables, fid)
+	if e.ref == 0 {
+		tc.idle.Remove(e.elem)
+		tc.close(e)
+		return
+	}
+	e.removed = true
+	tc.removing[e] = struct{}{}
+}
+
+// evictIdle closes the least recently released tables over the limit
+func (tc *tableCache) evictIdle() {
+	if tc.opt.MaxOpenTables <= 0 {
+		return
+	}
+	for tc.numOpen > tc.opt.MaxOpenTables && tc.idle.Len() > 0 {
+		e := tc.idle.Remove(tc.idle.Back()).(*tableEntry)
+		e.elem = nil
+		delete(tc.tables, e.t.fid)
+		tc.close(e)
+	}
+}
+
+func (tc *tableCache) close(e *tableEntry) {
+	tc.numOpen--
+	delete(tc.removing, e)
+	if e.removed {
+		// readers that outlived remove may have cached blocks again
+		tc.cache.purge(e.t.fid)
+	}
+	// the file is already mapped read only, a failed close can't lose data
+	_ = e.t.Close()
+}
+
+// newIterators returns iterators over the tables in fids that may hold keys
+// inside the bounds and prefix of opt, the rest are never opened once their
+// key range is known. Each iterator releases its table when closed.
+func (tc *tableCache) newIterators(fids []uint64, opt *utils.Options) ([]utils.Iterator, error) {
+	var iters []utils.Iterator
+	for _, fid := range fids {
+		tc.lock.Lock()
+		kr, known := tc.ranges[fid]
+		tc.lock.Unlock()
+		if known && !opt.Overlaps(kr.smallest, kr.biggest) {
+			continue
+		}
+		t, err := tc.acquire(fid)
+		if err != nil {
+			for _, itr := range iters {
+				itr.Close()
+			}
+			return nil, err
+		}
+		if !t.overlaps(opt) {
+			tc.release(t)
+			continue
+		}
+		itr := t.NewIterator(opt).(*tableIterator)
+		itr.onClose = func() { tc.release(t) }
+		iters = append(iters, itr)
+	}
+	return iters, nil
+}
+
+// Close closes every table, tables still in use are closed anyway
+func (tc *tableCache) Close() error {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	var err error
+	for fid, e := range tc.tables {
+		if cerr := e.t.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(tc.tables, fid)
+	}
+	for e := range tc.removing {
+		if cerr := e.t.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	tc.removing = make(map[*tableEntry]struct{})
+	tc.idle.Init()
+	tc.numOpen = 0
+	return err
+}
@@ -0,0 +1,67 @@
+package lsm
+
+import (
+	"testing"
+
+	"TLKV/utils"
+)
+
+func TestTableCacheEvictsIdle(t *testing.T) {
+	opt := testOptions(t)
+	opt.MaxOpenTables = 2
+	for fid := uint64(1); fid <= 4; fid++ {
+		buildTestTable(t, opt, fid, 0, 10)
+	}
+	tc := newTableCache(opt, nil, newCache(opt))
+	defer tc.Close()
+	open := func(want ...uint64) {
+		t.Helper()
+		if tc.numOpen != len(want) || len(tc.tables) != len(want) {
+			t.Fatalf("%d tables open, want %v", tc.numOpen, want)
+		}
+		for _, fid := range want {
+			if _, ok := tc.tables[fid]; !ok {
+				t.Fatalf("table %d is closed, want %v open", fid, want)
+			}
+		}
+	}
+
+	var tables []*table
+	for fid := uint64(1); fid <= 3; fid++ {
+		tbl, err := tc.acquire(fid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, tbl)
+	}
+	// tables in use are never closed, the limit is exceeded instead
+	open(1, 2, 3)
+	tc.release(tables[0])
+	open(2, 3)
+	tc.release(tables[1])
+	open(2, 3)
+	t4, err := tc.acquire(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open(3, 4)
+
+	// a table held across evictions stays readable
+	key := utils.KeyWithTs([]byte("k0005"), 3)
+	if _, err := tables[2].get(key); err != nil {
+		t.Fatal(err)
+	}
+	tc.release(tables[2])
+	tc.release(t4)
+
+	// evicted tables are opened again on demand
+	t1, err := tc.acquire(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := t1.get(key); err != nil {
+		t.Fatal(err)
+	}
+	tc.release(t1)
+	open(1, 4)
+}
@@ -0,0 +1,76 @@
+package lsm
+
+// TableInfo describes a table for operators
+type TableInfo struct {
+	ID       uint64
+	Level    int
+	Size     int64
+	KeyCount uint32
+	// StaleDataSize is the bytes of versions a later compaction will drop,
+	// StaleRatio their share of Size
+	StaleDataSize int64
+	StaleRatio    float64
+	// MaxVersion is the newest version in the table
+	MaxVersion uint64
+	// MaxExpiresAt is the unix time every entry has expired by, 0 if some
+	// entry never expires
+	MaxExpiresAt uint64
+}
+
+func (t *table) info(level int) (TableInfo, error) {
+	index, err := t.getIndex()
+	if err != nil {
+		return TableInfo{}, err
+	}
+	ti := TableInfo{
+		ID:            t.fid,
+		Level:         level,
+		Size:          int64(len(t.data)),
+		KeyCount:      index.GetKeyCount(),
+		StaleDataSize: int64(index.GetStaleDataSize()),
+		MaxVersion:    index.GetMaxVersion(),
+		MaxExpiresAt:  index.GetMaxExpiresAt(),
+	}
+	if ti.Size > 0 {
+		ti.StaleRatio = float64(ti.StaleDataSize) / float64(ti.Size)
+	}
+	return ti, nil
+}
+
+// tableInfos describes the tables of levels, the table ids of each level
+func (tc *tableCache) tableInfos(levels [][]uint64) ([]TableInfo, error) {
+	var infos []TableInfo
+	for l, fids := range levels {
+		for _, fid := range fids {
+			t, err := tc.acquire(fid)
+			if err != nil {
+				return nil, err
+			}
+			ti, err := t.info(l)
+			tc.release(t)
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, ti)
+		}
+	}
+	return infos, nil
+}
+
+// tableSize returns the size and stale bytes of table fid, opening it only
+// if it wasn't opened yet
+func (tc *tableCache) tableSize(fid uint64) (tableSize, error) {
+	tc.lock.Lock()
+	ts, ok := tc.sizes[fid]
+	tc.lock.Unlock()
+	if ok {
+		return ts, nil
+	}
+	t, err := tc.acquire(fid)
+	if err != nil {
+		return tableSize{}, err
+	}
+	ts = tableSize{int64(len(t.data)), t.staleDataSize}
+	tc.release(t)
+	return ts, nil
+}
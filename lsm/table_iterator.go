@@ -0,0 +1,272 @@
+package lsm
+
+import (
+	"bytes"
+	"io"
+	"math"
+
+	"TLKV/pb"
+	"TLKV/utils"
+)
+
+// tableIterator walks the entries of a table in either direction, stopping
+// at the bounds and prefix of its options
+type tableIterator struct {
+	t        *table
+	index    *pb.TableIndex
+	opt      utils.Options
+	bi       blockIterator
+	blockIdx int
+	// descending iteration decodes a block forward once and walks it back
+	entries []*utils.Entry
+	pos     int
+	err     error
+	onClose func()
+}
+
+// NewIterator returns an iterator over t, it has to be positioned with
+// Rewind or Seek before use
+func (t *table) NewIterator(opt *utils.Options) utils.Iterator {
+	itr := &tableIterator{t: t, err: io.EOF}
+	if opt != nil {
+		itr.opt = *opt
+	}
+	index, err := t.getIndex()
+	if err != nil {
+		itr.err = err
+		return itr
+	}
+	itr.index = index
+	return itr
+}
+
+// overlaps reports whether the table may hold keys inside the bounds and
+// prefix of opt, so iterators can skip it without reading a block
+func (t *table) overlaps(opt *utils.Options) bool {
+	if !opt.Overlaps(utils.ParseKey(t.smallest), utils.ParseKey(t.biggest)) {
+		return false
+	}
+	if len(opt.Prefix) > 0 {
+		ok, err := t.mayContainPrefix(opt.Prefix)
+		return ok || err != nil
+	}
+	return true
+}
+
+func (itr *tableIterator) Valid() bool {
+	return itr.err == nil
+}
+
+func (itr *tableIterator) Item() utils.Item {
+	if !itr.opt.IsAsc {
+		return &Item{e: itr.entries[itr.pos]}
+	}
+	return itr.bi.Item()
+}
+
+// Rewind moves to the first entry inside the bounds
+func (itr *tableIterator) Rewind() {
+	if itr.index == nil {
+		// the index failed to load, keep reporting why
+		return
+	}
+	if itr.opt.IsAsc {
+		if itr.opt.LowerBound != nil || len(itr.opt.Prefix) > 0 {
+			itr.Seek(utils.KeyWithTs(itr.start(), math.MaxUint64))
+			return
+		}
+		if !itr.loadBlock(0) {
+			return
+		}
+		itr.bi.seekToFirst()
+		itr.skipForward()
+		return
+	}
+	if end := itr.end(); end != nil {
+		// every version of end sorts after this one
+		itr.Seek(utils.KeyWithTs(end, math.MaxUint64))
+		return
+	}
+	if !itr.loadEntries(numBlocks(itr.index) - 1) {
+		return
+	}
+	itr.pos = len(itr.entries) - 1
+	itr.skipBackward()
+}
+
+// start is the first user key the bounds and prefix allow
+func (itr *tableIterator) start() []byte {
+	start := itr.opt.LowerBound
+	if p := itr.opt.Prefix; len(p) > 0 && (start == nil || bytes.Compare(p, start) > 0) {
+		start = p
+	}
+	return start
+}
+
+// end is the user key the bounds and prefix stop before, nil if unbounded
+func (itr *tableIterator) end() []byte {
+	end := itr.opt.UpperBound
+	if p := prefixSuccessor(itr.opt.Prefix); p != nil && (end == nil || bytes.Compare(p, end) < 0) {
+		end = p
+	}
+	return end
+}
+
+// prefixSuccessor returns the smallest key greater than every key with the
+// prefix, nil if there is none
+func prefixSuccessor(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			succ := append([]byte{}, prefix[:i+1]...)
+			succ[i]++
+			return succ
+		}
+	}
+	return nil
+}
+
+// Seek moves to the first entry >= key ascending, or the last <= key descending
+func (itr *tableIterator) Seek(key []byte) {
+	if itr.index == nil {
+		return
+	}
+	if itr.opt.IsAsc {
+		if start := itr.start(); start != nil {
+			if lower := utils.KeyWithTs(start, math.MaxUint64); utils.CompareKeys(key, lower) < 0 {
+				key = lower
+			}
+		}
+		idx, err := itr.t.seekBlock(itr.index, key)
+		if err != nil {
+			itr.err = err
+			return
+		}
+		if !itr.loadBlock(idx) {
+			return
+		}
+		itr.bi.seek(key)
+		itr.skipForward()
+		return
+	}
+	end := itr.end()
+	if end != nil {
+		if upper := utils.KeyWithTs(end, math.MaxUint64); utils.CompareKeys(key, upper) > 0 {
+			key = upper
+		}
+	}
+	idx, err := itr.t.seekBlock(itr.index, key)
+	if err != nil {
+		itr.err = err
+		return
+	}
+	if !itr.loadEntries(idx) {
+		return
+	}
+	// the last entry <= key and before end
+	itr.pos = len(itr.entries) - 1
+	for itr.pos >= 0 && (utils.CompareKeys(itr.entries[itr.pos].Key, key) > 0 ||
+		end != nil && bytes.Compare(utils.ParseKey(itr.entries[itr.pos].Key), end) >= 0) {
+		itr.pos--
+	}
+	itr.skipBackward()
+}
+
+func (itr *tableIterator) Next() {
+	if itr.err != nil {
+		return
+	}
+	if itr.opt.IsAsc {
+		itr.bi.Next()
+		itr.skipForward()
+		return
+	}
+	itr.pos--
+	itr.skipBackward()
+}
+
+// skipForward moves to the next block when the current one is exhausted and
+// stops at the upper bound
+func (itr *tableIterator) skipForward() {
+	for !itr.bi.Valid() {
+		if itr.bi.err != io.EOF {
+			itr.err = itr.bi.err
+			return
+		}
+		if !itr.loadBlock(itr.blockIdx + 1) {
+			return
+		}
+		itr.bi.seekToFirst()
+	}
+	if !itr.inBounds(itr.bi.key) {
+		itr.err = io.EOF
+		return
+	}
+	itr.err = nil
+}
+
+// skipBackward moves to the previous block when pos fell off the current one
+// and stops at the lower bound
+func (itr *tableIterator) skipBackward() {
+	for itr.pos < 0 {
+		if !itr.loadEntries(itr.blockIdx - 1) {
+			return
+		}
+		itr.pos = len(itr.entries) - 1
+	}
+	if !itr.inBounds(itr.entries[itr.pos].Key) {
+		itr.err = io.EOF
+		return
+	}
+	itr.err = nil
+}
+
+func (itr *tableIterator) inBounds(key []byte) bool {
+	return itr.opt.InBounds(utils.ParseKey(key))
+}
+
+// loadBlock points the block iterator at the idx-th block
+func (itr *tableIterator) loadBlock(idx int) bool {
+	if idx < 0 || idx >= numBlocks(itr.index) {
+		itr.err = io.EOF
+		return false
+	}
+	b, err := itr.t.blockAt(itr.index, idx)
+	if err != nil {
+		itr.err = err
+		return false
+	}
+	itr.blockIdx = idx
+	itr.bi.tableID, itr.bi.blockID = itr.t.fid, idx
+	itr.bi.setBlock(b)
+	return true
+}
+
+// loadEntries decodes the idx-th block for descending iteration
+func (itr *tableIterator) loadEntries(idx int) bool {
+	if !itr.loadBlock(idx) {
+		return false
+	}
+	itr.entries = itr.entries[:0]
+	for itr.bi.seekToFirst(); itr.bi.Valid(); itr.bi.Next() {
+		itr.entries = append(itr.entries, copyEntry(itr.bi.Item().Entry()))
+	}
+	if itr.bi.err != io.EOF {
+		itr.err = itr.bi.err
+		return false
+	}
+	return true
+}
+
+// Close runs the release hook of the table cache, if any
+func (itr *tableIterator) Close() error {
+	if itr.onClose != nil {
+		itr.onClose()
+		itr.onClose = nil
+	}
+	return nil
+}
+
+// Error returns why the iterator stopped, io.EOF at the end of its range
+func (itr *tableIterator) Error() error {
+	return itr.err
+}
@@ -0,0 +1,153 @@
+package lsm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"testing"
+
+	"TLKV/utils"
+)
+
+func testOptions(t *testing.T) *Options {
+	return &Options{WorkDir: t.TempDir(), SSTableMaxSz: 1 << 20, BlockSize: 256, BloomFalsePositive: 0.01}
+}
+
+// buildTestTable writes keys k<from>..k<to> with versions 1-3 to table fid
+func buildTestTable(t *testing.T, opt *Options, fid uint64, from, to int) [][]byte {
+	tb := newTableBuilerWithSSTSize(opt, opt.SSTableMaxSz, 1, nil)
+	var keys [][]byte
+	for i := from; i < to; i++ {
+		for ts := 1; ts <= 3; ts++ {
+			keys = append(keys, utils.KeyWithTs([]byte(fmt.Sprintf("k%04d", i)), uint64(ts)))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return utils.CompareKeys(keys[i], keys[j]) < 0 })
+	for _, k := range keys {
+		tb.AddKey(&utils.Entry{Key: k, Value: []byte("v")})
+	}
+	if err := os.WriteFile(utils.FileNameSSTable(opt.WorkDir, fid), tb.finish(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestTableIteratorBounds(t *testing.T) {
+	for _, ps := range []int{0, 200} {
+		opt := testOptions(t)
+		opt.IndexPartitionSize = ps
+		keys := buildTestTable(t, opt, 1, 0, 500)
+		tbl, err := openTable(opt, nil, nil, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases := []utils.Options{
+			{}, {LowerBound: []byte("k0100")}, {UpperBound: []byte("k0200")},
+			{LowerBound: []byte("k0100"), UpperBound: []byte("k0100x")},
+			{Prefix: []byte("k02")}, {Prefix: []byte("k02"), LowerBound: []byte("k0250")},
+			{LowerBound: []byte("zz")}, {UpperBound: []byte("a")}, {Prefix: []byte("k9")},
+		}
+		for _, c := range cases {
+			for _, asc := range []bool{true, false} {
+				c.IsAsc = asc
+				var want [][]byte
+				for _, k := range keys {
+					if c.InBounds(utils.ParseKey(k)) {
+						want = append(want, k)
+					}
+				}
+				if !asc {
+					for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+						want[i], want[j] = want[j], want[i]
+					}
+				}
+				it := tbl.NewIterator(&c)
+				var got [][]byte
+				for it.Rewind(); it.Valid(); it.Next() {
+					got = append(got, append([]byte{}, it.Item().Entry().Key...))
+				}
+				if len(got) != len(want) {
+					t.Fatalf("partition %d %+v: got %d keys, want %d", ps, c, len(got), len(want))
+				}
+				for i := range got {
+					if !bytes.Equal(got[i], want[i]) {
+						t.Fatalf("partition %d %+v: key %d = %q, want %q", ps, c, i, got[i], want[i])
+					}
+				}
+			}
+		}
+		tbl.Close()
+	}
+}
+
+func TestTableIteratorSeekDescending(t *testing.T) {
+	opt := testOptions(t)
+	buildTestTable(t, opt, 1, 0, 500)
+	tbl, err := openTable(opt, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+
+	it := tbl.NewIterator(&utils.Options{})
+	it.Seek(utils.KeyWithTs([]byte("k0300"), 2))
+	if e := it.Item().Entry(); string(utils.ParseKey(e.Key)) != "k0300" || utils.ParseTs(e.Key) != 2 {
+		t.Fatalf("seek landed on %q", e.Key)
+	}
+	it.Next()
+	if utils.ParseTs(it.Item().Entry().Key) != 3 {
+		t.Fatal("next after descending seek")
+	}
+
+	// a seek past the upper bound or prefix starts at the last key inside it
+	for _, c := range []utils.Options{
+		{UpperBound: []byte("k0200")},
+		{Prefix: []byte("k01")},
+		{UpperBound: []byte("k0200"), Prefix: []byte("k01")},
+	} {
+		it := tbl.NewIterator(&c)
+		it.Seek(utils.KeyWithTs([]byte("k0450"), 1))
+		if !it.Valid() {
+			t.Fatalf("%+v: seek past the end found nothing", c)
+		}
+		want := "k0199"
+		if got := string(utils.ParseKey(it.Item().Entry().Key)); got != want {
+			t.Fatalf("%+v: seek landed on %s, want %s", c, got, want)
+		}
+	}
+}
+
+func TestTableIteratorIndexError(t *testing.T) {
+	opt := testOptions(t)
+	opt.IndexCacheSize = 1 << 20
+	buildTestTable(t, opt, 1, 0, 100)
+	c := newCache(opt)
+	tbl, err := openTable(opt, nil, c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+
+	// drop the cached index and damage it on disk so the reload fails
+	c.indexs.Del(tbl.fid)
+	f, err := os.OpenFile(utils.FileNameSSTable(opt.WorkDir, 1), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := int64(len(tbl.data) - footerSize - 4 - 12)
+	if _, err := f.WriteAt([]byte{0xde, 0xad, 0xbe, 0xef}, pos); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	it := tbl.NewIterator(&utils.Options{IsAsc: true}).(*tableIterator)
+	it.Rewind()
+	if it.Valid() {
+		t.Fatal("iterator over a table with a broken index is valid")
+	}
+	if err := it.Error(); err == nil || err == io.EOF {
+		t.Fatalf("err = %v, want the index error", err)
+	}
+}
@@ -0,0 +1,230 @@
+package lsm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"testing"
+	"unsafe"
+
+	"TLKV/pb"
+	"TLKV/utils"
+)
+
+// writeV1Table writes entries in the version 1 layout, which had no footer
+// and no meta bytes: | block ... | index | index len | checksum | checksum len |
+// with blocks | header | diffKey | expiresAt | value | ... | offsets | count | checksum | checksum len |
+func writeV1Table(t *testing.T, opt *Options, fid uint64, entries []*utils.Entry, perBlock int) {
+	var out []byte
+	index := &pb.TableIndex{KeyCount: uint32(len(entries))}
+	var hashes []uint32
+	for start := 0; start < len(entries); start += perBlock {
+		end := min(start+perBlock, len(entries))
+		var data []byte
+		var offsets []uint32
+		baseKey := entries[start].Key
+		for _, e := range entries[start:end] {
+			offsets = append(offsets, uint32(len(data)))
+			overlap := 0
+			if len(offsets) > 1 {
+				for overlap < len(e.Key) && overlap < len(baseKey) && e.Key[overlap] == baseKey[overlap] {
+					overlap++
+				}
+			}
+			h := header{overlap: uint16(overlap), diff: uint16(len(e.Key) - overlap)}
+			data = append(data, (*[headerSize]byte)(unsafe.Pointer(&h))[:]...)
+			data = append(data, e.Key[overlap:]...)
+			data = binary.AppendUvarint(data, e.ExpiresAt)
+			data = append(data, e.Value...)
+			hashes = append(hashes, utils.Hash(utils.ParseKey(e.Key)))
+			if ts := utils.ParseTs(e.Key); ts > index.MaxVersion {
+				index.MaxVersion = ts
+			}
+		}
+		data = append(data, utils.U32SliceToBytes(offsets)...)
+		data = append(data, utils.U32ToBytes(uint32(len(offsets)))...)
+		sum := utils.U64ToBytes(utils.CalculateChecksum(data))
+		data = append(data, sum...)
+		data = append(data, utils.U32ToBytes(uint32(len(sum)))...)
+		index.Offsets = append(index.Offsets, &pb.BlockOffset{Key: baseKey, Offset: uint32(len(out)), Len: uint32(len(data))})
+		out = append(out, data...)
+	}
+	index.BloomFilter = utils.NewFilter(hashes, utils.BloomBitsPerKey(len(hashes), 0.01))
+	idx, err := index.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := utils.U64ToBytes(utils.CalculateChecksum(idx))
+	out = append(out, idx...)
+	out = append(out, utils.U32ToBytes(uint32(len(idx)))...)
+	out = append(out, sum...)
+	out = append(out, utils.U32ToBytes(uint32(len(sum)))...)
+	if err := os.WriteFile(utils.FileNameSSTable(opt.WorkDir, fid), out, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersion1Table(t *testing.T) {
+	opt := testOptions(t)
+	var entries []*utils.Entry
+	for i := 0; i < 200; i++ {
+		e := &utils.Entry{Key: utils.KeyWithTs([]byte(fmt.Sprintf("key%04d", i)), uint64(i+1)), Value: []byte(fmt.Sprintf("val%d", i))}
+		if i%10 == 0 {
+			// deletes were written as nil values
+			e.Value = nil
+		}
+		if i%7 == 0 {
+			e.ExpiresAt = 100
+		}
+		entries = append(entries, e)
+	}
+	writeV1Table(t, opt, 1, entries, 16)
+
+	tbl, err := openTable(opt, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Close()
+	if tbl.version != 1 {
+		t.Fatalf("version = %d", tbl.version)
+	}
+	if !bytes.Equal(tbl.biggest, entries[len(entries)-1].Key) {
+		t.Fatalf("biggest = %q", tbl.biggest)
+	}
+
+	it := tbl.NewIterator(&utils.Options{IsAsc: true})
+	i := 0
+	for it.Rewind(); it.Valid(); it.Next() {
+		got, want := it.Item().Entry(), entries[i]
+		if !bytes.Equal(got.Key, want.Key) || !bytes.Equal(got.Value, want.Value) ||
+			(got.Value == nil) != (want.Value == nil) || got.ExpiresAt != want.ExpiresAt {
+			t.Fatalf("entry %d = %+v, want %+v", i, got, want)
+		}
+		i++
+	}
+	if i != len(entries) {
+		t.Fatalf("iterated %d entries, want %d", i, len(entries))
+	}
+
+	for _, want := range entries {
+		got, err := tbl.get(want.Key)
+		if err != nil {
+			t.Fatalf("get %q: %v", want.Key, err)
+		}
+		if !bytes.Equal(got.Value, want.Value) || got.IsDeletedOrExpiredAt(utils.SystemClock.Now()) != (want.Value == nil || want.ExpiresAt != 0) {
+			t.Fatalf("get %q = %+v", want.Key, got)
+		}
+	}
+}
+
+func TestOpenTableRejectsGarbage(t *testing.T) {
+	opt := testOptions(t)
+	if err := os.WriteFile(utils.FileNameSSTable(opt.WorkDir, 1), bytes.Repeat([]byte{7}, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openTable(opt, nil, nil, 1); err == nil {
+		t.Fatal("opened a file that is not a table")
+	}
+}
+
+// TestTableFormats round trips a table through every layout the builder
+// can write
+func TestTableFormats(t *testing.T) {
+	cases := []struct {
+		name string
+		set  func(opt *Options)
+	}{
+		{"plain", func(opt *Options) {}},
+		{"restart-interval", func(opt *Options) { opt.BlockRestartInterval = 4 }},
+		{"hash-index", func(opt *Options) { opt.BlockHashIndex = true }},
+		{"partitioned-index", func(opt *Options) {
+			opt.IndexPartitionSize = 200
+			opt.IndexCacheSize = 1 << 20
+		}},
+		{"blocked-bloom", func(opt *Options) {
+			opt.FilterPolicies = []utils.FilterPolicy{utils.NewBlockedBloomPolicy(10)}
+		}},
+		{"xor", func(opt *Options) { opt.FilterPolicies = []utils.FilterPolicy{utils.NewXorPolicy()} }},
+		{"prefix", func(opt *Options) { opt.PrefixExtractor = utils.NewFixedPrefix(3) }},
+		{"lz", func(opt *Options) { opt.BlockCompression = []utils.CompressionType{utils.LZCompression} }},
+		{"flate", func(opt *Options) {
+			opt.BlockCompression = []utils.CompressionType{utils.FlateCompression}
+			opt.BlockCacheSize = 1 << 20
+		}},
+		{"everything", func(opt *Options) {
+			opt.BlockRestartInterval = 8
+			opt.BlockHashIndex = true
+			opt.IndexPartitionSize = 300
+			opt.IndexCacheSize = 1 << 20
+			opt.BlockCacheSize = 1 << 20
+			opt.FilterPolicies = []utils.FilterPolicy{utils.NewXorPolicy()}
+			opt.PrefixExtractor = utils.NewFixedPrefix(3)
+			opt.BlockCompression = []utils.CompressionType{utils.LZCompression}
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opt := testOptions(t)
+			c.set(opt)
+			keys := buildTestTable(t, opt, 1, 0, 500)
+			tbl, err := openTable(opt, nil, newCache(opt), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tbl.Close()
+
+			it := tbl.NewIterator(&utils.Options{IsAsc: true})
+			i := 0
+			for it.Rewind(); it.Valid(); it.Next() {
+				if i >= len(keys) || !bytes.Equal(it.Item().Entry().Key, keys[i]) {
+					t.Fatalf("entry %d is %q", i, it.Item().Entry().Key)
+				}
+				i++
+			}
+			it.Close()
+			if i != len(keys) {
+				t.Fatalf("iterated %d of %d entries", i, len(keys))
+			}
+
+			for n := 0; n < 500; n += 7 {
+				key := []byte(fmt.Sprintf("k%04d", n))
+				for ts := uint64(1); ts <= 3; ts++ {
+					e, err := tbl.get(utils.KeyWithTs(key, ts))
+					if err != nil {
+						t.Fatalf("get %s@%d: %v", key, ts, err)
+					}
+					if !bytes.Equal(utils.ParseKey(e.Key), key) || utils.ParseTs(e.Key) != ts {
+						t.Fatalf("get %s@%d = %q", key, ts, e.Key)
+					}
+				}
+				// a newer read sees the newest version
+				if e, err := tbl.get(utils.KeyWithTs(key, 100)); err != nil || utils.ParseTs(e.Key) != 3 {
+					t.Fatalf("get %s@100: %v", key, err)
+				}
+			}
+			for _, k := range []string{"a", "k0000x", "k0250x", "k0499x", "z"} {
+				if _, err := tbl.get(utils.KeyWithTs([]byte(k), 100)); err != utils.ErrKeyNotFound {
+					t.Fatalf("get %s: %v", k, err)
+				}
+			}
+
+			if opt.PrefixExtractor != nil {
+				if ok, err := tbl.mayContainPrefix([]byte("k02")); err != nil || !ok {
+					t.Fatalf("prefix k02: %v, %v", ok, err)
+				}
+				misses := 0
+				for n := 0; n < 100; n++ {
+					if ok, err := tbl.mayContainPrefix([]byte(fmt.Sprintf("x%02d", n))); err != nil {
+						t.Fatal(err)
+					} else if !ok {
+						misses++
+					}
+				}
+				if misses < 90 {
+					t.Fatalf("only %d absent prefixes filtered", misses)
+				}
+			}
+		})
+	}
+}
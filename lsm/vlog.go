@@ -0,0 +1,198 @@
+package lsm
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"sync"
+
+	"TLKV/file"
+	"TLKV/utils"
+
+	"github.com/pkg/errors"
+)
+
+// valueLog keeps values of at least Options.ValueThreshold bytes out of the
+// LSM tree, their entries hold a utils.ValuePtr instead. Values are appended
+// to the newest file in the WAL format, encrypted like the WAL, and a new
+// file is started once it reaches ValueLogFileSize. Files live as long as
+// the DB: there is no value log GC yet, so values overwritten or deleted
+// since keep their space.
+type valueLog struct {
+	opt  *Options
+	keys file.DataKeys
+	// lock guards files, readers look up the file of a pointer under it
+	lock  sync.RWMutex
+	files map[uint32]*file.WalFile
+	// cur is appended to under DB.writeLock, nil until the first value
+	cur    *file.WalFile
+	maxFid uint32
+}
+
+// openValueLog opens the value log files in opt.WorkDir. Records after the
+// last one a WAL points to were never acknowledged, the newest file is
+// appended to after them.
+func openValueLog(opt *Options, keys file.DataKeys) (*valueLog, error) {
+	vlog := &valueLog{opt: opt, keys: keys, files: make(map[uint32]*file.WalFile)}
+	infos, err := os.ReadDir(opt.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		fid := utils.VlogFID(info.Name())
+		if fid == 0 {
+			continue
+		}
+		vf, err := file.OpenVlogFile(&file.Options{FID: fid, Dir: opt.WorkDir, Keys: keys})
+		if err != nil {
+			vlog.close()
+			return nil, err
+		}
+		vlog.files[uint32(fid)] = vf
+		if uint32(fid) > vlog.maxFid {
+			vlog.cur, vlog.maxFid = vf, uint32(fid)
+		}
+	}
+	return vlog, nil
+}
+
+// write moves the values of entries reaching ValueThreshold to the value
+// log and leaves pointers to them in their place, DB.writeLock is held.
+// Deletes and merge operands stay in the tree. With SyncWrites the values
+// are synced before the WAL records pointing to them are written.
+func (vlog *valueLog) write(entries []*utils.Entry) error {
+	if vlog.opt.ValueThreshold <= 0 {
+		return nil
+	}
+	var big []*utils.Entry
+	var size int64
+	for _, e := range entries {
+		if vlog.moves(e) {
+			big = append(big, e)
+			size += int64(utils.EstimateWalCodecSize(e))
+		}
+	}
+	if len(big) == 0 {
+		return nil
+	}
+	if vlog.cur == nil || vlog.cur.Size() > int64(utils.WalFileHeaderSize) &&
+		vlog.cur.Size()+size > vlog.opt.ValueLogFileSize {
+		if err := vlog.rotate(); err != nil {
+			return err
+		}
+	}
+	// pointers address files with 32 bits
+	if vlog.cur.Size()+size > math.MaxUint32 {
+		return utils.ErrTxnTooBig
+	}
+	ptrs, err := vlog.cur.Append(big...)
+	if err != nil {
+		return err
+	}
+	if vlog.opt.SyncWrites {
+		if err := vlog.cur.Sync(); err != nil {
+			return err
+		}
+	}
+	for i, e := range big {
+		e.Value = ptrs[i].Encode()
+		e.Meta |= utils.BitValuePointer
+	}
+	return nil
+}
+
+// moves reports whether write moves the value of e to the value log
+func (vlog *valueLog) moves(e *utils.Entry) bool {
+	threshold := vlog.opt.ValueThreshold
+	return threshold > 0 && e.Meta&(utils.BitDelete|utils.BitMerge) == 0 && len(e.Value) >= threshold
+}
+
+// arenaSize is the arena size of e in the memtable after write, a value
+// moved to the value log leaves a pointer there
+func (vlog *valueLog) arenaSize(e *utils.Entry) int64 {
+	if vlog.moves(e) {
+		return arenaSize(e) - int64(len(e.Value)) + utils.ValuePtrSize
+	}
+	return arenaSize(e)
+}
+
+// rotate starts the next file, the previous one stays open for reads
+func (vlog *valueLog) rotate() error {
+	fid := vlog.maxFid + 1
+	vf, err := file.OpenVlogFile(&file.Options{FID: uint64(fid), Dir: vlog.opt.WorkDir, Keys: vlog.keys})
+	if err != nil {
+		return err
+	}
+	if err := utils.SyncDir(vlog.opt.WorkDir); err != nil {
+		vf.Close()
+		return err
+	}
+	vlog.lock.Lock()
+	vlog.files[fid] = vf
+	vlog.lock.Unlock()
+	vlog.cur, vlog.maxFid = vf, fid
+	return nil
+}
+
+// value returns the value of e, read from the value log if e holds a
+// pointer. Files are never removed while the DB is open, so no lock on the
+// tables e came from is needed.
+func (vlog *valueLog) value(e *utils.Entry) ([]byte, error) {
+	if e.Meta&utils.BitValuePointer == 0 {
+		return e.Value, nil
+	}
+	var vp utils.ValuePtr
+	if err := vp.Decode(e.Value); err != nil {
+		return nil, err
+	}
+	if vlog == nil {
+		return nil, errors.Wrap(utils.ErrBadValuePointer, "no value log")
+	}
+	vlog.lock.RLock()
+	vf, ok := vlog.files[vp.Fid]
+	vlog.lock.RUnlock()
+	if !ok {
+		return nil, errors.Wrapf(utils.ErrBadValuePointer, "value log %d doesn't exist", vp.Fid)
+	}
+	rec, err := vf.Read(vp)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(utils.ParseKey(rec.Key), utils.ParseKey(e.Key)) {
+		return nil, errors.Wrapf(utils.ErrBadValuePointer, "%+v holds another key", vp)
+	}
+	return rec.Value, nil
+}
+
+// resolve returns e with the value it points to, e itself if it holds none
+func (vlog *valueLog) resolve(e *utils.Entry) (*utils.Entry, error) {
+	if e.Meta&utils.BitValuePointer == 0 {
+		return e, nil
+	}
+	val, err := vlog.value(e)
+	if err != nil {
+		return nil, err
+	}
+	out := *e
+	out.Value, out.Meta = val, e.Meta&^utils.BitValuePointer
+	return &out, nil
+}
+
+// sync flushes the file being appended to
+func (vlog *valueLog) sync() error {
+	if vlog.cur == nil {
+		return nil
+	}
+	return vlog.cur.Sync()
+}
+
+// close closes every file without syncing it
+func (vlog *valueLog) close() error {
+	var err error
+	for _, vf := range vlog.files {
+		if cerr := vf.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package lsm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"TLKV/utils"
+)
+
+// prefixDropFilter drops values starting with "drop" and upper cases the
+// ones starting with "up"
+type prefixDropFilter struct{}
+
+func (prefixDropFilter) Name() string { return "prefix-drop" }
+
+func (prefixDropFilter) Filter(ctx CompactionFilterContext, key []byte, e *utils.Entry) (CompactionDecision, []byte) {
+	switch {
+	case bytes.HasPrefix(e.Value, []byte("drop")):
+		return CompactionDrop, nil
+	case bytes.HasPrefix(e.Value, []byte("up")):
+		return CompactionChangeValue, bytes.ToUpper(e.Value)
+	}
+	return CompactionKeep, nil
+}
+
+func bigValue(prefix string, i int) string {
+	return fmt.Sprintf("%s-%d-%s", prefix, i, strings.Repeat("x", 100))
+}
+
+// dirContains reports whether a file of dir with ext holds text
+func dirContains(t *testing.T, dir, ext string, text []byte) (found bool, files int) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = found || bytes.Contains(b, text)
+	}
+	return found, len(paths)
+}
+
+func TestDBValueLog(t *testing.T) {
+	opt, clock := testDBOptions(t)
+	opt.ValueThreshold = 64
+	opt.ValueLogFileSize = 4 << 10
+	opt.MergeOperator = appendOperator{}
+	opt.CompactionFilter = prefixDropFilter{}
+	opt.EncryptionKey = bytes.Repeat([]byte{5}, 32)
+	db := openTestDB(t, opt)
+	for i := 0; i < 100; i++ {
+		if err := db.Set(utils.NewEntry([]byte(fmt.Sprintf("k%03d", i)), []byte(bigValue("plain", i)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Set(utils.NewEntry([]byte("small"), []byte("tiny"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(utils.NewEntry([]byte("ttl"), []byte(bigValue("ttl", 0))).WithTTLOnWrite(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// operands fold onto a base in the value log
+	if err := db.Merge([]byte("k007"), []byte("op")); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "k042", bigValue("plain", 42))
+	mustGet(t, db, "k007", bigValue("plain", 7)+",op")
+	mustGet(t, db, "small", "tiny")
+	mustGet(t, db, "ttl", bigValue("ttl", 0))
+
+	// the values sit in several value log files, encrypted like the WALs
+	if found, n := dirContains(t, opt.WorkDir, ".vlog", []byte("plain-42")); found || n < 2 {
+		t.Fatalf("value log: %d files, plaintext %v", n, found)
+	}
+	if found, _ := dirContains(t, opt.WorkDir, ".wal", []byte("small")); found {
+		t.Fatal("wal holds plaintext")
+	}
+
+	// WALs replay their pointers
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db = openTestDB(t, opt)
+	mustGet(t, db, "k099", bigValue("plain", 99))
+	mustGet(t, db, "k007", bigValue("plain", 7)+",op")
+
+	// the compaction filter sees the values, not their pointers
+	if err := db.Set(utils.NewEntry([]byte("k001"), []byte(bigValue("drop", 1)))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(utils.NewEntry([]byte("k002"), []byte(bigValue("up", 2)))); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Minute)
+	compactDB(t, db, opt.withDefaults().MaxLevelNum-1)
+	mustMiss(t, db, "k001")
+	mustMiss(t, db, "ttl")
+	mustGet(t, db, "k002", strings.ToUpper(bigValue("up", 2)))
+	mustGet(t, db, "k003", bigValue("plain", 3))
+	mustGet(t, db, "k007", bigValue("plain", 7)+",op")
+
+	itr, err := db.NewIterator(&utils.Options{Prefix: []byte("k09")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		e := itr.Item().Entry()
+		if want := bigValue("plain", 90+n); string(e.Value) != want || e.Meta&utils.BitValuePointer != 0 {
+			t.Fatalf("iterated %s=%q, want %q", e.Key, e.Value, want)
+		}
+		n++
+	}
+	if err := itr.Error(); err != nil || n != 10 {
+		t.Fatalf("iterated %d keys, %v", n, err)
+	}
+	itr.Close()
+
+	// conditional writes compare the values
+	if err := db.CompareAndSet([]byte("k004"), []byte(bigValue("plain", 4)), []byte("swapped")); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "k004", "swapped")
+}
+
+func TestValueLogBadPointer(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	vlog, err := openValueLog(opt, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vlog.close()
+	e := &utils.Entry{Key: utils.KeyWithTs([]byte("k"), 1), Meta: utils.BitValuePointer,
+		Value: utils.ValuePtr{Fid: 9, Len: 10, Offset: 40}.Encode()}
+	if _, err := vlog.value(e); !errors.Is(err, utils.ErrBadValuePointer) {
+		t.Fatalf("missing file: %v", err)
+	}
+	e.Value = e.Value[:5]
+	if _, err := vlog.value(e); err != utils.ErrBadValuePointer {
+		t.Fatalf("short pointer: %v", err)
+	}
+	// a value written for another key isn't returned for this one
+	opt.ValueThreshold = 1
+	other := &utils.Entry{Key: utils.KeyWithTs([]byte("other"), 1), Value: []byte("secret")}
+	if err := vlog.write([]*utils.Entry{other}); err != nil {
+		t.Fatal(err)
+	}
+	e.Value = other.Value
+	if _, err := vlog.value(e); !errors.Is(err, utils.ErrBadValuePointer) {
+		t.Fatalf("pointer to another key: %v", err)
+	}
+	if got, err := vlog.value(other); err != nil || string(got) != "secret" {
+		t.Fatalf("read %q, %v", got, err)
+	}
+	var none *valueLog
+	if _, err := none.value(other); !errors.Is(err, utils.ErrBadValuePointer) {
+		t.Fatalf("no value log: %v", err)
+	}
+}
+
+func TestDBValueLogRejectedBatch(t *testing.T) {
+	opt, _ := testDBOptions(t)
+	opt.ValueThreshold = 64
+	db := openTestDB(t, opt)
+	var batch []*utils.Entry
+	for i := 0; i < 2000; i++ {
+		batch = append(batch, utils.NewEntry([]byte(fmt.Sprintf("k%04d", i)), []byte(bigValue("plain", i))))
+	}
+	// even with pointers the batch is bigger than a memtable
+	if err := db.WriteBatch(batch); err != utils.ErrTxnTooBig {
+		t.Fatalf("write: %v", err)
+	}
+	if db.vlog.cur != nil {
+		t.Fatalf("rejected batch wrote %d bytes to the value log", db.vlog.cur.Size())
+	}
+	if err := db.WriteBatch(batch[:100]); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, db, "k0099", bigValue("plain", 99))
+}
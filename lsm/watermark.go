@@ -0,0 +1,70 @@
+package lsm
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// watermarkSamples is about how many samples versionWatermark keeps over
+// ScanRetention, the version it returns lags the exact one by at most
+// ScanRetention/watermarkSamples
+const watermarkSamples = 256
+
+// versionWatermark maps wall time to the newest version written by then.
+// Versions are unix seconds only while writes are rare, a burst of writes in
+// one second runs them ahead of the clock, so they can't be compared with a
+// time directly. It samples the newest version about once per step and
+// keeps the samples of the last retention.
+type versionWatermark struct {
+	lock      sync.Mutex
+	retention time.Duration
+	step      time.Duration
+	samples   []versionSample // oldest first
+}
+
+// versionSample pairs a version with a time it was the newest version at
+type versionSample struct {
+	at      time.Time
+	version uint64
+}
+
+func newVersionWatermark(retention time.Duration) *versionWatermark {
+	return &versionWatermark{retention: retention, step: max(retention/watermarkSamples, time.Second)}
+}
+
+// record notes that version is the newest version at time at
+func (w *versionWatermark) record(at time.Time, version uint64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	// the last sample follows the writes until it is a step away from the
+	// one before it, so every sample stays a version and its write time
+	if n := len(w.samples); n >= 2 && at.Sub(w.samples[n-2].at) < w.step {
+		w.samples[n-1] = versionSample{at, version}
+	} else {
+		w.samples = append(w.samples, versionSample{at, version})
+	}
+	w.trim(at.Add(-w.retention))
+}
+
+// versionAt returns the newest version sampled at or before t, 0 if no
+// sample is that old. It may lag the version written last before t by up
+// to a step, it is never ahead of it.
+func (w *versionWatermark) versionAt(t time.Time) uint64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.trim(t)
+	if len(w.samples) == 0 || w.samples[0].at.After(t) {
+		return 0
+	}
+	return w.samples[0].version
+}
+
+// trim drops the samples older than the newest one at or before t, nothing
+// asks for them anymore
+func (w *versionWatermark) trim(t time.Time) {
+	i := sort.Search(len(w.samples), func(i int) bool { return w.samples[i].at.After(t) })
+	if i > 1 {
+		w.samples = append(w.samples[:0], w.samples[i-1:]...)
+	}
+}
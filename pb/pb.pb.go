@@ -4,6 +4,7 @@
 package pb
 
 import (
+	encoding_binary "encoding/binary"
 	fmt "fmt"
 	proto "github.com/golang/protobuf/proto"
 	io "io"
@@ -25,18 +26,24 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 type ManifestChange_Operation int32
 
 const (
-	ManifestChange_CREATE ManifestChange_Operation = 0
-	ManifestChange_DELETE ManifestChange_Operation = 1
+	ManifestChange_CREATE               ManifestChange_Operation = 0
+	ManifestChange_DELETE               ManifestChange_Operation = 1
+	ManifestChange_CREATE_COLUMN_FAMILY ManifestChange_Operation = 2
+	ManifestChange_DELETE_COLUMN_FAMILY ManifestChange_Operation = 3
 )
 
 var ManifestChange_Operation_name = map[int32]string{
 	0: "CREATE",
 	1: "DELETE",
+	2: "CREATE_COLUMN_FAMILY",
+	3: "DELETE_COLUMN_FAMILY",
 }
 
 var ManifestChange_Operation_value = map[string]int32{
-	"CREATE": 0,
-	"DELETE": 1,
+	"CREATE":               0,
+	"DELETE":               1,
+	"CREATE_COLUMN_FAMILY": 2,
+	"DELETE_COLUMN_FAMILY": 3,
 }
 
 func (x ManifestChange_Operation) String() string {
@@ -243,6 +250,9 @@ type ManifestChange struct {
 	Op                   ManifestChange_Operation `protobuf:"varint,2,opt,name=Op,proto3,enum=pb.ManifestChange_Operation" json:"Op,omitempty"`
 	Level                uint32                   `protobuf:"varint,3,opt,name=Level,proto3" json:"Level,omitempty"`
 	Checksum             []byte                   `protobuf:"bytes,4,opt,name=Checksum,proto3" json:"Checksum,omitempty"`
+	ColumnFamily         uint32                   `protobuf:"varint,5,opt,name=ColumnFamily,proto3" json:"ColumnFamily,omitempty"`
+	ColumnFamilyName     string                   `protobuf:"bytes,6,opt,name=ColumnFamilyName,proto3" json:"ColumnFamilyName,omitempty"`
+	ColumnFamilyOptions  *ColumnFamilyOptions     `protobuf:"bytes,7,opt,name=ColumnFamilyOptions,proto3" json:"ColumnFamilyOptions,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                 `json:"-"`
 	XXX_unrecognized     []byte                   `json:"-"`
 	XXX_sizecache        int32                    `json:"-"`
@@ -309,12 +319,42 @@ func (m *ManifestChange) GetChecksum() []byte {
 	return nil
 }
 
+func (m *ManifestChange) GetColumnFamily() uint32 {
+	if m != nil {
+		return m.ColumnFamily
+	}
+	return 0
+}
+
+func (m *ManifestChange) GetColumnFamilyName() string {
+	if m != nil {
+		return m.ColumnFamilyName
+	}
+	return ""
+}
+
+func (m *ManifestChange) GetColumnFamilyOptions() *ColumnFamilyOptions {
+	if m != nil {
+		return m.ColumnFamilyOptions
+	}
+	return nil
+}
+
 type TableIndex struct {
 	Offsets              []*BlockOffset `protobuf:"bytes,1,rep,name=offsets,proto3" json:"offsets,omitempty"`
 	BloomFilter          []byte         `protobuf:"bytes,2,opt,name=bloomFilter,proto3" json:"bloomFilter,omitempty"`
 	MaxVersion           uint64         `protobuf:"varint,3,opt,name=maxVersion,proto3" json:"maxVersion,omitempty"`
 	KeyCount             uint32         `protobuf:"varint,4,opt,name=keyCount,proto3" json:"keyCount,omitempty"`
 	StaleDataSize        uint32         `protobuf:"varint,5,opt,name=staleDataSize,proto3" json:"staleDataSize,omitempty"`
+	MaxExpiresAt         uint64         `protobuf:"varint,7,opt,name=maxExpiresAt,proto3" json:"maxExpiresAt,omitempty"`
+	KeyId                uint64         `protobuf:"varint,8,opt,name=keyId,proto3" json:"keyId,omitempty"`
+	EncryptedIndex       []byte         `protobuf:"bytes,9,opt,name=encryptedIndex,proto3" json:"encryptedIndex,omitempty"`
+	IndexPartitions      []*BlockOffset `protobuf:"bytes,10,rep,name=indexPartitions,proto3" json:"indexPartitions,omitempty"`
+	FilterPartitions     []*BlockOffset `protobuf:"bytes,11,rep,name=filterPartitions,proto3" json:"filterPartitions,omitempty"`
+	PartitionFirstBlocks []uint32       `protobuf:"varint,12,rep,name=partitionFirstBlocks,packed,proto3" json:"partitionFirstBlocks,omitempty"`
+	BlockCount           uint32         `protobuf:"varint,13,opt,name=blockCount,proto3" json:"blockCount,omitempty"`
+	PrefixExtractor      string         `protobuf:"bytes,14,opt,name=prefixExtractor,proto3" json:"prefixExtractor,omitempty"`
+	BiggestKey           []byte         `protobuf:"bytes,15,opt,name=biggestKey,proto3" json:"biggestKey,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
 	XXX_unrecognized     []byte         `json:"-"`
 	XXX_sizecache        int32          `json:"-"`
@@ -388,6 +428,69 @@ func (m *TableIndex) GetStaleDataSize() uint32 {
 	return 0
 }
 
+func (m *TableIndex) GetMaxExpiresAt() uint64 {
+	if m != nil {
+		return m.MaxExpiresAt
+	}
+	return 0
+}
+
+func (m *TableIndex) GetKeyId() uint64 {
+	if m != nil {
+		return m.KeyId
+	}
+	return 0
+}
+
+func (m *TableIndex) GetEncryptedIndex() []byte {
+	if m != nil {
+		return m.EncryptedIndex
+	}
+	return nil
+}
+
+func (m *TableIndex) GetIndexPartitions() []*BlockOffset {
+	if m != nil {
+		return m.IndexPartitions
+	}
+	return nil
+}
+
+func (m *TableIndex) GetFilterPartitions() []*BlockOffset {
+	if m != nil {
+		return m.FilterPartitions
+	}
+	return nil
+}
+
+func (m *TableIndex) GetPartitionFirstBlocks() []uint32 {
+	if m != nil {
+		return m.PartitionFirstBlocks
+	}
+	return nil
+}
+
+func (m *TableIndex) GetBlockCount() uint32 {
+	if m != nil {
+		return m.BlockCount
+	}
+	return 0
+}
+
+func (m *TableIndex) GetPrefixExtractor() string {
+	if m != nil {
+		return m.PrefixExtractor
+	}
+	return ""
+}
+
+func (m *TableIndex) GetBiggestKey() []byte {
+	if m != nil {
+		return m.BiggestKey
+	}
+	return nil
+}
+
 type BlockOffset struct {
 	Key                  []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
 	Offset               uint32   `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
@@ -451,6 +554,300 @@ func (m *BlockOffset) GetLen() uint32 {
 	return 0
 }
 
+type IndexPartition struct {
+	Offsets              []*BlockOffset `protobuf:"bytes,1,rep,name=offsets,proto3" json:"offsets,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *IndexPartition) Reset()         { *m = IndexPartition{} }
+func (m *IndexPartition) String() string { return proto.CompactTextString(m) }
+func (*IndexPartition) ProtoMessage()    {}
+func (*IndexPartition) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{6}
+}
+func (m *IndexPartition) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *IndexPartition) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_IndexPartition.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *IndexPartition) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IndexPartition.Merge(m, src)
+}
+func (m *IndexPartition) XXX_Size() int {
+	return m.Size()
+}
+func (m *IndexPartition) XXX_DiscardUnknown() {
+	xxx_messageInfo_IndexPartition.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IndexPartition proto.InternalMessageInfo
+
+func (m *IndexPartition) GetOffsets() []*BlockOffset {
+	if m != nil {
+		return m.Offsets
+	}
+	return nil
+}
+
+type DataKey struct {
+	KeyId                uint64   `protobuf:"varint,1,opt,name=keyId,proto3" json:"keyId,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Iv                   []byte   `protobuf:"bytes,3,opt,name=iv,proto3" json:"iv,omitempty"`
+	CreatedAt            int64    `protobuf:"varint,4,opt,name=createdAt,proto3" json:"createdAt,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DataKey) Reset()         { *m = DataKey{} }
+func (m *DataKey) String() string { return proto.CompactTextString(m) }
+func (*DataKey) ProtoMessage()    {}
+func (*DataKey) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{7}
+}
+func (m *DataKey) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DataKey) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DataKey.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *DataKey) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DataKey.Merge(m, src)
+}
+func (m *DataKey) XXX_Size() int {
+	return m.Size()
+}
+func (m *DataKey) XXX_DiscardUnknown() {
+	xxx_messageInfo_DataKey.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DataKey proto.InternalMessageInfo
+
+func (m *DataKey) GetKeyId() uint64 {
+	if m != nil {
+		return m.KeyId
+	}
+	return 0
+}
+
+func (m *DataKey) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *DataKey) GetIv() []byte {
+	if m != nil {
+		return m.Iv
+	}
+	return nil
+}
+
+func (m *DataKey) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+// ColumnFamilyOptions are the lsm.Options a column family was created with.
+type ColumnFamilyOptions struct {
+	MemTableSize               int64    `protobuf:"varint,1,opt,name=memTableSize,proto3" json:"memTableSize,omitempty"`
+	SsTableMaxSz               int64    `protobuf:"varint,2,opt,name=ssTableMaxSz,proto3" json:"ssTableMaxSz,omitempty"`
+	BlockSize                  uint32   `protobuf:"varint,3,opt,name=blockSize,proto3" json:"blockSize,omitempty"`
+	BloomFalsePositive         float64  `protobuf:"fixed64,4,opt,name=bloomFalsePositive,proto3" json:"bloomFalsePositive,omitempty"`
+	BaseLevelSize              int64    `protobuf:"varint,5,opt,name=baseLevelSize,proto3" json:"baseLevelSize,omitempty"`
+	LevelSizeMultiplier        uint32   `protobuf:"varint,6,opt,name=levelSizeMultiplier,proto3" json:"levelSizeMultiplier,omitempty"`
+	TableSizeMultiplier        uint32   `protobuf:"varint,7,opt,name=tableSizeMultiplier,proto3" json:"tableSizeMultiplier,omitempty"`
+	BaseTableSize              int64    `protobuf:"varint,8,opt,name=baseTableSize,proto3" json:"baseTableSize,omitempty"`
+	NumLevelZeroTables         uint32   `protobuf:"varint,9,opt,name=numLevelZeroTables,proto3" json:"numLevelZeroTables,omitempty"`
+	MaxLevelNum                uint32   `protobuf:"varint,10,opt,name=maxLevelNum,proto3" json:"maxLevelNum,omitempty"`
+	BlockCompression           []uint32 `protobuf:"varint,11,rep,name=blockCompression,packed,proto3" json:"blockCompression,omitempty"`
+	BlockRestartInterval       uint32   `protobuf:"varint,12,opt,name=blockRestartInterval,proto3" json:"blockRestartInterval,omitempty"`
+	BlockHashIndex             bool     `protobuf:"varint,13,opt,name=blockHashIndex,proto3" json:"blockHashIndex,omitempty"`
+	IndexPartitionSize         uint32   `protobuf:"varint,14,opt,name=indexPartitionSize,proto3" json:"indexPartitionSize,omitempty"`
+	MemTableBloomBitsPerKey    uint32   `protobuf:"varint,15,opt,name=memTableBloomBitsPerKey,proto3" json:"memTableBloomBitsPerKey,omitempty"`
+	StaleDataCompactionRatio   float64  `protobuf:"fixed64,16,opt,name=staleDataCompactionRatio,proto3" json:"staleDataCompactionRatio,omitempty"`
+	SeekCompactionBytesPerSeek int64    `protobuf:"varint,17,opt,name=seekCompactionBytesPerSeek,proto3" json:"seekCompactionBytesPerSeek,omitempty"`
+	XXX_NoUnkeyedLiteral       struct{} `json:"-"`
+	XXX_unrecognized           []byte   `json:"-"`
+	XXX_sizecache              int32    `json:"-"`
+}
+
+func (m *ColumnFamilyOptions) Reset()         { *m = ColumnFamilyOptions{} }
+func (m *ColumnFamilyOptions) String() string { return proto.CompactTextString(m) }
+func (*ColumnFamilyOptions) ProtoMessage()    {}
+func (*ColumnFamilyOptions) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f80abaa17e25ccc8, []int{8}
+}
+func (m *ColumnFamilyOptions) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ColumnFamilyOptions) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ColumnFamilyOptions.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ColumnFamilyOptions) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ColumnFamilyOptions.Merge(m, src)
+}
+func (m *ColumnFamilyOptions) XXX_Size() int {
+	return m.Size()
+}
+func (m *ColumnFamilyOptions) XXX_DiscardUnknown() {
+	xxx_messageInfo_ColumnFamilyOptions.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ColumnFamilyOptions proto.InternalMessageInfo
+
+func (m *ColumnFamilyOptions) GetMemTableSize() int64 {
+	if m != nil {
+		return m.MemTableSize
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetSsTableMaxSz() int64 {
+	if m != nil {
+		return m.SsTableMaxSz
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetBlockSize() uint32 {
+	if m != nil {
+		return m.BlockSize
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetBloomFalsePositive() float64 {
+	if m != nil {
+		return m.BloomFalsePositive
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetBaseLevelSize() int64 {
+	if m != nil {
+		return m.BaseLevelSize
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetLevelSizeMultiplier() uint32 {
+	if m != nil {
+		return m.LevelSizeMultiplier
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetTableSizeMultiplier() uint32 {
+	if m != nil {
+		return m.TableSizeMultiplier
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetBaseTableSize() int64 {
+	if m != nil {
+		return m.BaseTableSize
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetNumLevelZeroTables() uint32 {
+	if m != nil {
+		return m.NumLevelZeroTables
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetMaxLevelNum() uint32 {
+	if m != nil {
+		return m.MaxLevelNum
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetBlockCompression() []uint32 {
+	if m != nil {
+		return m.BlockCompression
+	}
+	return nil
+}
+
+func (m *ColumnFamilyOptions) GetBlockRestartInterval() uint32 {
+	if m != nil {
+		return m.BlockRestartInterval
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetBlockHashIndex() bool {
+	if m != nil {
+		return m.BlockHashIndex
+	}
+	return false
+}
+
+func (m *ColumnFamilyOptions) GetIndexPartitionSize() uint32 {
+	if m != nil {
+		return m.IndexPartitionSize
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetMemTableBloomBitsPerKey() uint32 {
+	if m != nil {
+		return m.MemTableBloomBitsPerKey
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetStaleDataCompactionRatio() float64 {
+	if m != nil {
+		return m.StaleDataCompactionRatio
+	}
+	return 0
+}
+
+func (m *ColumnFamilyOptions) GetSeekCompactionBytesPerSeek() int64 {
+	if m != nil {
+		return m.SeekCompactionBytesPerSeek
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterEnum("pb.ManifestChange_Operation", ManifestChange_Operation_name, ManifestChange_Operation_value)
 	proto.RegisterType((*KV)(nil), "pb.KV")
@@ -459,43 +856,80 @@ func init() {
 	proto.RegisterType((*ManifestChange)(nil), "pb.ManifestChange")
 	proto.RegisterType((*TableIndex)(nil), "pb.TableIndex")
 	proto.RegisterType((*BlockOffset)(nil), "pb.BlockOffset")
+	proto.RegisterType((*IndexPartition)(nil), "pb.IndexPartition")
+	proto.RegisterType((*DataKey)(nil), "pb.DataKey")
+	proto.RegisterType((*ColumnFamilyOptions)(nil), "pb.ColumnFamilyOptions")
 }
 
 func init() { proto.RegisterFile("pb.proto", fileDescriptor_f80abaa17e25ccc8) }
 
 var fileDescriptor_f80abaa17e25ccc8 = []byte{
-	// 485 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x52, 0x5d, 0x6e, 0xda, 0x4c,
-	0x14, 0xcd, 0x18, 0x62, 0xe0, 0x12, 0xf3, 0xf1, 0x8d, 0xaa, 0xc8, 0xea, 0x0f, 0xb2, 0xdc, 0x3e,
-	0x50, 0x29, 0xe2, 0x21, 0x5d, 0x01, 0x21, 0x54, 0xb2, 0x20, 0x42, 0x9a, 0x20, 0x5e, 0xd1, 0x18,
-	0x5f, 0x1a, 0xcb, 0xbf, 0xf2, 0x0c, 0x16, 0xe9, 0x4a, 0xba, 0x81, 0xae, 0xa0, 0x8f, 0xdd, 0x40,
-	0x1f, 0xbb, 0x84, 0x8a, 0x6e, 0xa4, 0x9a, 0xc1, 0x20, 0x50, 0xfb, 0x76, 0xcf, 0xb9, 0x77, 0xce,
-	0x9c, 0x39, 0x77, 0xa0, 0x99, 0xfb, 0x83, 0xbc, 0xc8, 0x64, 0x46, 0x8d, 0xdc, 0x77, 0xbf, 0x11,
-	0x30, 0x26, 0x0b, 0xda, 0x85, 0x5a, 0x84, 0xcf, 0x36, 0x71, 0x48, 0xff, 0x8a, 0xa9, 0x92, 0xbe,
-	0x80, 0xcb, 0x92, 0xc7, 0x1b, 0xb4, 0x0d, 0xcd, 0xed, 0x01, 0x7d, 0x05, 0xad, 0x8d, 0xc0, 0x62,
-	0x99, 0xa0, 0xe4, 0x76, 0x4d, 0x77, 0x9a, 0x8a, 0x78, 0x40, 0xc9, 0xa9, 0x0d, 0x8d, 0x12, 0x0b,
-	0x11, 0x66, 0xa9, 0x5d, 0x77, 0x48, 0xbf, 0xce, 0x0e, 0x90, 0xbe, 0x01, 0xc0, 0x6d, 0x1e, 0x16,
-	0x28, 0x96, 0x5c, 0xda, 0x97, 0xba, 0xd9, 0xaa, 0x98, 0xa1, 0xa4, 0x14, 0xea, 0x5a, 0xd0, 0xd4,
-	0x82, 0xba, 0x56, 0x37, 0x09, 0x59, 0x20, 0x4f, 0x96, 0x61, 0x60, 0x83, 0x43, 0xfa, 0x16, 0x6b,
-	0xee, 0x09, 0x2f, 0x70, 0x1d, 0x30, 0x27, 0x8b, 0x69, 0x28, 0x24, 0xbd, 0x06, 0x23, 0x2a, 0x6d,
-	0xe2, 0xd4, 0xfa, 0xed, 0x5b, 0x73, 0x90, 0xfb, 0x83, 0xc9, 0x82, 0x19, 0x51, 0xe9, 0x0e, 0xe1,
-	0xff, 0x07, 0x9e, 0x86, 0x6b, 0x14, 0x72, 0xf4, 0xc4, 0xd3, 0x4f, 0xf8, 0x88, 0x92, 0xde, 0x40,
-	0x63, 0xa5, 0x81, 0xa8, 0x4e, 0x50, 0x75, 0xe2, 0x7c, 0x8e, 0x1d, 0x46, 0xdc, 0xaf, 0x04, 0x3a,
-	0xe7, 0x3d, 0xda, 0x01, 0xc3, 0x0b, 0x74, 0x4a, 0x75, 0x66, 0x78, 0x01, 0xbd, 0x01, 0x63, 0x96,
-	0xeb, 0x84, 0x3a, 0xb7, 0xaf, 0xff, 0xd6, 0x1a, 0xcc, 0x72, 0x2c, 0xb8, 0x0c, 0xb3, 0x94, 0x19,
-	0xb3, 0x5c, 0x45, 0x3a, 0xc5, 0x12, 0x63, 0x1d, 0x9c, 0xc5, 0xf6, 0x80, 0xbe, 0x84, 0xe6, 0xe8,
-	0x09, 0x57, 0x91, 0xd8, 0x24, 0x3a, 0xb6, 0x2b, 0x76, 0xc4, 0xee, 0x5b, 0x68, 0x1d, 0x25, 0x28,
-	0x80, 0x39, 0x62, 0xe3, 0xe1, 0x7c, 0xdc, 0xbd, 0x50, 0xf5, 0xfd, 0x78, 0x3a, 0x9e, 0x8f, 0xbb,
-	0xc4, 0xfd, 0x4e, 0x00, 0xe6, 0xdc, 0x8f, 0xd1, 0x4b, 0x03, 0xdc, 0xd2, 0xf7, 0xd0, 0xc8, 0xd6,
-	0x6b, 0x81, 0xf2, 0xf0, 0xc8, 0xff, 0x94, 0xb1, 0xbb, 0x38, 0x5b, 0x45, 0x33, 0xcd, 0xb3, 0x43,
-	0x9f, 0x3a, 0xd0, 0xf6, 0xe3, 0x2c, 0x4b, 0x3e, 0x86, 0xb1, 0xc4, 0xa2, 0xda, 0xf4, 0x29, 0x45,
-	0x7b, 0x00, 0x09, 0xdf, 0x2e, 0xaa, 0xad, 0xd6, 0xf4, 0xc3, 0x4f, 0x18, 0x65, 0x3e, 0xc2, 0xe7,
-	0x51, 0xb6, 0x49, 0xa5, 0x36, 0x6f, 0xb1, 0x23, 0xa6, 0xef, 0xc0, 0x12, 0x92, 0xc7, 0x78, 0xcf,
-	0x25, 0x7f, 0x0c, 0x3f, 0xa3, 0xde, 0xbb, 0xc5, 0xce, 0x49, 0xd7, 0x83, 0xf6, 0x89, 0xb7, 0x7f,
-	0x7c, 0xc4, 0x6b, 0x30, 0xf7, 0x7e, 0xb5, 0x3f, 0x8b, 0x55, 0x48, 0x4d, 0xc6, 0x98, 0x56, 0x59,
-	0xaa, 0xf2, 0xae, 0xfb, 0x63, 0xd7, 0x23, 0x3f, 0x77, 0x3d, 0xf2, 0x6b, 0xd7, 0x23, 0x5f, 0x7e,
-	0xf7, 0x2e, 0x7c, 0x53, 0x7f, 0xf4, 0x0f, 0x7f, 0x02, 0x00, 0x00, 0xff, 0xff, 0x9e, 0xf4, 0xb3,
-	0x68, 0xf4, 0x02, 0x00, 0x00,
+	// 1025 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x56, 0xdd, 0x6e, 0x1a, 0x47,
+	0x14, 0xce, 0x2e, 0x84, 0x9f, 0x83, 0xc1, 0x64, 0x62, 0x25, 0xab, 0x34, 0xb5, 0xd0, 0xaa, 0xaa,
+	0x68, 0x15, 0xa1, 0xca, 0xbd, 0x69, 0x1b, 0xa9, 0x92, 0x4d, 0xb0, 0x8a, 0x6c, 0x8c, 0x35, 0x76,
+	0x5d, 0xb5, 0x37, 0xd6, 0x00, 0xc7, 0xf6, 0x88, 0xfd, 0xd3, 0xce, 0x80, 0x20, 0x4f, 0x52, 0xa9,
+	0x8f, 0x91, 0x97, 0xe8, 0x65, 0x1f, 0xa1, 0x72, 0x5f, 0xa1, 0x0f, 0x50, 0xcd, 0x59, 0x16, 0x16,
+	0x7b, 0x53, 0x29, 0x77, 0x73, 0xbe, 0xef, 0x9c, 0x61, 0xce, 0x77, 0x7e, 0x16, 0xa8, 0x44, 0xa3,
+	0x4e, 0x14, 0x87, 0x3a, 0x64, 0x76, 0x34, 0x72, 0x3f, 0x58, 0x60, 0x9f, 0x5c, 0xb1, 0x26, 0x14,
+	0xa6, 0xb8, 0x74, 0xac, 0x96, 0xd5, 0xde, 0xe1, 0xe6, 0xc8, 0xf6, 0xe0, 0xe9, 0x5c, 0x78, 0x33,
+	0x74, 0x6c, 0xc2, 0x12, 0x83, 0x7d, 0x06, 0xd5, 0x99, 0xc2, 0xf8, 0xda, 0x47, 0x2d, 0x9c, 0x02,
+	0x31, 0x15, 0x03, 0x0c, 0x50, 0x0b, 0xe6, 0x40, 0x79, 0x8e, 0xb1, 0x92, 0x61, 0xe0, 0x14, 0x5b,
+	0x56, 0xbb, 0xc8, 0x53, 0x93, 0x7d, 0x0e, 0x80, 0x8b, 0x48, 0xc6, 0xa8, 0xae, 0x85, 0x76, 0x9e,
+	0x12, 0x59, 0x5d, 0x21, 0x87, 0x9a, 0x31, 0x28, 0xd2, 0x85, 0x25, 0xba, 0x90, 0xce, 0xe6, 0x97,
+	0x94, 0x8e, 0x51, 0xf8, 0xd7, 0x72, 0xe2, 0x40, 0xcb, 0x6a, 0xd7, 0x79, 0x25, 0x01, 0xfa, 0x13,
+	0xb7, 0x05, 0xa5, 0x93, 0xab, 0x53, 0xa9, 0x34, 0x7b, 0x01, 0xf6, 0x74, 0xee, 0x58, 0xad, 0x42,
+	0xbb, 0x76, 0x50, 0xea, 0x44, 0xa3, 0xce, 0xc9, 0x15, 0xb7, 0xa7, 0x73, 0xf7, 0x10, 0x9e, 0x0d,
+	0x44, 0x20, 0x6f, 0x50, 0xe9, 0xee, 0x9d, 0x08, 0x6e, 0xf1, 0x02, 0x35, 0x7b, 0x03, 0xe5, 0x31,
+	0x19, 0x6a, 0x15, 0xc1, 0x4c, 0xc4, 0xb6, 0x1f, 0x4f, 0x5d, 0xdc, 0x7f, 0x6d, 0x68, 0x6c, 0x73,
+	0xac, 0x01, 0x76, 0x7f, 0x42, 0x2a, 0x15, 0xb9, 0xdd, 0x9f, 0xb0, 0x37, 0x60, 0x0f, 0x23, 0x52,
+	0xa8, 0x71, 0xf0, 0xfa, 0xf1, 0x5d, 0x9d, 0x61, 0x84, 0xb1, 0xd0, 0x32, 0x0c, 0xb8, 0x3d, 0x8c,
+	0x8c, 0xa4, 0xa7, 0x38, 0x47, 0x8f, 0x84, 0xab, 0xf3, 0xc4, 0x60, 0xaf, 0xa0, 0xd2, 0xbd, 0xc3,
+	0xf1, 0x54, 0xcd, 0x7c, 0x92, 0x6d, 0x87, 0xaf, 0x6d, 0xe6, 0xc2, 0x4e, 0x37, 0xf4, 0x66, 0x7e,
+	0x70, 0x2c, 0x7c, 0xe9, 0x2d, 0x49, 0xb9, 0x3a, 0xdf, 0xc2, 0xd8, 0xd7, 0xd0, 0xcc, 0xda, 0x67,
+	0xc2, 0x47, 0x12, 0xb2, 0xca, 0x1f, 0xe1, 0xac, 0x0f, 0xcf, 0xb3, 0xd8, 0x30, 0x32, 0x6f, 0x53,
+	0x4e, 0xb9, 0x65, 0xb5, 0x6b, 0x07, 0x2f, 0x4d, 0x02, 0x39, 0x34, 0xcf, 0x8b, 0x71, 0x7f, 0x81,
+	0xea, 0x3a, 0x3b, 0x06, 0x50, 0xea, 0xf2, 0xde, 0xe1, 0x65, 0xaf, 0xf9, 0xc4, 0x9c, 0xdf, 0xf5,
+	0x4e, 0x7b, 0x97, 0xbd, 0xa6, 0xc5, 0x1c, 0xd8, 0x4b, 0xf0, 0xeb, 0xee, 0xf0, 0xf4, 0xe7, 0xc1,
+	0xd9, 0xf5, 0xf1, 0xe1, 0xa0, 0x7f, 0xfa, 0x6b, 0xd3, 0x36, 0x4c, 0xe2, 0xf5, 0x80, 0x29, 0xb8,
+	0x1f, 0x8a, 0x00, 0x97, 0x62, 0xe4, 0x61, 0x3f, 0x98, 0xe0, 0x82, 0x7d, 0x05, 0xe5, 0xf0, 0xe6,
+	0x46, 0xa1, 0x4e, 0x6b, 0xb6, 0x6b, 0x9e, 0x79, 0xe4, 0x85, 0xe3, 0xe9, 0x90, 0x70, 0x9e, 0xf2,
+	0xac, 0x05, 0xb5, 0x91, 0x17, 0x86, 0xfe, 0xb1, 0xf4, 0x34, 0xc6, 0xab, 0xc6, 0xcd, 0x42, 0x6c,
+	0x1f, 0xc0, 0x17, 0x8b, 0xab, 0x55, 0x93, 0x16, 0xa8, 0x8e, 0x19, 0xc4, 0xd4, 0x62, 0x8a, 0xcb,
+	0x6e, 0x38, 0x0b, 0x34, 0xd5, 0xa2, 0xce, 0xd7, 0x36, 0xfb, 0x02, 0xea, 0x4a, 0x0b, 0x0f, 0xdf,
+	0x09, 0x2d, 0x2e, 0xe4, 0x7b, 0x5c, 0x15, 0x63, 0x1b, 0x34, 0x15, 0xf3, 0xc5, 0xa2, 0x97, 0xb6,
+	0x36, 0x49, 0x5b, 0xe4, 0x5b, 0x98, 0xe9, 0x83, 0x29, 0x2e, 0xfb, 0x13, 0xa7, 0x42, 0x64, 0x62,
+	0xb0, 0x2f, 0xa1, 0x81, 0xc1, 0x38, 0x5e, 0x46, 0x1a, 0x27, 0x94, 0xba, 0x53, 0xa5, 0x04, 0x1e,
+	0xa0, 0xec, 0x7b, 0xd8, 0x95, 0xe6, 0x70, 0x2e, 0x62, 0x2d, 0x93, 0xfa, 0x41, 0xbe, 0x30, 0x0f,
+	0xfd, 0xd8, 0x5b, 0x68, 0xde, 0x90, 0x10, 0x99, 0xd8, 0x5a, 0x7e, 0xec, 0x23, 0x47, 0x76, 0x00,
+	0x7b, 0x51, 0x6a, 0x1d, 0xcb, 0x58, 0x69, 0x72, 0x57, 0xce, 0x4e, 0xab, 0xd0, 0xae, 0xf3, 0x5c,
+	0xce, 0xe8, 0x3d, 0x32, 0xa7, 0x44, 0xd1, 0x3a, 0x09, 0x96, 0x41, 0x58, 0x1b, 0x76, 0xa3, 0x18,
+	0x6f, 0xe4, 0xa2, 0xb7, 0xd0, 0xb1, 0x18, 0xeb, 0x30, 0x76, 0x1a, 0xd4, 0xba, 0x0f, 0x61, 0xba,
+	0x49, 0xde, 0xde, 0xa2, 0xd2, 0x27, 0xb8, 0x74, 0x76, 0x49, 0x99, 0x0c, 0xe2, 0xf6, 0xa1, 0x96,
+	0x79, 0x7e, 0xce, 0x3e, 0x7b, 0x01, 0xa5, 0xa4, 0x4f, 0xa8, 0x2f, 0xea, 0xbc, 0x14, 0xae, 0x3d,
+	0x3d, 0x0c, 0x56, 0x23, 0x69, 0x8e, 0xee, 0x5b, 0x68, 0xf4, 0xb7, 0x84, 0xfb, 0x84, 0x1e, 0x74,
+	0x05, 0x94, 0x4d, 0x2f, 0x9c, 0x24, 0x1b, 0x34, 0x29, 0xb3, 0x95, 0x2d, 0x33, 0x83, 0xe2, 0x44,
+	0x68, 0xb1, 0xea, 0x4e, 0x3a, 0x9b, 0xb5, 0x22, 0xe7, 0xab, 0x75, 0x6a, 0xcb, 0x39, 0x7b, 0x0d,
+	0xd5, 0x71, 0x8c, 0x42, 0xe3, 0xe4, 0x30, 0xe9, 0xc3, 0x02, 0xdf, 0x00, 0xee, 0x1f, 0xa5, 0xdc,
+	0x29, 0xa6, 0xd6, 0x43, 0x9f, 0x46, 0x87, 0xfa, 0xd3, 0xa2, 0xc0, 0x2d, 0xcc, 0xf8, 0x28, 0x45,
+	0xe6, 0x40, 0x2c, 0x2e, 0xde, 0xd3, 0x2b, 0x0a, 0x7c, 0x0b, 0x33, 0xbf, 0x4e, 0x25, 0xa2, 0x4b,
+	0x12, 0x5d, 0x36, 0x00, 0xeb, 0x00, 0x4b, 0x26, 0x4a, 0x78, 0x0a, 0xcf, 0x43, 0x25, 0xb5, 0x9c,
+	0x23, 0x3d, 0xd2, 0xe2, 0x39, 0x8c, 0x19, 0x9b, 0x91, 0x50, 0x48, 0xbb, 0x6e, 0x3d, 0x36, 0x05,
+	0xbe, 0x0d, 0xb2, 0x6f, 0xe0, 0xb9, 0x97, 0x1a, 0x83, 0x99, 0xa7, 0x65, 0xe4, 0x49, 0x8c, 0x69,
+	0x8f, 0xd5, 0x79, 0x1e, 0x65, 0x22, 0x74, 0x9a, 0x56, 0x26, 0xa2, 0x9c, 0x44, 0xe4, 0x50, 0xe9,
+	0x4b, 0x36, 0x02, 0x55, 0x36, 0x2f, 0xd9, 0x28, 0xd4, 0x01, 0x16, 0xcc, 0x7c, 0x7a, 0xd9, 0x6f,
+	0x18, 0x87, 0x44, 0x28, 0x1a, 0xc5, 0x3a, 0xcf, 0x61, 0xcc, 0xd2, 0xf1, 0xc5, 0x82, 0xd0, 0xb3,
+	0x99, 0xbf, 0xfa, 0x52, 0x65, 0x21, 0xb3, 0xa0, 0x57, 0x2d, 0xef, 0x47, 0x31, 0x2a, 0x5a, 0x3d,
+	0x35, 0x1a, 0x9a, 0x47, 0xb8, 0x19, 0x32, 0xc2, 0x38, 0x2a, 0x2d, 0x62, 0xdd, 0x0f, 0x34, 0xc6,
+	0x73, 0xe1, 0x39, 0x3b, 0x74, 0x6d, 0x2e, 0x67, 0x16, 0x07, 0xe1, 0x3f, 0x09, 0x75, 0x97, 0x2c,
+	0x0e, 0x33, 0x68, 0x15, 0xfe, 0x00, 0x35, 0x99, 0x6d, 0x2f, 0x04, 0x12, 0xa1, 0x91, 0x64, 0xf6,
+	0x98, 0x61, 0xdf, 0xc1, 0xcb, 0xb4, 0x77, 0x8e, 0x4c, 0x5d, 0x8f, 0xa4, 0x56, 0xe7, 0x18, 0xa7,
+	0xf3, 0x57, 0xe7, 0x1f, 0xa3, 0xd9, 0x0f, 0xe0, 0xac, 0xb7, 0xa2, 0xc9, 0x4e, 0x8c, 0xe9, 0x1b,
+	0x68, 0xbe, 0x15, 0x4e, 0x93, 0x3a, 0xe5, 0xa3, 0x3c, 0xfb, 0x11, 0x5e, 0x29, 0xc4, 0xe9, 0x06,
+	0x3e, 0x5a, 0x6a, 0x34, 0x17, 0x5f, 0x20, 0x4e, 0x9d, 0x67, 0x54, 0xb2, 0xff, 0xf1, 0x38, 0x6a,
+	0xfe, 0x79, 0xbf, 0x6f, 0xfd, 0x75, 0xbf, 0x6f, 0xfd, 0x7d, 0xbf, 0x6f, 0xfd, 0xfe, 0xcf, 0xfe,
+	0x93, 0x51, 0x89, 0xfe, 0xed, 0x7c, 0xfb, 0xdf, 0x00, 0xd6, 0x8a, 0x43, 0xc3, 0xf9, 0x08, 0x00,
+	0x00,
 }
 
 func (m *KV) Marshal() (dAtA []byte, err error) {
@@ -674,6 +1108,30 @@ func (m *ManifestChange) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.ColumnFamilyOptions != nil {
+		{
+			size, err := m.ColumnFamilyOptions.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintPb(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.ColumnFamilyName) > 0 {
+		i -= len(m.ColumnFamilyName)
+		copy(dAtA[i:], m.ColumnFamilyName)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.ColumnFamilyName)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if m.ColumnFamily != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.ColumnFamily))
+		i--
+		dAtA[i] = 0x28
+	}
 	if len(m.Checksum) > 0 {
 		i -= len(m.Checksum)
 		copy(dAtA[i:], m.Checksum)
@@ -723,21 +1181,103 @@ func (m *TableIndex) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if m.StaleDataSize != 0 {
-		i = encodeVarintPb(dAtA, i, uint64(m.StaleDataSize))
+	if len(m.BiggestKey) > 0 {
+		i -= len(m.BiggestKey)
+		copy(dAtA[i:], m.BiggestKey)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.BiggestKey)))
 		i--
-		dAtA[i] = 0x28
+		dAtA[i] = 0x7a
 	}
-	if m.KeyCount != 0 {
-		i = encodeVarintPb(dAtA, i, uint64(m.KeyCount))
+	if len(m.PrefixExtractor) > 0 {
+		i -= len(m.PrefixExtractor)
+		copy(dAtA[i:], m.PrefixExtractor)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.PrefixExtractor)))
 		i--
-		dAtA[i] = 0x20
+		dAtA[i] = 0x72
 	}
-	if m.MaxVersion != 0 {
-		i = encodeVarintPb(dAtA, i, uint64(m.MaxVersion))
+	if m.BlockCount != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.BlockCount))
 		i--
-		dAtA[i] = 0x18
-	}
+		dAtA[i] = 0x68
+	}
+	if len(m.PartitionFirstBlocks) > 0 {
+		dAtA2 := make([]byte, len(m.PartitionFirstBlocks)*10)
+		var j1 int
+		for _, num := range m.PartitionFirstBlocks {
+			for num >= 1<<7 {
+				dAtA2[j1] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j1++
+			}
+			dAtA2[j1] = uint8(num)
+			j1++
+		}
+		i -= j1
+		copy(dAtA[i:], dAtA2[:j1])
+		i = encodeVarintPb(dAtA, i, uint64(j1))
+		i--
+		dAtA[i] = 0x62
+	}
+	if len(m.FilterPartitions) > 0 {
+		for iNdEx := len(m.FilterPartitions) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.FilterPartitions[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintPb(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x5a
+		}
+	}
+	if len(m.IndexPartitions) > 0 {
+		for iNdEx := len(m.IndexPartitions) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.IndexPartitions[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintPb(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x52
+		}
+	}
+	if len(m.EncryptedIndex) > 0 {
+		i -= len(m.EncryptedIndex)
+		copy(dAtA[i:], m.EncryptedIndex)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.EncryptedIndex)))
+		i--
+		dAtA[i] = 0x4a
+	}
+	if m.KeyId != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.KeyId))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.MaxExpiresAt != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.MaxExpiresAt))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.StaleDataSize != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.StaleDataSize))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.KeyCount != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.KeyCount))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.MaxVersion != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.MaxVersion))
+		i--
+		dAtA[i] = 0x18
+	}
 	if len(m.BloomFilter) > 0 {
 		i -= len(m.BloomFilter)
 		copy(dAtA[i:], m.BloomFilter)
@@ -806,6 +1346,234 @@ func (m *BlockOffset) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *IndexPartition) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *IndexPartition) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *IndexPartition) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Offsets) > 0 {
+		for iNdEx := len(m.Offsets) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Offsets[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintPb(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DataKey) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DataKey) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DataKey) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.CreatedAt != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.CreatedAt))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.Iv) > 0 {
+		i -= len(m.Iv)
+		copy(dAtA[i:], m.Iv)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.Iv)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Data) > 0 {
+		i -= len(m.Data)
+		copy(dAtA[i:], m.Data)
+		i = encodeVarintPb(dAtA, i, uint64(len(m.Data)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.KeyId != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.KeyId))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ColumnFamilyOptions) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ColumnFamilyOptions) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ColumnFamilyOptions) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.SeekCompactionBytesPerSeek != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.SeekCompactionBytesPerSeek))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x88
+	}
+	if m.StaleDataCompactionRatio != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.StaleDataCompactionRatio))))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x81
+	}
+	if m.MemTableBloomBitsPerKey != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.MemTableBloomBitsPerKey))
+		i--
+		dAtA[i] = 0x78
+	}
+	if m.IndexPartitionSize != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.IndexPartitionSize))
+		i--
+		dAtA[i] = 0x70
+	}
+	if m.BlockHashIndex {
+		i--
+		if m.BlockHashIndex {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x68
+	}
+	if m.BlockRestartInterval != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.BlockRestartInterval))
+		i--
+		dAtA[i] = 0x60
+	}
+	if len(m.BlockCompression) > 0 {
+		dAtA4 := make([]byte, len(m.BlockCompression)*10)
+		var j3 int
+		for _, num := range m.BlockCompression {
+			for num >= 1<<7 {
+				dAtA4[j3] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j3++
+			}
+			dAtA4[j3] = uint8(num)
+			j3++
+		}
+		i -= j3
+		copy(dAtA[i:], dAtA4[:j3])
+		i = encodeVarintPb(dAtA, i, uint64(j3))
+		i--
+		dAtA[i] = 0x5a
+	}
+	if m.MaxLevelNum != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.MaxLevelNum))
+		i--
+		dAtA[i] = 0x50
+	}
+	if m.NumLevelZeroTables != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.NumLevelZeroTables))
+		i--
+		dAtA[i] = 0x48
+	}
+	if m.BaseTableSize != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.BaseTableSize))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.TableSizeMultiplier != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.TableSizeMultiplier))
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.LevelSizeMultiplier != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.LevelSizeMultiplier))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.BaseLevelSize != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.BaseLevelSize))
+		i--
+		dAtA[i] = 0x28
+	}
+	if m.BloomFalsePositive != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.BloomFalsePositive))))
+		i--
+		dAtA[i] = 0x21
+	}
+	if m.BlockSize != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.BlockSize))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.SsTableMaxSz != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.SsTableMaxSz))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.MemTableSize != 0 {
+		i = encodeVarintPb(dAtA, i, uint64(m.MemTableSize))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintPb(dAtA []byte, offset int, v uint64) int {
 	offset -= sovPb(v)
 	base := offset
@@ -909,6 +1677,17 @@ func (m *ManifestChange) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovPb(uint64(l))
 	}
+	if m.ColumnFamily != 0 {
+		n += 1 + sovPb(uint64(m.ColumnFamily))
+	}
+	l = len(m.ColumnFamilyName)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
+	if m.ColumnFamilyOptions != nil {
+		l = m.ColumnFamilyOptions.Size()
+		n += 1 + l + sovPb(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -940,6 +1719,46 @@ func (m *TableIndex) Size() (n int) {
 	if m.StaleDataSize != 0 {
 		n += 1 + sovPb(uint64(m.StaleDataSize))
 	}
+	if m.MaxExpiresAt != 0 {
+		n += 1 + sovPb(uint64(m.MaxExpiresAt))
+	}
+	if m.KeyId != 0 {
+		n += 1 + sovPb(uint64(m.KeyId))
+	}
+	l = len(m.EncryptedIndex)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
+	if len(m.IndexPartitions) > 0 {
+		for _, e := range m.IndexPartitions {
+			l = e.Size()
+			n += 1 + l + sovPb(uint64(l))
+		}
+	}
+	if len(m.FilterPartitions) > 0 {
+		for _, e := range m.FilterPartitions {
+			l = e.Size()
+			n += 1 + l + sovPb(uint64(l))
+		}
+	}
+	if len(m.PartitionFirstBlocks) > 0 {
+		l = 0
+		for _, e := range m.PartitionFirstBlocks {
+			l += sovPb(uint64(e))
+		}
+		n += 1 + sovPb(uint64(l)) + l
+	}
+	if m.BlockCount != 0 {
+		n += 1 + sovPb(uint64(m.BlockCount))
+	}
+	l = len(m.PrefixExtractor)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
+	l = len(m.BiggestKey)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -968,46 +1787,906 @@ func (m *BlockOffset) Size() (n int) {
 	return n
 }
 
-func sovPb(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
+func (m *IndexPartition) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Offsets) > 0 {
+		for _, e := range m.Offsets {
+			l = e.Size()
+			n += 1 + l + sovPb(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
 }
-func sozPb(x uint64) (n int) {
-	return sovPb(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+
+func (m *DataKey) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.KeyId != 0 {
+		n += 1 + sovPb(uint64(m.KeyId))
+	}
+	l = len(m.Data)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
+	l = len(m.Iv)
+	if l > 0 {
+		n += 1 + l + sovPb(uint64(l))
+	}
+	if m.CreatedAt != 0 {
+		n += 1 + sovPb(uint64(m.CreatedAt))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
 }
-func (m *KV) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
+
+func (m *ColumnFamilyOptions) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MemTableSize != 0 {
+		n += 1 + sovPb(uint64(m.MemTableSize))
+	}
+	if m.SsTableMaxSz != 0 {
+		n += 1 + sovPb(uint64(m.SsTableMaxSz))
+	}
+	if m.BlockSize != 0 {
+		n += 1 + sovPb(uint64(m.BlockSize))
+	}
+	if m.BloomFalsePositive != 0 {
+		n += 9
+	}
+	if m.BaseLevelSize != 0 {
+		n += 1 + sovPb(uint64(m.BaseLevelSize))
+	}
+	if m.LevelSizeMultiplier != 0 {
+		n += 1 + sovPb(uint64(m.LevelSizeMultiplier))
+	}
+	if m.TableSizeMultiplier != 0 {
+		n += 1 + sovPb(uint64(m.TableSizeMultiplier))
+	}
+	if m.BaseTableSize != 0 {
+		n += 1 + sovPb(uint64(m.BaseTableSize))
+	}
+	if m.NumLevelZeroTables != 0 {
+		n += 1 + sovPb(uint64(m.NumLevelZeroTables))
+	}
+	if m.MaxLevelNum != 0 {
+		n += 1 + sovPb(uint64(m.MaxLevelNum))
+	}
+	if len(m.BlockCompression) > 0 {
+		l = 0
+		for _, e := range m.BlockCompression {
+			l += sovPb(uint64(e))
+		}
+		n += 1 + sovPb(uint64(l)) + l
+	}
+	if m.BlockRestartInterval != 0 {
+		n += 1 + sovPb(uint64(m.BlockRestartInterval))
+	}
+	if m.BlockHashIndex {
+		n += 2
+	}
+	if m.IndexPartitionSize != 0 {
+		n += 1 + sovPb(uint64(m.IndexPartitionSize))
+	}
+	if m.MemTableBloomBitsPerKey != 0 {
+		n += 1 + sovPb(uint64(m.MemTableBloomBitsPerKey))
+	}
+	if m.StaleDataCompactionRatio != 0 {
+		n += 10
+	}
+	if m.SeekCompactionBytesPerSeek != 0 {
+		n += 2 + sovPb(uint64(m.SeekCompactionBytesPerSeek))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func sovPb(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozPb(x uint64) (n int) {
+	return sovPb(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *KV) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowPb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: KV: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: KV: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
+			if m.Key == nil {
+				m.Key = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
+			if m.Value == nil {
+				m.Value = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserMeta", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UserMeta = append(m.UserMeta[:0], dAtA[iNdEx:postIndex]...)
+			if m.UserMeta == nil {
+				m.UserMeta = []byte{}
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpiresAt", wireType)
+			}
+			m.ExpiresAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ExpiresAt |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Meta = append(m.Meta[:0], dAtA[iNdEx:postIndex]...)
+			if m.Meta == nil {
+				m.Meta = []byte{}
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StreamId", wireType)
+			}
+			m.StreamId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StreamId |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipPb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthPb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *KVList) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowPb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: KVList: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: KVList: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Kv", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Kv = append(m.Kv, &KV{})
+			if err := m.Kv[len(m.Kv)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipPb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthPb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowPb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ManifestChangeSet: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ManifestChangeSet: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Changes", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Changes = append(m.Changes, &ManifestChange{})
+			if err := m.Changes[len(m.Changes)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipPb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthPb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ManifestChange) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowPb
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ManifestChange: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ManifestChange: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			m.Id = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Id |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
+			}
+			m.Op = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Op |= ManifestChange_Operation(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Level", wireType)
+			}
+			m.Level = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Level |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Checksum", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Checksum = append(m.Checksum[:0], dAtA[iNdEx:postIndex]...)
+			if m.Checksum == nil {
+				m.Checksum = []byte{}
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ColumnFamily", wireType)
+			}
+			m.ColumnFamily = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ColumnFamily |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ColumnFamilyName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ColumnFamilyName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ColumnFamilyOptions", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ColumnFamilyOptions == nil {
+				m.ColumnFamilyOptions = &ColumnFamilyOptions{}
+			}
+			if err := m.ColumnFamilyOptions.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipPb(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthPb
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TableIndex) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
 		for shift := uint(0); ; shift += 7 {
 			if shift >= 64 {
 				return ErrIntOverflowPb
 			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TableIndex: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TableIndex: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offsets", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Offsets = append(m.Offsets, &BlockOffset{})
+			if err := m.Offsets[len(m.Offsets)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BloomFilter", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BloomFilter = append(m.BloomFilter[:0], dAtA[iNdEx:postIndex]...)
+			if m.BloomFilter == nil {
+				m.BloomFilter = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxVersion", wireType)
+			}
+			m.MaxVersion = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxVersion |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KeyCount", wireType)
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
+			m.KeyCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.KeyCount |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: KV: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: KV: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StaleDataSize", wireType)
 			}
-			var byteLen int
+			m.StaleDataSize = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1017,29 +2696,52 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				m.StaleDataSize |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
-				return ErrInvalidLengthPb
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxExpiresAt", wireType)
 			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthPb
+			m.MaxExpiresAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxExpiresAt |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KeyId", wireType)
 			}
-			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
-			if m.Key == nil {
-				m.Key = []byte{}
+			m.KeyId = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.KeyId |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			iNdEx = postIndex
-		case 2:
+		case 9:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field EncryptedIndex", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -1066,16 +2768,16 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
-			if m.Value == nil {
-				m.Value = []byte{}
+			m.EncryptedIndex = append(m.EncryptedIndex[:0], dAtA[iNdEx:postIndex]...)
+			if m.EncryptedIndex == nil {
+				m.EncryptedIndex = []byte{}
 			}
 			iNdEx = postIndex
-		case 3:
+		case 10:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field UserMeta", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field IndexPartitions", wireType)
 			}
-			var byteLen int
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1085,31 +2787,31 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthPb
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthPb
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.UserMeta = append(m.UserMeta[:0], dAtA[iNdEx:postIndex]...)
-			if m.UserMeta == nil {
-				m.UserMeta = []byte{}
+			m.IndexPartitions = append(m.IndexPartitions, &BlockOffset{})
+			if err := m.IndexPartitions[len(m.IndexPartitions)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
 			iNdEx = postIndex
-		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FilterPartitions", wireType)
 			}
-			m.Version = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1119,16 +2821,107 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Version |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 5:
+			if msglen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FilterPartitions = append(m.FilterPartitions, &BlockOffset{})
+			if err := m.FilterPartitions[len(m.FilterPartitions)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 12:
+			if wireType == 0 {
+				var v uint32
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowPb
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint32(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.PartitionFirstBlocks = append(m.PartitionFirstBlocks, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowPb
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthPb
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthPb
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.PartitionFirstBlocks) == 0 {
+					m.PartitionFirstBlocks = make([]uint32, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint32
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowPb
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint32(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.PartitionFirstBlocks = append(m.PartitionFirstBlocks, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field PartitionFirstBlocks", wireType)
+			}
+		case 13:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ExpiresAt", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockCount", wireType)
 			}
-			m.ExpiresAt = 0
+			m.BlockCount = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1138,16 +2931,16 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ExpiresAt |= uint64(b&0x7F) << shift
+				m.BlockCount |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 6:
+		case 14:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Meta", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PrefixExtractor", wireType)
 			}
-			var byteLen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1157,31 +2950,29 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthPb
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthPb
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Meta = append(m.Meta[:0], dAtA[iNdEx:postIndex]...)
-			if m.Meta == nil {
-				m.Meta = []byte{}
-			}
+			m.PrefixExtractor = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 10:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StreamId", wireType)
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BiggestKey", wireType)
 			}
-			m.StreamId = 0
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1191,11 +2982,26 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.StreamId |= uint32(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BiggestKey = append(m.BiggestKey[:0], dAtA[iNdEx:postIndex]...)
+			if m.BiggestKey == nil {
+				m.BiggestKey = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPb(dAtA[iNdEx:])
@@ -1218,7 +3024,7 @@ func (m *KV) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *KVList) Unmarshal(dAtA []byte) error {
+func (m *BlockOffset) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1241,17 +3047,17 @@ func (m *KVList) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: KVList: wiretype end group for non-group")
+			return fmt.Errorf("proto: BlockOffset: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: KVList: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: BlockOffset: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Kv", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1261,26 +3067,64 @@ func (m *KVList) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthPb
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthPb
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Kv = append(m.Kv, &KV{})
-			if err := m.Kv[len(m.Kv)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
+			if m.Key == nil {
+				m.Key = []byte{}
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+			}
+			m.Offset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Offset |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Len", wireType)
+			}
+			m.Len = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Len |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPb(dAtA[iNdEx:])
@@ -1303,7 +3147,7 @@ func (m *KVList) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
+func (m *IndexPartition) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1326,15 +3170,15 @@ func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ManifestChangeSet: wiretype end group for non-group")
+			return fmt.Errorf("proto: IndexPartition: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ManifestChangeSet: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: IndexPartition: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Changes", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Offsets", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -1361,8 +3205,8 @@ func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Changes = append(m.Changes, &ManifestChange{})
-			if err := m.Changes[len(m.Changes)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.Offsets = append(m.Offsets, &BlockOffset{})
+			if err := m.Offsets[len(m.Offsets)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -1388,7 +3232,7 @@ func (m *ManifestChangeSet) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ManifestChange) Unmarshal(dAtA []byte) error {
+func (m *DataKey) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1411,36 +3255,17 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ManifestChange: wiretype end group for non-group")
+			return fmt.Errorf("proto: DataKey: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ManifestChange: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DataKey: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
-			}
-			m.Id = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowPb
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Id |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field KeyId", wireType)
 			}
-			m.Op = 0
+			m.KeyId = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1450,16 +3275,16 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Op |= ManifestChange_Operation(b&0x7F) << shift
+				m.KeyId |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Level", wireType)
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
 			}
-			m.Level = 0
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1469,14 +3294,29 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Level |= uint32(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 4:
+			if byteLen < 0 {
+				return ErrInvalidLengthPb
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPb
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
+			if m.Data == nil {
+				m.Data = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Checksum", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Iv", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -1503,11 +3343,30 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Checksum = append(m.Checksum[:0], dAtA[iNdEx:postIndex]...)
-			if m.Checksum == nil {
-				m.Checksum = []byte{}
+			m.Iv = append(m.Iv[:0], dAtA[iNdEx:postIndex]...)
+			if m.Iv == nil {
+				m.Iv = []byte{}
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreatedAt", wireType)
+			}
+			m.CreatedAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CreatedAt |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPb(dAtA[iNdEx:])
@@ -1530,7 +3389,7 @@ func (m *ManifestChange) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TableIndex) Unmarshal(dAtA []byte) error {
+func (m *ColumnFamilyOptions) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1553,17 +3412,17 @@ func (m *TableIndex) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TableIndex: wiretype end group for non-group")
+			return fmt.Errorf("proto: ColumnFamilyOptions: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TableIndex: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ColumnFamilyOptions: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Offsets", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MemTableSize", wireType)
 			}
-			var msglen int
+			m.MemTableSize = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1573,31 +3432,16 @@ func (m *TableIndex) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				m.MemTableSize |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthPb
-			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthPb
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Offsets = append(m.Offsets, &BlockOffset{})
-			if err := m.Offsets[len(m.Offsets)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
 		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field BloomFilter", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SsTableMaxSz", wireType)
 			}
-			var byteLen int
+			m.SsTableMaxSz = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1607,31 +3451,46 @@ func (m *TableIndex) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				m.SsTableMaxSz |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
-				return ErrInvalidLengthPb
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockSize", wireType)
 			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthPb
+			m.BlockSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BlockSize |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+		case 4:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BloomFalsePositive", wireType)
 			}
-			m.BloomFilter = append(m.BloomFilter[:0], dAtA[iNdEx:postIndex]...)
-			if m.BloomFilter == nil {
-				m.BloomFilter = []byte{}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
 			}
-			iNdEx = postIndex
-		case 3:
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.BloomFalsePositive = float64(math.Float64frombits(v))
+		case 5:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MaxVersion", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field BaseLevelSize", wireType)
 			}
-			m.MaxVersion = 0
+			m.BaseLevelSize = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1641,16 +3500,16 @@ func (m *TableIndex) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.MaxVersion |= uint64(b&0x7F) << shift
+				m.BaseLevelSize |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 4:
+		case 6:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field KeyCount", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LevelSizeMultiplier", wireType)
 			}
-			m.KeyCount = 0
+			m.LevelSizeMultiplier = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1660,16 +3519,16 @@ func (m *TableIndex) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.KeyCount |= uint32(b&0x7F) << shift
+				m.LevelSizeMultiplier |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 5:
+		case 7:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StaleDataSize", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field TableSizeMultiplier", wireType)
 			}
-			m.StaleDataSize = 0
+			m.TableSizeMultiplier = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1679,67 +3538,149 @@ func (m *TableIndex) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.StaleDataSize |= uint32(b&0x7F) << shift
+				m.TableSizeMultiplier |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipPb(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BaseTableSize", wireType)
 			}
-			if (skippy < 0) || (iNdEx+skippy) < 0 {
-				return ErrInvalidLengthPb
+			m.BaseTableSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BaseTableSize |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NumLevelZeroTables", wireType)
 			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *BlockOffset) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowPb
+			m.NumLevelZeroTables = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.NumLevelZeroTables |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxLevelNum", wireType)
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
+			m.MaxLevelNum = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxLevelNum |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: BlockOffset: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: BlockOffset: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+		case 11:
+			if wireType == 0 {
+				var v uint32
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowPb
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint32(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.BlockCompression = append(m.BlockCompression, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowPb
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthPb
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthPb
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.BlockCompression) == 0 {
+					m.BlockCompression = make([]uint32, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint32
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowPb
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint32(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.BlockCompression = append(m.BlockCompression, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockCompression", wireType)
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockRestartInterval", wireType)
 			}
-			var byteLen int
+			m.BlockRestartInterval = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1749,31 +3690,55 @@ func (m *BlockOffset) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				m.BlockRestartInterval |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
-				return ErrInvalidLengthPb
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockHashIndex", wireType)
 			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthPb
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+			m.BlockHashIndex = bool(v != 0)
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IndexPartitionSize", wireType)
 			}
-			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
-			if m.Key == nil {
-				m.Key = []byte{}
+			m.IndexPartitionSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPb
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.IndexPartitionSize |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			iNdEx = postIndex
-		case 2:
+		case 15:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field MemTableBloomBitsPerKey", wireType)
 			}
-			m.Offset = 0
+			m.MemTableBloomBitsPerKey = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1783,16 +3748,27 @@ func (m *BlockOffset) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Offset |= uint32(b&0x7F) << shift
+				m.MemTableBloomBitsPerKey |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
+		case 16:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StaleDataCompactionRatio", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.StaleDataCompactionRatio = float64(math.Float64frombits(v))
+		case 17:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Len", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field SeekCompactionBytesPerSeek", wireType)
 			}
-			m.Len = 0
+			m.SeekCompactionBytesPerSeek = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowPb
@@ -1802,7 +3778,7 @@ func (m *BlockOffset) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Len |= uint32(b&0x7F) << shift
+				m.SeekCompactionBytesPerSeek |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -104,7 +104,7 @@ func (s *Arena) getKey(offset uint32, size uint16) []byte {
 }
 
 func (s *Arena) getVal(offset uint32, size uint32) (ret ValueStruct) {
-	ret.DecodeValue(s.buf[offset : offset+size])
+	Panic(ret.DecodeValue(s.buf[offset : offset+size]))
 	return
 }
 
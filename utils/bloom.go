@@ -2,9 +2,34 @@ package utils
 
 import "math"
 
-// Filter is an encoded set of []byte keys
+// Filter is an encoded set of []byte keys. The last byte is the number of
+// probes of a classic bloom filter, values past 30 tag other encodings
 type Filter []byte
 
+// encoding tags in the last byte of a Filter
+const (
+	filterBlockedBloom = 0xf0
+	filterXor          = 0xf1
+)
+
+// FilterPolicy builds the filters stored in tables, it works on the
+// utils.Hash of the user keys
+type FilterPolicy interface {
+	// Name identifies the policy in logs and options
+	Name() string
+	// NewFilter builds a filter over the key hashes, hashes may repeat
+	NewFilter(keys []uint32) Filter
+}
+
+type bloomPolicy struct{ bitsPerKey int }
+
+// NewBloomPolicy returns the classic bloom filter policy
+func NewBloomPolicy(bitsPerKey int) FilterPolicy { return bloomPolicy{bitsPerKey} }
+
+func (p bloomPolicy) Name() string { return "bloom" }
+
+func (p bloomPolicy) NewFilter(keys []uint32) Filter { return NewFilter(keys, p.bitsPerKey) }
+
 // MaycontainKey
 func (f Filter) MayContainKey(k []byte) bool {
 	return f.MayContain(Hash(k))
@@ -17,6 +42,12 @@ func (f Filter) MayContain(h uint32) bool {
 		return false
 	}
 	k := f[len(f)-1]
+	switch k {
+	case filterBlockedBloom:
+		return blockedBloomMayContain(f[:len(f)-1], h)
+	case filterXor:
+		return xorMayContain(f[:len(f)-1], h)
+	}
 	if k > 30 {
 		// This is reserved for potentially new encodings for short Bloom filters.
 		// Consider it a match.
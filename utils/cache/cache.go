@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache is a sharded, cost bounded W-TinyLFU cache. New items enter a small
+// LRU window; when the window overflows its oldest item competes with the
+// main space's victim and is only admitted if it was accessed more often
+// recently, which keeps scans from flushing the hot set.
+type Cache struct {
+	shards []*shard
+	hits   uint64
+	misses uint64
+}
+
+// Metrics are the hit and miss counters since the cache was created
+type Metrics struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// Ratio is the hit ratio, 0 before the first lookup
+func (m Metrics) Ratio() float64 {
+	if m.Hits+m.Misses == 0 {
+		return 0
+	}
+	return float64(m.Hits) / float64(m.Hits+m.Misses)
+}
+
+const (
+	numShards = 16
+	// avgItemCost sizes the frequency sketch from the cost budget
+	avgItemCost = 1 << 10
+)
+
+// NewCache returns a cache holding up to maxCost, the unit of cost is up to
+// the caller (bytes for blocks)
+func NewCache(maxCost int64) *Cache {
+	c := &Cache{shards: make([]*shard, numShards)}
+	for i := range c.shards {
+		c.shards[i] = newShard(maxCost / numShards)
+	}
+	return c
+}
+
+func (c *Cache) shardOf(key uint64) *shard {
+	return c.shards[mix(key)%numShards]
+}
+
+// Get returns the value of key
+func (c *Cache) Get(key uint64) (interface{}, bool) {
+	v, ok := c.shardOf(key).get(key)
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+	return v, ok
+}
+
+// Set inserts or replaces key, it reports false if the value was rejected
+// because it is larger than a shard
+func (c *Cache) Set(key uint64, value interface{}, cost int64) bool {
+	return c.shardOf(key).set(key, value, cost, false)
+}
+
+// SetHighPriority inserts key straight into the protected space without
+// going through admission, for index and filter blocks every lookup needs
+func (c *Cache) SetHighPriority(key uint64, value interface{}, cost int64) bool {
+	return c.shardOf(key).set(key, value, cost, true)
+}
+
+// Del removes key
+func (c *Cache) Del(key uint64) {
+	c.shardOf(key).del(key)
+}
+
+// DelRange removes every key in [lo, hi], it walks the whole cache so it is
+// meant for rare bulk drops like the blocks of a deleted table
+func (c *Cache) DelRange(lo, hi uint64) {
+	for _, s := range c.shards {
+		s.delRange(lo, hi)
+	}
+}
+
+// Metrics returns the hit and miss counters
+func (c *Cache) Metrics() Metrics {
+	return Metrics{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+type shard struct {
+	lock   sync.Mutex
+	data   map[uint64]*list.Element
+	window *lru
+	main   *segmentedLRU
+	sketch *cmSketch
+}
+
+func newShard(maxCost int64) *shard {
+	window := maxCost / 100 // 1% like the W-TinyLFU paper
+	if window < 1 {
+		window = 1
+	}
+	return &shard{
+		data:   make(map[uint64]*list.Element),
+		window: newLRU(window),
+		main:   newSegmentedLRU(maxCost - window),
+		sketch: newCmSketch(int(maxCost / avgItemCost)),
+	}
+}
+
+func (s *shard) get(key uint64) (interface{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	h := mix(key)
+	s.sketch.increment(h)
+	e, ok := s.data[key]
+	if !ok {
+		return nil, false
+	}
+	item := e.Value.(*storeItem)
+	if item.stage == stageWindow {
+		s.window.list.MoveToFront(e)
+	} else {
+		s.remap(s.main.touch(e))
+	}
+	return item.value, true
+}
+
+func (s *shard) set(key uint64, value interface{}, cost int64, highPri bool) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if cost > s.main.maxCost {
+		return false
+	}
+	s.sketch.increment(mix(key))
+	if e, ok := s.data[key]; ok {
+		s.removeElement(e)
+	}
+	item := &storeItem{key: key, value: value, cost: cost}
+	if highPri {
+		s.remap(s.main.add(item, true))
+		s.evictMain()
+		return true
+	}
+	item.stage = stageWindow
+	s.data[key] = s.window.pushFront(item)
+	for s.window.overflow() && s.window.list.Len() > 0 {
+		s.admit(s.window.remove(s.window.back()))
+	}
+	return true
+}
+
+// admit moves a candidate evicted from the window into the main space if it
+// is more popular than the items it would push out
+func (s *shard) admit(cand *storeItem) {
+	if cand.cost > s.main.maxCost {
+		delete(s.data, cand.key)
+		return
+	}
+	candFreq := s.sketch.estimate(mix(cand.key))
+	for s.main.cost()+cand.cost > s.main.maxCost {
+		v := s.main.victim()
+		victim := v.Value.(*storeItem)
+		if s.sketch.estimate(mix(victim.key)) >= candFreq {
+			delete(s.data, cand.key)
+			return
+		}
+		s.main.remove(v)
+		delete(s.data, victim.key)
+	}
+	s.remap(s.main.add(cand, false))
+}
+
+// evictMain drops victims until the main space fits, only high priority
+// inserts can overfill it
+func (s *shard) evictMain() {
+	for s.main.cost() > s.main.maxCost {
+		v := s.main.victim()
+		s.main.remove(v)
+		delete(s.data, v.Value.(*storeItem).key)
+	}
+}
+
+func (s *shard) del(key uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if e, ok := s.data[key]; ok {
+		s.removeElement(e)
+	}
+}
+
+func (s *shard) delRange(lo, hi uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, e := range s.data {
+		if key >= lo && key <= hi {
+			s.removeElement(e)
+		}
+	}
+}
+
+func (s *shard) removeElement(e *list.Element) {
+	item := e.Value.(*storeItem)
+	if item.stage == stageWindow {
+		s.window.remove(e)
+	} else {
+		s.main.remove(e)
+	}
+	delete(s.data, item.key)
+}
+
+// remap points the map at elements that were moved between lists
+func (s *shard) remap(moved []*list.Element) {
+	for _, e := range moved {
+		s.data[e.Value.(*storeItem).key] = e
+	}
+}
@@ -0,0 +1,73 @@
+package cache
+
+import "testing"
+
+func TestCacheScanResistance(t *testing.T) {
+	c := NewCache(16 * 100)
+	get := func(k uint64) {
+		if _, ok := c.Get(k); !ok {
+			c.Set(k, k, 1)
+		}
+	}
+	// a hot set of 50 items accessed often
+	for r := 0; r < 20; r++ {
+		for k := uint64(0); k < 50; k++ {
+			get(k)
+		}
+	}
+	// a scan of one-hit items mixed with the hot set
+	for k := uint64(1000); k < 100000; k++ {
+		get(k)
+		if k%20 == 0 {
+			get(k / 20 % 50)
+		}
+	}
+	hit := 0
+	for k := uint64(0); k < 50; k++ {
+		if v, ok := c.Get(k); ok {
+			if v.(uint64) != k {
+				t.Fatalf("key %d has value %v", k, v)
+			}
+			hit++
+		}
+	}
+	if hit < 40 {
+		t.Fatalf("only %d of the hot set survived the scan", hit)
+	}
+}
+
+func TestCacheDel(t *testing.T) {
+	c := NewCache(1 << 20)
+	for k := uint64(0); k < 100; k++ {
+		c.SetHighPriority(k, k, 1)
+	}
+	c.Del(3)
+	if _, ok := c.Get(3); ok {
+		t.Fatal("deleted key still cached")
+	}
+	c.DelRange(10, 19)
+	for k := uint64(0); k < 100; k++ {
+		_, ok := c.Get(k)
+		if want := k != 3 && (k < 10 || k > 19); ok != want {
+			t.Fatalf("key %d cached = %v, want %v", k, ok, want)
+		}
+	}
+}
+
+func TestCacheMetrics(t *testing.T) {
+	c := NewCache(1 << 20)
+	if r := c.Metrics().Ratio(); r != 0 {
+		t.Fatalf("ratio before any lookup = %v", r)
+	}
+	c.Set(1, "a", 1)
+	c.Get(1)
+	c.Get(1)
+	c.Get(2)
+	m := c.Metrics()
+	if m.Hits != 2 || m.Misses != 1 {
+		t.Fatalf("metrics = %+v", m)
+	}
+	if c.Set(3, "big", 1<<30) {
+		t.Fatal("an item larger than a shard was accepted")
+	}
+}
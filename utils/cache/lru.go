@@ -0,0 +1,114 @@
+package cache
+
+import "container/list"
+
+// list stages an item can be in
+const (
+	stageWindow = iota
+	stageProbation
+	stageProtected
+)
+
+type storeItem struct {
+	stage int
+	key   uint64
+	value interface{}
+	cost  int64
+}
+
+// lru is a cost bounded list, the front is the most recently used
+type lru struct {
+	list    *list.List
+	cost    int64
+	maxCost int64
+}
+
+func newLRU(maxCost int64) *lru {
+	return &lru{list: list.New(), maxCost: maxCost}
+}
+
+func (l *lru) pushFront(item *storeItem) *list.Element {
+	l.cost += item.cost
+	return l.list.PushFront(item)
+}
+
+func (l *lru) remove(e *list.Element) *storeItem {
+	item := l.list.Remove(e).(*storeItem)
+	l.cost -= item.cost
+	return item
+}
+
+func (l *lru) back() *list.Element { return l.list.Back() }
+
+func (l *lru) overflow() bool { return l.cost > l.maxCost }
+
+// segmentedLRU is the main space of the cache. New items enter probation,
+// a second hit promotes them to protected, and items pushed out of protected
+// get another chance in probation before they are evicted.
+type segmentedLRU struct {
+	probation *lru
+	protected *lru
+	maxCost   int64
+}
+
+func newSegmentedLRU(maxCost int64) *segmentedLRU {
+	protected := maxCost * 8 / 10
+	return &segmentedLRU{
+		probation: newLRU(maxCost - protected),
+		protected: newLRU(protected),
+		maxCost:   maxCost,
+	}
+}
+
+func (s *segmentedLRU) cost() int64 { return s.probation.cost + s.protected.cost }
+
+// victim is the next item the main space would evict
+func (s *segmentedLRU) victim() *list.Element {
+	if e := s.probation.back(); e != nil {
+		return e
+	}
+	return s.protected.back()
+}
+
+func (s *segmentedLRU) remove(e *list.Element) *storeItem {
+	if e.Value.(*storeItem).stage == stageProtected {
+		return s.protected.remove(e)
+	}
+	return s.probation.remove(e)
+}
+
+// add inserts an item into probation, or straight into protected when it is
+// high priority. Returns the elements that moved so the caller can update its
+// map.
+func (s *segmentedLRU) add(item *storeItem, highPri bool) []*list.Element {
+	if highPri {
+		item.stage = stageProtected
+		return append([]*list.Element{s.protected.pushFront(item)}, s.demote()...)
+	}
+	item.stage = stageProbation
+	return []*list.Element{s.probation.pushFront(item)}
+}
+
+// touch records a hit on e
+func (s *segmentedLRU) touch(e *list.Element) []*list.Element {
+	item := e.Value.(*storeItem)
+	if item.stage == stageProtected {
+		s.protected.list.MoveToFront(e)
+		return nil
+	}
+	s.probation.remove(e)
+	item.stage = stageProtected
+	return append([]*list.Element{s.protected.pushFront(item)}, s.demote()...)
+}
+
+// demote moves the oldest protected items back to probation until protected
+// fits again
+func (s *segmentedLRU) demote() []*list.Element {
+	var moved []*list.Element
+	for s.protected.overflow() && s.protected.list.Len() > 1 {
+		item := s.protected.remove(s.protected.back())
+		item.stage = stageProbation
+		moved = append(moved, s.probation.pushFront(item))
+	}
+	return moved
+}
@@ -0,0 +1,124 @@
+package cache
+
+// cmSketch is a count-min sketch with 4-bit counters estimating how often a
+// key was accessed recently. Counters are halved every resetAt increments so
+// the estimate follows the current workload instead of all of history.
+type cmSketch struct {
+	rows    [cmDepth][]byte // two 4-bit counters per byte
+	seeds   [cmDepth]uint64
+	mask    uint64
+	added   int
+	resetAt int
+	door    doorkeeper
+}
+
+const cmDepth = 4
+
+func newCmSketch(numCounters int) *cmSketch {
+	n := nextPow2(numCounters)
+	s := &cmSketch{
+		mask:    uint64(n - 1),
+		resetAt: 10 * n,
+		door:    newDoorkeeper(n),
+	}
+	seed := uint64(0x9E3779B97F4A7C15)
+	for i := range s.rows {
+		s.rows[i] = make([]byte, n/2)
+		seed = mix(seed)
+		s.seeds[i] = seed
+	}
+	return s
+}
+
+// increment records one access of the key hashed to h. The first access only
+// goes to the doorkeeper so one-hit wonders don't pollute the counters
+func (s *cmSketch) increment(h uint64) {
+	if s.door.add(h) {
+		for i := range s.rows {
+			pos := mix(h^s.seeds[i]) & s.mask
+			shift := (pos & 1) * 4
+			v := (s.rows[i][pos/2] >> shift) & 0x0f
+			if v < 15 {
+				s.rows[i][pos/2] += 1 << shift
+			}
+		}
+	}
+	s.added++
+	if s.added >= s.resetAt {
+		s.reset()
+	}
+}
+
+// estimate returns the access frequency of h, at most 16
+func (s *cmSketch) estimate(h uint64) int {
+	min := byte(15)
+	for i := range s.rows {
+		pos := mix(h^s.seeds[i]) & s.mask
+		v := (s.rows[i][pos/2] >> ((pos & 1) * 4)) & 0x0f
+		if v < min {
+			min = v
+		}
+	}
+	if s.door.has(h) {
+		min++
+	}
+	return int(min)
+}
+
+// reset halves every counter and clears the doorkeeper
+func (s *cmSketch) reset() {
+	for _, row := range s.rows {
+		for j := range row {
+			row[j] = (row[j] >> 1) & 0x77
+		}
+	}
+	s.door.clear()
+	s.added = 0
+}
+
+// doorkeeper is a single hash bitset in front of the sketch
+type doorkeeper struct {
+	bits []uint64
+	mask uint64
+}
+
+func newDoorkeeper(n int) doorkeeper {
+	return doorkeeper{bits: make([]uint64, (n+63)/64), mask: uint64(n - 1)}
+}
+
+// add sets the bit of h and reports whether it was already set
+func (d doorkeeper) add(h uint64) bool {
+	pos := h & d.mask
+	set := d.bits[pos/64]&(1<<(pos%64)) != 0
+	d.bits[pos/64] |= 1 << (pos % 64)
+	return set
+}
+
+func (d doorkeeper) has(h uint64) bool {
+	pos := h & d.mask
+	return d.bits[pos/64]&(1<<(pos%64)) != 0
+}
+
+func (d doorkeeper) clear() {
+	for i := range d.bits {
+		d.bits[i] = 0
+	}
+}
+
+// mix is the splitmix64 finalizer, it spreads sequential keys over all bits
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func nextPow2(n int) int {
+	p := 64
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
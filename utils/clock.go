@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for TTLs and versions. It is injected through
+// the options so tests and trace replays can control time instead of sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock reads the wall clock, it is used when no Clock is configured
+var SystemClock Clock = systemClock{}
+
+// ManualClock only moves when it is told to
+type ManualClock struct {
+	lock sync.RWMutex
+	now  time.Time
+}
+
+// NewManualClock returns a ManualClock stopped at now
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the time the clock is stopped at
+func (c *ManualClock) Now() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.now
+}
+
+// Set moves the clock to now
+func (c *ManualClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	c := NewManualClock(start)
+	if !c.Now().Equal(start) || NewVersion(c) != 1_700_000_000 {
+		t.Fatalf("now %v, version %d", c.Now(), NewVersion(c))
+	}
+	// it doesn't move by itself
+	time.Sleep(time.Millisecond)
+	if !c.Now().Equal(start) {
+		t.Fatal("manual clock moved")
+	}
+	c.Advance(1500 * time.Millisecond)
+	if NewVersion(c) != 1_700_000_001 {
+		t.Fatalf("version %d after 1.5s", NewVersion(c))
+	}
+	c.Set(start.Add(-time.Hour))
+	if NewVersion(c) != 1_700_000_000-3600 {
+		t.Fatalf("version %d after going back", NewVersion(c))
+	}
+	if SystemClock.Now().IsZero() {
+		t.Fatal("system clock is zero")
+	}
+}
+
+func TestKeyWithTs(t *testing.T) {
+	k := KeyWithTs([]byte("user"), 42)
+	if string(ParseKey(k)) != "user" || ParseTs(k) != 42 {
+		t.Fatalf("parsed %q@%d", ParseKey(k), ParseTs(k))
+	}
+	// newer versions sort first
+	if CompareKeys(KeyWithTs([]byte("a"), 2), KeyWithTs([]byte("a"), 1)) >= 0 {
+		t.Fatal("newer version sorts after older")
+	}
+	if CompareKeys(KeyWithTs([]byte("a"), 1), KeyWithTs([]byte("b"), 2)) >= 0 {
+		t.Fatal("user keys out of order")
+	}
+	if !SameKey(KeyWithTs([]byte("a"), 1), KeyWithTs([]byte("a"), 9)) || SameKey(KeyWithTs([]byte("a"), 1), KeyWithTs([]byte("ab"), 1)) {
+		t.Fatal("SameKey")
+	}
+	if ParseTs([]byte("ab")) != 0 || string(ParseKey([]byte("ab"))) != "ab" {
+		t.Fatal("short key")
+	}
+}
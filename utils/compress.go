@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// CompressionType identifies the codec a block was written with, it is
+// stored in the last byte of every block
+type CompressionType byte
+
+const (
+	// NoCompression stores blocks as they are
+	NoCompression CompressionType = iota
+	// LZCompression is the in-repo LZ77 codec, fast with a modest ratio
+	LZCompression
+	// FlateCompression is compress/flate, slower with a better ratio
+	FlateCompression
+)
+
+// CompressBlock compresses src with ct
+func CompressBlock(ct CompressionType, src []byte) ([]byte, error) {
+	switch ct {
+	case NoCompression:
+		return src, nil
+	case LZCompression:
+		return lzCompress(src), nil
+	case FlateCompression:
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(src); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, errors.Wrapf(ErrUnknownCompression, "type %d", ct)
+}
+
+// DecompressBlock reverses CompressBlock
+func DecompressBlock(ct CompressionType, src []byte) ([]byte, error) {
+	switch ct {
+	case NoCompression:
+		return src, nil
+	case LZCompression:
+		return lzDecompress(src)
+	case FlateCompression:
+		r := flate.NewReader(bytes.NewReader(src))
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, errors.Wrapf(ErrUnknownCompression, "type %d", ct)
+}
+
+// lz format: | uvarint(decoded len) | sequence ... |
+// sequence:  | uvarint(literal len) | literals | uvarint(match len) | uvarint(offset) |
+// the last sequence has no match part, it ends where the input ends
+const (
+	lzMinMatch  = 4
+	lzHashBits  = 14
+	lzMaxOffset = 1 << 16
+)
+
+func lzHash(u uint32) uint32 {
+	return (u * 2654435761) >> (32 - lzHashBits)
+}
+
+func lzCompress(src []byte) []byte {
+	dst := make([]byte, 0, len(src)/2+binary.MaxVarintLen64)
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+	var table [1 << lzHashBits]int32 // position + 1, 0 means empty
+
+	anchor, i := 0, 0
+	for i+lzMinMatch <= len(src) {
+		cur := binary.LittleEndian.Uint32(src[i:])
+		h := lzHash(cur)
+		cand := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if cand < 0 || i-cand > lzMaxOffset || binary.LittleEndian.Uint32(src[cand:]) != cur {
+			i++
+			continue
+		}
+		n := lzMinMatch
+		for i+n < len(src) && src[cand+n] == src[i+n] {
+			n++
+		}
+		dst = binary.AppendUvarint(dst, uint64(i-anchor))
+		dst = append(dst, src[anchor:i]...)
+		dst = binary.AppendUvarint(dst, uint64(n))
+		dst = binary.AppendUvarint(dst, uint64(i-cand))
+		i += n
+		anchor = i
+	}
+	dst = binary.AppendUvarint(dst, uint64(len(src)-anchor))
+	return append(dst, src[anchor:]...)
+}
+
+func lzDecompress(src []byte) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, ErrBadCompressedData
+	}
+	src = src[n:]
+	// size is untrusted until the output matches it, don't let it drive
+	// the allocation on its own
+	dst := make([]byte, 0, min(size, uint64(len(src))*8))
+	for {
+		lit, n := binary.Uvarint(src)
+		if n <= 0 || uint64(len(src)-n) < lit || lit > size-uint64(len(dst)) {
+			return nil, ErrBadCompressedData
+		}
+		dst = append(dst, src[n:n+int(lit)]...)
+		src = src[n+int(lit):]
+		if len(src) == 0 {
+			break
+		}
+		mlen, n := binary.Uvarint(src)
+		if n <= 0 || mlen > size-uint64(len(dst)) {
+			return nil, ErrBadCompressedData
+		}
+		src = src[n:]
+		off, n := binary.Uvarint(src)
+		if n <= 0 || off == 0 || off > uint64(len(dst)) {
+			return nil, ErrBadCompressedData
+		}
+		src = src[n:]
+		// byte by byte, the match may overlap what it is copying
+		start := len(dst) - int(off)
+		for j := 0; j < int(mlen); j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+	if uint64(len(dst)) != size {
+		return nil, ErrBadCompressedData
+	}
+	return dst, nil
+}
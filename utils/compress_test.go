@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 4096)
+	rnd.Read(random)
+	inputs := map[string][]byte{
+		"empty":   {},
+		"short":   []byte("abc"),
+		"run":     bytes.Repeat([]byte{'a'}, 10000),
+		"text":    bytes.Repeat([]byte("the quick brown fox "), 200),
+		"random":  random,
+		"overlap": append([]byte("abcd"), bytes.Repeat([]byte("abcdabcd"), 50)...),
+	}
+	for _, ct := range []CompressionType{NoCompression, LZCompression, FlateCompression} {
+		for name, in := range inputs {
+			enc, err := CompressBlock(ct, in)
+			if err != nil {
+				t.Fatalf("%d/%s: compress: %v", ct, name, err)
+			}
+			dec, err := DecompressBlock(ct, enc)
+			if err != nil {
+				t.Fatalf("%d/%s: decompress: %v", ct, name, err)
+			}
+			if !bytes.Equal(dec, in) {
+				t.Fatalf("%d/%s: round trip mismatch", ct, name)
+			}
+		}
+	}
+}
+
+func TestCompressUnknownType(t *testing.T) {
+	if _, err := CompressBlock(CompressionType(9), nil); !errors.Is(err, ErrUnknownCompression) {
+		t.Fatalf("compress err = %v", err)
+	}
+	if _, err := DecompressBlock(CompressionType(9), nil); !errors.Is(err, ErrUnknownCompression) {
+		t.Fatalf("decompress err = %v", err)
+	}
+}
+
+func TestLZDecompressCorrupt(t *testing.T) {
+	uv := func(vs ...uint64) []byte {
+		var b []byte
+		for _, v := range vs {
+			b = binary.AppendUvarint(b, v)
+		}
+		return b
+	}
+	cases := map[string][]byte{
+		"no size":        {},
+		"literal past":   uv(4, 10),
+		"literal > size": append(uv(2, 4), "abcd"...),
+		"match > size":   append(append(uv(8, 4), "abcd"...), uv(1<<40, 4)...),
+		"zero offset":    append(append(uv(8, 4), "abcd"...), uv(4, 0)...),
+		"offset past":    append(append(uv(8, 4), "abcd"...), uv(4, 5)...),
+		"short output":   append(uv(8, 4), "abcd"...),
+		"huge size":      append(uv(1<<62, 4), "abcd"...),
+	}
+	for name, in := range cases {
+		if _, err := lzDecompress(in); !errors.Is(err, ErrBadCompressedData) {
+			t.Fatalf("%s: err = %v, want ErrBadCompressedData", name, err)
+		}
+	}
+}
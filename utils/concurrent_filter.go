@@ -0,0 +1,82 @@
+package utils
+
+import "sync/atomic"
+
+// ConcurrentFilter is a bloom filter that keys are added to while it is being
+// read, for memtables. The bits are laid out like a Filter so it can be
+// encoded as one.
+type ConcurrentFilter struct {
+	words []uint32
+	nBits uint32
+	k     uint8
+}
+
+// NewConcurrentFilter sizes the filter for expectedKeys keys
+func NewConcurrentFilter(expectedKeys, bitsPerKey int) *ConcurrentFilter {
+	k := uint8(float64(bitsPerKey) * 0.69)
+	if k < 1 {
+		k = 1
+	}
+	if k > 30 {
+		k = 30
+	}
+	nBits := expectedKeys * bitsPerKey
+	if nBits < 64 {
+		nBits = 64
+	}
+	nWords := (nBits + 31) / 32
+	return &ConcurrentFilter{
+		words: make([]uint32, nWords),
+		nBits: uint32(nWords * 32),
+		k:     k,
+	}
+}
+
+// Add adds the key hash h
+func (f *ConcurrentFilter) Add(h uint32) {
+	delta := h>>17 | h<<15
+	for j := uint8(0); j < f.k; j++ {
+		bitPos := h % f.nBits
+		w, mask := &f.words[bitPos/32], uint32(1)<<(bitPos%32)
+		for {
+			old := atomic.LoadUint32(w)
+			if old&mask != 0 || atomic.CompareAndSwapUint32(w, old, old|mask) {
+				break
+			}
+		}
+		h += delta
+	}
+}
+
+// AddKey adds k
+func (f *ConcurrentFilter) AddKey(k []byte) { f.Add(Hash(k)) }
+
+// MayContain is Filter.MayContain
+func (f *ConcurrentFilter) MayContain(h uint32) bool {
+	delta := h>>17 | h<<15
+	for j := uint8(0); j < f.k; j++ {
+		bitPos := h % f.nBits
+		if atomic.LoadUint32(&f.words[bitPos/32])&(1<<(bitPos%32)) == 0 {
+			return false
+		}
+		h += delta
+	}
+	return true
+}
+
+// MayContainKey is Filter.MayContainKey
+func (f *ConcurrentFilter) MayContainKey(k []byte) bool { return f.MayContain(Hash(k)) }
+
+// Filter encodes a snapshot of the filter
+func (f *ConcurrentFilter) Filter() Filter {
+	out := make([]byte, len(f.words)*4+1)
+	for i := range f.words {
+		w := atomic.LoadUint32(&f.words[i])
+		out[4*i] = byte(w)
+		out[4*i+1] = byte(w >> 8)
+		out[4*i+2] = byte(w >> 16)
+		out[4*i+3] = byte(w >> 24)
+	}
+	out[len(out)-1] = f.k
+	return out
+}
@@ -2,7 +2,6 @@ package utils
 
 import (
 	"hash/crc32"
-	"math"
 	"os"
 )
 
@@ -15,6 +14,8 @@ const (
 
 // file
 const (
+	KeyRegistryFileName = "KEYREGISTRY"
+	ScanSecretFileName = "SCANSECRET"
 	ManifestFilename = "MANIFEST"
 	ManifestRewriteFilename = "REWRITEMANIFEST"
 	ManifestDeletionsRewriteThreshold = 10000
@@ -25,10 +26,36 @@ const (
 	datasyncFileFlag = 0x0
 )
 
+// meta
+const (
+	// BitDelete marks a tombstone, an empty value is a legitimate value
+	BitDelete byte = 1 << 0
+	// BitValuePointer is set if the value is a pointer into the value log
+	BitValuePointer byte = 1 << 1
+	// BitMerge is set if the value is a merge operand
+	BitMerge byte = 1 << 2
+)
+
 // codec
 var (
-	MagicText    = [4]byte{'H', 'A', 'R', 'D'}
-	MagicVersion = uint32(1)
+	MagicText = [4]byte{'H', 'A', 'R', 'D'}
+	// MagicVersion is bumped whenever the on-disk encoding changes
+	// 2: entries carry meta and userMeta bytes
+	// 3: blocks end with their CompressionType
+	// 4: keys are prefix compressed against the previous key with restart points
+	// 5: blocks may carry a hash index in front of the restarts
+	// 6: the index and bloom filter may be split into partitions
+	MagicVersion = uint32(6)
+	// WalMagic starts every WAL file written with a version, it can't be
+	// mistaken for a record as keys are never empty. Files without it are
+	// version 1.
+	WalMagic = [4]byte{0, 'W', 'A', 'L'}
+	// WalVersion is bumped whenever the record encoding changes
+	// 1: | keyLen | valueLen | expiresAt |, files had no header
+	// 2: meta and userMeta follow valueLen
+	// 3: the column family follows userMeta
+	// 4: the file header names the data key and IV the records are encrypted with
+	WalVersion = uint32(4)
 	// CastagnoliCrcTable is a CRC32 polynomial table
 	CastagnoliCrcTable = crc32.MakeTable(crc32.Castagnoli)
 )
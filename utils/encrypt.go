@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+)
+
+// XORBlock encrypts or decrypts src into dst with AES-CTR, the two are the
+// same operation. dst and src may overlap entirely.
+func XORBlock(dst, src, key, iv []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	stream := cipher.NewCTR(block, iv)
+	stream.XORKeyStream(dst, src)
+	return nil
+}
+
+// XORBlockAllocate is XORBlock into a new slice
+func XORBlockAllocate(src, key, iv []byte) ([]byte, error) {
+	dst := make([]byte, len(src))
+	if err := XORBlock(dst, src, key, iv); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// GenerateIV returns a random IV of aes.BlockSize bytes
+func GenerateIV() ([]byte, error) {
+	iv := make([]byte, aes.BlockSize)
+	_, err := rand.Read(iv)
+	return iv, err
+}
+
+// ValidEncryptionKey reports whether key selects AES-128, AES-192 or AES-256
+func ValidEncryptionKey(key []byte) bool {
+	switch len(key) {
+	case 16, 24, 32:
+		return true
+	}
+	return false
+}
+
+// NewDecryptReader wraps r so that everything read from it is decrypted
+// with key and iv
+func NewDecryptReader(r io.Reader, key, iv []byte) (io.Reader, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.StreamReader{S: cipher.NewCTR(block, iv), R: r}, nil
+}
+
+// XORStream encrypts or decrypts src into dst as the bytes at offset of the
+// AES-CTR stream of key and iv. A file appended to piece by piece this way
+// reads back through NewDecryptReader in one pass, and no two pieces share
+// keystream.
+func XORStream(dst, src, key, iv []byte, offset int64) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	// the counter of the block holding offset: iv + offset/BlockSize
+	ctr := make([]byte, aes.BlockSize)
+	copy(ctr, iv)
+	carry := uint64(offset / aes.BlockSize)
+	for i := aes.BlockSize - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(ctr[i]) + carry&0xff
+		ctr[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	stream := cipher.NewCTR(block, ctr)
+	if skip := int(offset % aes.BlockSize); skip > 0 {
+		var pad [aes.BlockSize]byte
+		stream.XORKeyStream(pad[:skip], pad[:skip])
+	}
+	stream.XORKeyStream(dst, src)
+	return nil
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"io"
+	"testing"
+)
+
+func TestXORStream(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	// the counter carries over from the low bytes of the IV
+	iv := bytes.Repeat([]byte{0xff}, aes.BlockSize)
+	iv[0] = 1
+	plain := make([]byte, 1000)
+	for i := range plain {
+		plain[i] = byte(i * 31)
+	}
+	whole, err := XORBlockAllocate(plain, key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sealing at odd offsets piece by piece gives the same stream
+	pieces := make([]byte, len(plain))
+	for off, n := 0, 1; off < len(plain); off, n = off+n, n+7 {
+		end := min(off+n, len(plain))
+		if err := XORStream(pieces[off:end], plain[off:end], key, iv, int64(off)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(whole, pieces) {
+		t.Fatal("piecewise stream differs from the whole one")
+	}
+	r, err := NewDecryptReader(bytes.NewReader(pieces), key, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("decrypted %d bytes, %v", len(got), err)
+	}
+	if err := XORStream(got, plain, []byte("short"), iv, 0); err == nil {
+		t.Fatal("invalid key accepted")
+	}
+}
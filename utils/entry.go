@@ -6,27 +6,77 @@ import (
 )
 
 type ValueStruct struct {
+	Meta      byte
+	UserMeta  byte
 	Value     []byte
 	ExpiresAt uint64
 }
 
+// EncodedSize | meta | userMeta | expiresAt | value |
 func (vs *ValueStruct) EncodedSize() uint32 {
-	sz := len(vs.Value)
+	sz := len(vs.Value) + 2 // meta, userMeta
 	enc := sizeVarint(vs.ExpiresAt)
 	return uint32(sz + enc)
 }
 
 func (vs *ValueStruct) EncodeValue(b []byte) uint32 {
-	sz := binary.PutUvarint(b[0:], vs.ExpiresAt)
-	n := copy(b[sz:], vs.Value)
-	return uint32(sz + n)
+	b[0] = vs.Meta
+	b[1] = vs.UserMeta
+	sz := binary.PutUvarint(b[2:], vs.ExpiresAt)
+	n := copy(b[2+sz:], vs.Value)
+	return uint32(2 + sz + n)
 }
 
-func (vs *ValueStruct) DecodeValue(b []byte) {
+// DecodeValue decodes b as written by EncodeValue. It returns ErrBadValue
+// if b is too short for the meta bytes and expiresAt.
+func (vs *ValueStruct) DecodeValue(b []byte) error {
+	if len(b) < 2 {
+		return ErrBadValue
+	}
+	expiresAt, sz := binary.Uvarint(b[2:])
+	if sz <= 0 {
+		return ErrBadValue
+	}
+	vs.Meta = b[0]
+	vs.UserMeta = b[1]
+	vs.ExpiresAt = expiresAt
+	vs.Value = b[2+sz:]
+	return nil
+}
+
+// DecodeLegacyValue decodes | expiresAt | value |, the encoding before the
+// meta bytes were added. Deletes were written as empty values back then,
+// they come back as nil so they still read as deletes.
+func (vs *ValueStruct) DecodeLegacyValue(b []byte) error {
 	var sz int
-	vs.ExpiresAt, sz = binary.Uvarint(b[0:])
-	vs.Value = b[sz:]
+	vs.ExpiresAt, sz = binary.Uvarint(b)
+	if sz <= 0 {
+		return ErrBadValue
+	}
+	vs.Value = nil
+	if len(b) > sz {
+		vs.Value = b[sz:]
+	}
+	return nil
+}
+
+// IncrementVarint adds delta to val, a zigzag varint as written by
+// binary.PutVarint, and returns the new encoding and value. An absent
+// value counts as 0.
+func IncrementVarint(val []byte, delta int64) ([]byte, int64, error) {
+	var cur int64
+	if len(val) > 0 {
+		var n int
+		cur, n = binary.Varint(val)
+		if n <= 0 || n != len(val) {
+			return nil, 0, ErrNotInteger
+		}
+	}
+	cur += delta
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutVarint(buf, cur)], cur, nil
 }
+
 func sizeVarint(x uint64) (n int) {
 	for {
 		n++
@@ -44,6 +94,15 @@ type Entry struct {
 	Key       []byte
 	Value     []byte
 	ExpiresAt uint64
+	// Meta holds the internal Bit* flags, UserMeta is left to the user
+	Meta     byte
+	UserMeta byte
+	// TTL is turned into ExpiresAt with the DB's clock when the entry is
+	// written, see WithTTLOnWrite
+	TTL time.Duration
+	// ColumnFamily routes the entry to its family's memtable, 0 is the
+	// default family
+	ColumnFamily uint32
 }
 
 // NewEntry
@@ -54,13 +113,36 @@ func NewEntry(key, value []byte) *Entry {
 	}
 }
 
+// NewDeleteEntry returns a tombstone for key
+func NewDeleteEntry(key []byte) *Entry {
+	return &Entry{
+		Key:  key,
+		Meta: BitDelete,
+	}
+}
+
+// NewMergeEntry returns an entry holding a merge operand for key
+func NewMergeEntry(key, operand []byte) *Entry {
+	return &Entry{
+		Key:   key,
+		Value: operand,
+		Meta:  BitMerge,
+	}
+}
+
 // Entry
 func (e *Entry) Entry() *Entry {
 	return e
 }
 
-func (e *Entry) IsDeletedOrExpired() bool {
-	if e.Value == nil {
+// IsDeletedOrExpiredAt judges the TTL against now instead of the wall clock.
+// A nil value without meta is a delete as it always was, an empty non-nil
+// value is a value.
+func (e *Entry) IsDeletedOrExpiredAt(now time.Time) bool {
+	if e.Meta&BitDelete > 0 {
+		return true
+	}
+	if e.Value == nil && e.Meta&(BitValuePointer|BitMerge) == 0 {
 		return true
 	}
 
@@ -68,23 +150,77 @@ func (e *Entry) IsDeletedOrExpired() bool {
 		return false
 	}
 
-	return e.ExpiresAt <= uint64(time.Now().Unix())
+	return e.ExpiresAt <= uint64(now.Unix())
 }
 
-// WithTTL
+// WithTTL sets the entry to expire dur from now by the wall clock. Use
+// WithTTLFrom to count from another clock, or WithTTLOnWrite to count from
+// the write by the DB's clock.
 func (e *Entry) WithTTL(dur time.Duration) *Entry {
-	e.ExpiresAt = uint64(time.Now().Add(dur).Unix())
+	return e.WithTTLFrom(SystemClock.Now(), dur)
+}
+
+// WithTTLOnWrite makes the entry expire dur after it is written, by the
+// clock of the DB writing it
+func (e *Entry) WithTTLOnWrite(dur time.Duration) *Entry {
+	e.TTL = dur
+	return e
+}
+
+// ResolveTTL sets ExpiresAt from a TTL given with WithTTLOnWrite, counting
+// from now
+func (e *Entry) ResolveTTL(now time.Time) {
+	if e.TTL > 0 {
+		e.WithTTLFrom(now, e.TTL)
+		e.TTL = 0
+	}
+}
+
+// WithTTLFrom sets the entry to expire dur after now
+func (e *Entry) WithTTLFrom(now time.Time, dur time.Duration) *Entry {
+	e.ExpiresAt = uint64(now.Add(dur).Unix())
 	return e
 }
 
 // EncodedSize is the size of the ValueStruct when encoded
 func (e *Entry) EncodedSize() uint32 {
-	sz := len(e.Value)
+	sz := len(e.Value) + 2 // meta, userMeta
 	enc := sizeVarint(e.ExpiresAt)
 	return uint32(sz + enc)
 }
 
 // EstimateSize
 func (e *Entry) EstimateSize() int {
-	return len(e.Key) + len(e.Value)
-}
\ No newline at end of file
+	return len(e.Key) + len(e.Value) + 2 // meta, userMeta
+}
+
+// ValuePtr locates a value written to the value log, entries with
+// BitValuePointer hold it encoded as their value
+type ValuePtr struct {
+	Fid    uint32
+	Len    uint32
+	Offset uint32
+}
+
+// ValuePtrSize is the size of an encoded ValuePtr
+const ValuePtrSize = 12
+
+// Encode returns | fid | len | offset |
+func (p ValuePtr) Encode() []byte {
+	b := make([]byte, ValuePtrSize)
+	binary.BigEndian.PutUint32(b, p.Fid)
+	binary.BigEndian.PutUint32(b[4:], p.Len)
+	binary.BigEndian.PutUint32(b[8:], p.Offset)
+	return b
+}
+
+// Decode reads a pointer written by Encode
+func (p *ValuePtr) Decode(b []byte) error {
+	if len(b) != ValuePtrSize {
+		return ErrBadValuePointer
+	}
+	p.Fid = binary.BigEndian.Uint32(b)
+	p.Len = binary.BigEndian.Uint32(b[4:])
+	p.Offset = binary.BigEndian.Uint32(b[8:])
+	return nil
+}
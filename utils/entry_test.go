@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestEntryDeleteSemantics(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		e    Entry
+		want bool
+	}{
+		{Entry{Value: nil}, true},
+		{Entry{Value: []byte{}}, false},
+		{Entry{Value: []byte("v")}, false},
+		{Entry{Meta: BitDelete}, true},
+		{Entry{Meta: BitMerge}, false},
+		{Entry{Meta: BitValuePointer}, false},
+		{Entry{Value: []byte("v"), ExpiresAt: uint64(now.Unix())}, true},
+		{Entry{Value: []byte("v"), ExpiresAt: uint64(now.Unix() + 60)}, false},
+	}
+	for i, c := range cases {
+		if got := c.e.IsDeletedOrExpiredAt(now); got != c.want {
+			t.Fatalf("case %d %+v: deleted = %v, want %v", i, c.e, got, c.want)
+		}
+	}
+
+	// version 1 tables wrote deletes as empty values
+	var vs ValueStruct
+	vs.DecodeLegacyValue(binary.AppendUvarint(nil, 5))
+	if vs.Value != nil || vs.ExpiresAt != 5 {
+		t.Fatalf("legacy delete decoded as %+v", vs)
+	}
+	vs.DecodeLegacyValue(append(binary.AppendUvarint(nil, 0), 'x'))
+	if string(vs.Value) != "x" {
+		t.Fatalf("legacy value decoded as %+v", vs)
+	}
+}
+
+func TestDecodeValueTooShort(t *testing.T) {
+	for _, b := range [][]byte{nil, {0}, {0, 0}, {0, 0, 0xff}} {
+		var vs ValueStruct
+		if err := vs.DecodeValue(b); err != ErrBadValue {
+			t.Fatalf("decode %v: err = %v, want ErrBadValue", b, err)
+		}
+	}
+	if err := new(ValueStruct).DecodeLegacyValue(nil); err != ErrBadValue {
+		t.Fatalf("decode empty legacy value: err = %v, want ErrBadValue", err)
+	}
+}
+
+func TestEntryTTLUsesClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	e := NewEntry([]byte("k"), []byte("v")).WithTTLOnWrite(time.Minute)
+	if e.ExpiresAt != 0 {
+		t.Fatal("WithTTLOnWrite resolved the expiry before the entry was written")
+	}
+	e.ResolveTTL(clock.Now())
+	if want := uint64(1700000060); e.ExpiresAt != want || e.TTL != 0 {
+		t.Fatalf("ExpiresAt = %d TTL = %v, want %d", e.ExpiresAt, e.TTL, want)
+	}
+	// resolving again keeps the first expiry
+	clock.Advance(time.Hour)
+	e.ResolveTTL(clock.Now())
+	if e.ExpiresAt != 1700000060 {
+		t.Fatalf("ExpiresAt moved to %d", e.ExpiresAt)
+	}
+	if !e.IsDeletedOrExpiredAt(clock.Now()) {
+		t.Fatal("entry not expired an hour later")
+	}
+	// WithTTL counts from the wall clock right away
+	e = NewEntry([]byte("k"), []byte("v")).WithTTL(time.Minute)
+	if e.TTL != 0 || e.ExpiresAt < uint64(time.Now().Unix()) {
+		t.Fatalf("WithTTL left ExpiresAt = %d TTL = %v", e.ExpiresAt, e.TTL)
+	}
+
+	v1 := NewVersion(clock)
+	clock.Advance(time.Second)
+	if v2 := NewVersion(clock); v2 <= v1 {
+		t.Fatalf("version %d after %d", v2, v1)
+	}
+}
@@ -40,6 +40,10 @@ var (
 	ErrTxnTooBig      = errors.New("Txn is too big to fit into one request")
 	ErrDeleteVlogFile = errors.New("Delete vlog file")
 	ErrNoRoom         = errors.New("No room for write")
+	// ErrBadValue is returned for an encoded value too short for its header.
+	ErrBadValue = errors.New("Bad encoded value")
+	// ErrBadValuePointer is returned for a value pointer that doesn't lead to its entry.
+	ErrBadValuePointer = errors.New("Bad value pointer")
 
 	// ErrInvalidRequest is returned if the user request is invalid.
 	ErrInvalidRequest = errors.New("Invalid request")
@@ -49,8 +53,66 @@ var (
 	// ErrRejected is returned if a value log GC is called either while another GC is running, or
 	// after DB::Close has been called.
 	ErrRejected = errors.New("Value log GC request rejected")
+
+	// ErrNoMergeOperator is returned when merge operands are read or compacted
+	// without a MergeOperator configured.
+	ErrNoMergeOperator = errors.New("No merge operator registered")
+
+	// ErrConflict is matched by every ConflictError.
+	ErrConflict = errors.New("Conditional write precondition failed")
+	// ErrNotInteger is returned when Increment finds a value that is not a varint.
+	ErrNotInteger = errors.New("Value is not a varint encoded integer")
+
+	// ErrColumnFamilyExists is returned when creating a family whose name is taken.
+	ErrColumnFamilyExists = errors.New("Column family already exists")
+	// ErrColumnFamilyNotFound is returned when a family name is unknown.
+	ErrColumnFamilyNotFound = errors.New("Column family not found")
+	// ErrDropDefaultColumnFamily is returned when dropping the default family.
+	ErrDropDefaultColumnFamily = errors.New("The default column family can't be dropped")
+
+	// ErrUnknownCompression is returned for a block codec id this build doesn't know.
+	ErrUnknownCompression = errors.New("Unknown compression type")
+	// ErrBadCompressedData is returned when a compressed block can't be decoded.
+	ErrBadCompressedData = errors.New("Corrupted compressed data")
+
+	// ErrInvalidEncryptionKey is returned if the master key is not 16, 24 or 32 bytes long.
+	ErrInvalidEncryptionKey = errors.New("Encryption key's length should be 16, 24 or 32 bytes")
+	// ErrEncryptionKeyMismatch is returned if the key registry was sealed with another master key.
+	ErrEncryptionKeyMismatch = errors.New("Encryption key mismatch")
+	// ErrInvalidDataKeyID is returned if a data key is not in the key registry.
+	ErrInvalidDataKeyID = errors.New("Invalid datakey id")
+
+	// ErrBlockCorrupted is returned when an entry runs past the end of its block.
+	ErrBlockCorrupted = errors.New("Block is corrupted")
+	// ErrWalCorrupted is returned for a bad WAL or value log record followed by good ones.
+	ErrWalCorrupted = errors.New("WAL record is corrupted")
+
+	// ErrInvalidScanToken is returned for a continuation token that wasn't returned by Scan.
+	ErrInvalidScanToken = errors.New("Invalid scan continuation token")
+	// ErrScanTokenExpired is returned when the snapshot of a continuation token is no longer retained.
+	ErrScanTokenExpired = errors.New("Scan snapshot is no longer retained")
+
+	// ErrDBClosed is returned by reads and writes after DB.Close
+	ErrDBClosed = errors.New("DB is closed")
 )
 
+// ConflictError is returned by CompareAndSet and SetIfAbsent when the key
+// did not hold what the caller expected. Current is nil if the key was
+// absent.
+type ConflictError struct {
+	Key     []byte
+	Current []byte
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: key %q", ErrConflict, e.Key)
+}
+
+// Is lets errors.Is(err, ErrConflict) match a ConflictError
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
 // Panic 如果err 不为nil 则panicc
 func Panic(err error) {
 	if err != nil {
@@ -17,12 +17,26 @@ import (
 
 // FID according to file name to get fid
 func FID(name string) uint64 {
+	return fileID(name, ".sst")
+}
+
+// WalFID is FID for WAL files
+func WalFID(name string) uint64 {
+	return fileID(name, ".wal")
+}
+
+// VlogFID is FID for value log files
+func VlogFID(name string) uint64 {
+	return fileID(name, ".vlog")
+}
+
+func fileID(name, ext string) uint64 {
 	name = path.Base(name)
-	if !strings.HasSuffix(name, ".sst") {
+	if !strings.HasSuffix(name, ext) {
 		return 0
 	}
 
-	name = strings.TrimSuffix(name, ".sst")
+	name = strings.TrimSuffix(name, ext)
 	id, err := strconv.Atoi(name)
 	if err != nil {
 		Err(err)
@@ -45,6 +59,16 @@ func FileNameSSTable(dir string, id uint64) string {
 	return filepath.Join(dir, fmt.Sprintf("%05d.sst", id))
 }
 
+// FileNameWal wal file name
+func FileNameWal(dir string, id uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%05d.wal", id))
+}
+
+// FileNameVlog value log file name
+func FileNameVlog(dir string, id uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%05d.vlog", id))
+}
+
 // openDir opens a directory for syncing
 func openDir(path string) (*os.File, error) { return os.Open(path) }
 
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func filterKeys(prefix string, n int) ([][]byte, []uint32) {
+	keys := make([][]byte, n)
+	hashes := make([]uint32, n)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("%s%06d", prefix, i))
+		hashes[i] = Hash(keys[i])
+	}
+	return keys, hashes
+}
+
+func TestFilterPolicies(t *testing.T) {
+	keys, hashes := filterKeys("key", 10000)
+	absent, _ := filterKeys("absent", 10000)
+	for _, c := range []struct {
+		policy FilterPolicy
+		maxFP  float64
+	}{
+		{NewBloomPolicy(10), 0.02},
+		{NewBlockedBloomPolicy(10), 0.03},
+		{NewXorPolicy(), 0.01},
+	} {
+		t.Run(c.policy.Name(), func(t *testing.T) {
+			// versions of a key share its hash
+			f := c.policy.NewFilter(append(hashes, hashes[:100]...))
+			for _, k := range keys {
+				if !f.MayContainKey(k) {
+					t.Fatalf("false negative for %s", k)
+				}
+			}
+			fp := 0
+			for _, k := range absent {
+				if f.MayContainKey(k) {
+					fp++
+				}
+			}
+			if rate := float64(fp) / float64(len(absent)); rate > c.maxFP {
+				t.Fatalf("false positive rate %.4f", rate)
+			}
+
+			empty := c.policy.NewFilter(nil)
+			for _, k := range absent[:100] {
+				if empty.MayContainKey(k) {
+					t.Fatalf("empty filter holds %s", k)
+				}
+			}
+			one := c.policy.NewFilter(hashes[:1])
+			if !one.MayContainKey(keys[0]) {
+				t.Fatal("single key filter misses its key")
+			}
+		})
+	}
+}
+
+func TestFilterEdgeCases(t *testing.T) {
+	if (Filter(nil)).MayContain(1) || (Filter{7}).MayContain(1) {
+		t.Fatal("truncated filter matches")
+	}
+	// unknown encodings match everything
+	if !(Filter{0, 0, 0xee}).MayContain(1) {
+		t.Fatal("unknown encoding rejected a key")
+	}
+	if n := BloomBitsPerKey(1000, 0.01); n < 9 || n > 10 {
+		t.Fatalf("bits per key for 1%%: %d", n)
+	}
+}
+
+func TestConcurrentFilter(t *testing.T) {
+	keys, _ := filterKeys("key", 4000)
+	f := NewConcurrentFilter(len(keys), 10)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(keys); i += 4 {
+				f.AddKey(keys[i])
+				if !f.MayContainKey(keys[i]) {
+					t.Errorf("added %s is missing", keys[i])
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	// the snapshot decodes as a bloom filter with the same bits
+	snap := f.Filter()
+	absent, _ := filterKeys("absent", 4000)
+	for _, k := range keys {
+		if !snap.MayContainKey(k) {
+			t.Fatalf("snapshot misses %s", k)
+		}
+	}
+	for _, k := range absent {
+		if snap.MayContainKey(k) != f.MayContainKey(k) {
+			t.Fatalf("snapshot and filter disagree on %s", k)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package utils
 
+import "bytes"
+
 // Iterator 迭代器
 type Iterator interface {
 	Next()
@@ -20,4 +22,39 @@ type Item interface {
 type Options struct {
 	Prefix []byte
 	IsAsc  bool
+	// LowerBound and UpperBound limit the user keys iterated to
+	// [LowerBound, UpperBound), nil leaves that side open
+	LowerBound []byte
+	UpperBound []byte
+}
+
+// InBounds reports whether userKey is inside the bounds and prefix
+func (opt *Options) InBounds(userKey []byte) bool {
+	if opt.LowerBound != nil && bytes.Compare(userKey, opt.LowerBound) < 0 {
+		return false
+	}
+	if opt.UpperBound != nil && bytes.Compare(userKey, opt.UpperBound) >= 0 {
+		return false
+	}
+	return bytes.HasPrefix(userKey, opt.Prefix)
+}
+
+// Overlaps reports whether the user key range [smallest, biggest] may hold
+// keys inside the bounds and prefix
+func (opt *Options) Overlaps(smallest, biggest []byte) bool {
+	if opt.LowerBound != nil && bytes.Compare(biggest, opt.LowerBound) < 0 {
+		return false
+	}
+	if opt.UpperBound != nil && bytes.Compare(smallest, opt.UpperBound) >= 0 {
+		return false
+	}
+	if len(opt.Prefix) > 0 {
+		if bytes.Compare(biggest, opt.Prefix) < 0 {
+			return false
+		}
+		if bytes.Compare(smallest, opt.Prefix) > 0 && !bytes.HasPrefix(smallest, opt.Prefix) {
+			return false
+		}
+	}
+	return true
 }
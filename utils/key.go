@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"math"
-	"time"
 	"unsafe"
 )
 
@@ -71,6 +70,7 @@ func SafeCopy(a, src []byte) []byte {
 	return append(a[:0], src...)
 }
 
-func NewCurVersion() uint64 {
-	return uint64(time.Now().UnixNano() / 1e9)
+// NewVersion returns the version for a write made at the time of c
+func NewVersion(c Clock) uint64 {
+	return uint64(c.Now().UnixNano() / 1e9)
 }
@@ -3,7 +3,6 @@ package mmap
 
 import(
 	"os"
-	"reflect"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -22,29 +21,7 @@ func mmap(fd *os.File, writeable bool, size int64) ([]byte, error) {
 
 // mremap is a Linux-specific system call to remap pages in memory. This can be used in place of munmap + mmap.
 func mremap(data []byte, size int) ([]byte, error) {
-    // taken from <https://github.com/torvalds/linux/blob/f8394f232b1eab649ce2df5c5f15b0e528c92091/include/uapi/linux/mman.h#L8>
-    const MREMAP_MAYMOVE = 0x1
-
-    // Get the pointer and length of the byte slice using unsafe.
-    ptr := unsafe.Pointer(&data[0])
-    len := len(data)
-
-    mmapAddr, _, errno := unix.Syscall6(
-        unix.SYS_MREMAP,
-        uintptr(ptr),
-        uintptr(len),
-        uintptr(size),
-        uintptr(MREMAP_MAYMOVE),
-        0,
-        0,
-    )
-    if errno != 0 {
-        return nil, errno
-    }
-
-    // Convert the new mmap address back to a byte slice.
-    newData := unsafe.Slice((*byte)(unsafe.Pointer(mmapAddr)), size)
-    return newData, nil
+	return unix.Mremap(data, size, unix.MREMAP_MAYMOVE)
 }
 
 
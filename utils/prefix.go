@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// PrefixExtractor maps user keys to the prefix they are scanned by. The
+// prefixes are added to table filters so prefix scans can skip tables that
+// hold no key with the prefix.
+type PrefixExtractor interface {
+	// Name is stored in tables, filters built with another extractor are ignored
+	Name() string
+	// InDomain reports whether key is long enough to have a prefix
+	InDomain(key []byte) bool
+	// Transform returns the prefix of a key in the domain
+	Transform(key []byte) []byte
+}
+
+type fixedPrefix struct{ n int }
+
+// NewFixedPrefix extracts the first n bytes
+func NewFixedPrefix(n int) PrefixExtractor { return fixedPrefix{n} }
+
+func (p fixedPrefix) Name() string { return "fixed:" + strconv.Itoa(p.n) }
+
+func (p fixedPrefix) InDomain(key []byte) bool { return len(key) >= p.n }
+
+func (p fixedPrefix) Transform(key []byte) []byte { return key[:p.n] }
+
+type delimiterPrefix struct {
+	delim byte
+	n     int
+}
+
+// NewDelimiterPrefix extracts the first n components of keys made of
+// components separated by delim, the last delimiter included: with '/' and
+// 1, "tenant/entity/id" has the prefix "tenant/"
+func NewDelimiterPrefix(delim byte, n int) PrefixExtractor {
+	return delimiterPrefix{delim: delim, n: n}
+}
+
+func (p delimiterPrefix) Name() string {
+	return "delimiter:" + string(p.delim) + ":" + strconv.Itoa(p.n)
+}
+
+func (p delimiterPrefix) end(key []byte) int {
+	off := 0
+	for i := 0; i < p.n; i++ {
+		j := bytes.IndexByte(key[off:], p.delim)
+		if j < 0 {
+			return -1
+		}
+		off += j + 1
+	}
+	return off
+}
+
+func (p delimiterPrefix) InDomain(key []byte) bool { return p.end(key) >= 0 }
+
+func (p delimiterPrefix) Transform(key []byte) []byte { return key[:p.end(key)] }
@@ -0,0 +1,35 @@
+package utils
+
+import "testing"
+
+func TestPrefixExtractors(t *testing.T) {
+	cases := []struct {
+		pe       PrefixExtractor
+		name     string
+		key      string
+		inDomain bool
+		prefix   string
+	}{
+		{NewFixedPrefix(3), "fixed:3", "abcdef", true, "abc"},
+		{NewFixedPrefix(3), "fixed:3", "abc", true, "abc"},
+		{NewFixedPrefix(3), "fixed:3", "ab", false, ""},
+		{NewDelimiterPrefix('/', 1), "delimiter:/:1", "tenant/entity/id", true, "tenant/"},
+		{NewDelimiterPrefix('/', 2), "delimiter:/:2", "tenant/entity/id", true, "tenant/entity/"},
+		{NewDelimiterPrefix('/', 2), "delimiter:/:2", "tenant/entity", false, ""},
+		{NewDelimiterPrefix('/', 1), "delimiter:/:1", "/x", true, "/"},
+		{NewDelimiterPrefix('/', 1), "delimiter:/:1", "", false, ""},
+	}
+	for _, c := range cases {
+		if got := c.pe.Name(); got != c.name {
+			t.Fatalf("name %q, want %q", got, c.name)
+		}
+		if got := c.pe.InDomain([]byte(c.key)); got != c.inDomain {
+			t.Fatalf("%s: InDomain(%q) = %v", c.name, c.key, got)
+		}
+		if c.inDomain {
+			if got := string(c.pe.Transform([]byte(c.key))); got != c.prefix {
+				t.Fatalf("%s: Transform(%q) = %q, want %q", c.name, c.key, got, c.prefix)
+			}
+		}
+	}
+}
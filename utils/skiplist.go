@@ -1,12 +1,10 @@
 package utils
 
 import (
-	"fmt"
 	"log"
 	"math"
-	"strings"
+	"math/rand"
 	"sync/atomic"
-	_ "unsafe"
 
 	"github.com/pkg/errors"
 )
@@ -55,6 +53,10 @@ func (n *node) getNextOffset(h int) uint32 {
 	return atomic.LoadUint32(&n.tower[h])
 }
 
+func (n *node) casNextOffset(h int, old, val uint32) bool {
+	return atomic.CompareAndSwapUint32(&n.tower[h], old, val)
+}
+
 // getVs return ValueStruct stored in node
 func (n *node) getVs(arena *Arena) ValueStruct {
 	valOffset, valSize := n.getValueOffset()
@@ -67,6 +69,9 @@ type Skiplist struct {
 	ref        int32
 	arena      *Arena
 	OnClose    func()
+	// filter holds the user keys and their prefixes, nil if disabled
+	filter *ConcurrentFilter
+	prefix PrefixExtractor
 }
 
 // Increases the refcount
@@ -114,6 +119,36 @@ func NewSkipList(arenaSize int64) *Skiplist {
 	}
 }
 
+// memtableAvgEntrySize sizes memtable filters from the arena size
+const memtableAvgEntrySize = 128
+
+// NewSkipListWithFilter is NewSkipList with a bloom filter of bitsPerKey that
+// lets Search skip absent keys. pe also puts key prefixes in it, may be nil
+func NewSkipListWithFilter(arenaSize int64, bitsPerKey int, pe PrefixExtractor) *Skiplist {
+	s := NewSkipList(arenaSize)
+	s.filter = NewConcurrentFilter(int(arenaSize/memtableAvgEntrySize), bitsPerKey)
+	s.prefix = pe
+	return s
+}
+
+func (s *Skiplist) addToFilter(key []byte) {
+	userKey := ParseKey(key)
+	s.filter.AddKey(userKey)
+	if s.prefix != nil && s.prefix.InDomain(userKey) {
+		s.filter.AddKey(s.prefix.Transform(userKey))
+	}
+}
+
+// MayContainPrefix reports whether keys starting with scanPrefix may be in
+// the list, scanPrefix must be in the domain of the list's prefix extractor
+// to be tested
+func (s *Skiplist) MayContainPrefix(scanPrefix []byte) bool {
+	if s.filter == nil || s.prefix == nil || !s.prefix.InDomain(scanPrefix) {
+		return true
+	}
+	return s.filter.MayContainKey(s.prefix.Transform(scanPrefix))
+}
+
 func (s *Skiplist) randomHeight() int {
 	h := 1
 	for h < maxHeight && FastRand() <= heightIncrease {
@@ -222,16 +257,66 @@ func (s *Skiplist) Add(e *Entry) {
 	// Since we allow overwrite, we may not need to create a new node. We might not even need to
 	// increases the height. Let's defer these actions
 	key, v := e.Key, ValueStruct {
+		Meta: e.Meta,
+		UserMeta: e.UserMeta,
 		Value: e.Value,
 		ExpiresAt: e.ExpiresAt,
 	}
+	// before the node is linked, a reader may not miss it in the filter
+	if s.filter != nil {
+		s.addToFilter(key)
+	}
 
 	listHeight := s.getHeight()
 	var prev [maxHeight + 1]uint32
 	var next [maxHeight + 1]uint32
 	prev[listHeight] = s.headOffset
+	for i := int(listHeight) - 1; i >= 0; i-- {
+		// Use higher level to speed up for current level.
+		prev[i], next[i] = s.findSpliceForLevel(key, prev[i+1], i)
+		if prev[i] == next[i] {
+			s.arena.getNode(prev[i]).setValue(s.arena, encodeValue(s.arena.putVal(v), v.EncodedSize()))
+			return
+		}
+	}
+
+	// We do need to create a new node.
+	height := s.randomHeight()
+	x := newNode(s.arena, key, v, height)
 
+	// Try to increase s.height via CAS.
+	listHeight = s.getHeight()
+	for height > int(listHeight) {
+		if atomic.CompareAndSwapInt32(&s.height, listHeight, int32(height)) {
+			break
+		}
+		listHeight = s.getHeight()
+	}
 
+	// We always insert from the base level and up. After you add a node in base level, we cannot
+	// create a node in the level above because it would have discovered the node in the base level.
+	for i := 0; i < height; i++ {
+		for {
+			if s.arena.getNode(prev[i]) == nil {
+				AssertTrue(i > 1) // This cannot happen in base level.
+				// We haven't computed prev, next for this level because height exceeds old listHeight.
+				// For these levels, we expect the lists to be sparse, so we can just search from head.
+				prev[i], next[i] = s.findSpliceForLevel(key, s.headOffset, i)
+				AssertTrue(prev[i] != next[i])
+			}
+			x.tower[i] = next[i]
+			if s.arena.getNode(prev[i]).casNextOffset(i, next[i], s.arena.getNodeOffset(x)) {
+				break
+			}
+			// CAS failed. We need to recompute prev and next.
+			prev[i], next[i] = s.findSpliceForLevel(key, prev[i], i)
+			if prev[i] == next[i] {
+				AssertTruef(i == 0, "Equality can happen only on base level: %d", i)
+				s.arena.getNode(prev[i]).setValue(s.arena, encodeValue(s.arena.putVal(v), v.EncodedSize()))
+				return
+			}
+		}
+	}
 }
 
 // findLast returns the last element. If head (empty list), we return nil. All the find functions
@@ -255,6 +340,11 @@ func (s *Skiplist) findLast() *node {
 	}
 }
 
+// MemSize is the number of arena bytes in use
+func (s *Skiplist) MemSize() int64 {
+	return s.arena.size()
+}
+
 // Empty returns if the SkipList is empty
 func (s *Skiplist) Empty() bool {
 	return s.findLast() == nil
@@ -263,6 +353,9 @@ func (s *Skiplist) Empty() bool {
 // Get gets the value associated with the key. It returns a valid value if it finds equal or earlier
 // version of the same key.
 func (s *Skiplist) Search(key []byte) ValueStruct {
+	if s.filter != nil && !s.filter.MayContainKey(ParseKey(key)) {
+		return ValueStruct{}
+	}
 	n, _ := s.findNear(key, false, true) // findGreaterOrEqual
 	if n ==nil {
 		return ValueStruct{}
@@ -281,8 +374,71 @@ func (s *Skiplist) Search(key []byte) ValueStruct {
 
 
 
-// go:linkname FastRand runtime.fastrand
-func FastRand() uint32
+// SearchEntry is Search returning the entry found along with its key, nil if
+// there is none. The entry points into the arena.
+func (s *Skiplist) SearchEntry(key []byte) *Entry {
+	if s.filter != nil && !s.filter.MayContainKey(ParseKey(key)) {
+		return nil
+	}
+	n, _ := s.findNear(key, false, true)
+	if n == nil {
+		return nil
+	}
+	nextKey := s.arena.getKey(n.keyOffset, n.keySize)
+	if !SameKey(key, nextKey) {
+		return nil
+	}
+	vs := n.getVs(s.arena)
+	return &Entry{
+		Key:       nextKey,
+		Value:     vs.Value,
+		ExpiresAt: vs.ExpiresAt,
+		Meta:      vs.Meta,
+		UserMeta:  vs.UserMeta,
+	}
+}
+
+// SkipListIterator iterates the keys of a skiplist in ascending order
+type SkipListIterator struct {
+	list *Skiplist
+	n    *node
+}
+
+// NewSkipListIterator holds a reference on the skiplist until Close
+func (s *Skiplist) NewSkipListIterator() Iterator {
+	s.IncrRef()
+	return &SkipListIterator{list: s}
+}
+
+func (s *SkipListIterator) Valid() bool { return s.n != nil }
+
+func (s *SkipListIterator) Next() { s.n = s.list.getNext(s.n, 0) }
+
+func (s *SkipListIterator) Rewind() { s.n = s.list.getNext(s.list.getHead(), 0) }
+
+// Seek moves to the first key >= key
+func (s *SkipListIterator) Seek(key []byte) { s.n, _ = s.list.findNear(key, false, true) }
+
+func (s *SkipListIterator) Item() Item {
+	vs := s.n.getVs(s.list.arena)
+	return &Entry{
+		Key:       s.n.key(s.list.arena),
+		Value:     vs.Value,
+		ExpiresAt: vs.ExpiresAt,
+		Meta:      vs.Meta,
+		UserMeta:  vs.UserMeta,
+	}
+}
+
+func (s *SkipListIterator) Close() error {
+	s.list.DecrRef()
+	return nil
+}
+
+// FastRand returns a pseudo random number, safe for concurrent use
+func FastRand() uint32 {
+	return rand.Uint32()
+}
 
 // AssertTruef is AssertTrue with extra info
 func AssertTruef(b bool, format string, args ...interface{}) {
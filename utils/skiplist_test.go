@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSkipListAddSearch(t *testing.T) {
+	s := NewSkipList(1 << 20)
+	for i := 0; i < 1000; i++ {
+		s.Add(NewEntry(KeyWithTs([]byte(fmt.Sprintf("key%05d", i)), 1), []byte(fmt.Sprintf("val%d", i))))
+	}
+	for i := 0; i < 1000; i++ {
+		vs := s.Search(KeyWithTs([]byte(fmt.Sprintf("key%05d", i)), 1))
+		if string(vs.Value) != fmt.Sprintf("val%d", i) {
+			t.Fatalf("key%05d: got %q", i, vs.Value)
+		}
+	}
+	if vs := s.Search(KeyWithTs([]byte("absent"), 1)); vs.Value != nil {
+		t.Fatalf("absent key found: %q", vs.Value)
+	}
+	// overwrite keeps one node
+	s.Add(NewEntry(KeyWithTs([]byte("key00001"), 1), []byte("new")))
+	if vs := s.Search(KeyWithTs([]byte("key00001"), 1)); string(vs.Value) != "new" {
+		t.Fatalf("overwrite: got %q", vs.Value)
+	}
+}
+
+func TestSkipListVersions(t *testing.T) {
+	s := NewSkipList(1 << 20)
+	for ts := uint64(1); ts <= 5; ts++ {
+		s.Add(NewEntry(KeyWithTs([]byte("k"), ts*10), []byte(fmt.Sprint(ts*10))))
+	}
+	// the newest version at or below the read version
+	for _, c := range []struct {
+		read uint64
+		want string
+	}{{100, "50"}, {35, "30"}, {10, "10"}, {5, ""}} {
+		if vs := s.Search(KeyWithTs([]byte("k"), c.read)); string(vs.Value) != c.want {
+			t.Fatalf("read %d: got %q want %q", c.read, vs.Value, c.want)
+		}
+	}
+}
+
+func TestSkipListSearchEntry(t *testing.T) {
+	s := NewSkipListWithFilter(1<<20, 10, nil)
+	s.Add(NewEntry(KeyWithTs([]byte("k"), 10), []byte("ten")))
+	s.Add(&Entry{Key: KeyWithTs([]byte("k"), 20), Value: []byte("op"), Meta: BitMerge})
+	e := s.SearchEntry(KeyWithTs([]byte("k"), 25))
+	if e == nil || ParseTs(e.Key) != 20 || e.Meta != BitMerge || string(e.Value) != "op" {
+		t.Fatalf("read 25: got %+v", e)
+	}
+	if e := s.SearchEntry(KeyWithTs([]byte("k"), 19)); e == nil || ParseTs(e.Key) != 10 {
+		t.Fatalf("read 19: got %+v", e)
+	}
+	if e := s.SearchEntry(KeyWithTs([]byte("k"), 9)); e != nil {
+		t.Fatalf("read 9: got %+v", e)
+	}
+	if e := s.SearchEntry(KeyWithTs([]byte("kk"), 30)); e != nil {
+		t.Fatalf("absent key: got %+v", e)
+	}
+}
+
+func TestSkipListConcurrentAdd(t *testing.T) {
+	s := NewSkipList(16 << 20)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := KeyWithTs([]byte(fmt.Sprintf("g%d-%05d", g, i)), 1)
+				s.Add(NewEntry(k, k))
+			}
+		}(g)
+	}
+	wg.Wait()
+	n := 0
+	itr := s.NewSkipListIterator()
+	defer itr.Close()
+	var prev []byte
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		k := itr.Item().Entry().Key
+		if prev != nil && CompareKeys(prev, k) >= 0 {
+			t.Fatalf("out of order: %q %q", prev, k)
+		}
+		prev = append(prev[:0], k...)
+		n++
+	}
+	if n != 8000 {
+		t.Fatalf("iterated %d keys, want 8000", n)
+	}
+}
@@ -2,28 +2,94 @@ package utils
 
 import (
 	"bytes"
+	"crypto/aes"
 	"encoding/binary"
+	"fmt"
 	"hash"
 	"hash/crc32"
 	"io"
 )
 
 type WalHeader struct {
-	KeyLen    uint32
-	ValueLen  uint32
-	ExpiresAt uint64
+	KeyLen       uint32
+	ValueLen     uint32
+	Meta         byte
+	UserMeta     byte
+	ColumnFamily uint32
+	ExpiresAt    uint64
 }
 
-const maxHeaderSize int = 21
-func (h WalHeader) Encode(out []byte) int {
+const maxHeaderSize int = 28
+
+// WalFileHeader starts every WAL and value log file since version 2
+// | WalMagic | version | keyID | iv |, keyID and iv since version 4
+type WalFileHeader struct {
+	Version uint32
+	// KeyID is the data key the records are encrypted with, 0 for none
+	KeyID uint64
+	// IV starts the AES-CTR stream the records are encrypted as
+	IV []byte
+}
+
+// walFileHeaderV2Size is the size of | WalMagic | version |
+const walFileHeaderV2Size = len(WalMagic) + 4
+
+// WalFileHeaderSize is the size of the header of the current WalVersion
+const WalFileHeaderSize = walFileHeaderV2Size + 8 + aes.BlockSize
+
+// Encode returns the header in the current WalVersion
+func (h WalFileHeader) Encode() []byte {
+	buf := make([]byte, WalFileHeaderSize)
+	copy(buf, WalMagic[:])
+	binary.BigEndian.PutUint32(buf[len(WalMagic):], WalVersion)
+	binary.BigEndian.PutUint64(buf[walFileHeaderV2Size:], h.KeyID)
+	copy(buf[walFileHeaderV2Size+8:], h.IV)
+	return buf
+}
+
+// DecodeWalFileHeader returns the header of the WAL file starting with buf
+// and its size, 0 for version 1 files which have none
+func DecodeWalFileHeader(buf []byte) (WalFileHeader, int, error) {
+	if len(buf) < len(WalMagic) || !bytes.Equal(buf[:len(WalMagic)], WalMagic[:]) {
+		return WalFileHeader{Version: 1}, 0, nil
+	}
+	if len(buf) < walFileHeaderV2Size {
+		return WalFileHeader{}, 0, ErrBadMagic
+	}
+	h := WalFileHeader{Version: binary.BigEndian.Uint32(buf[len(WalMagic):])}
+	if h.Version < 2 || h.Version > WalVersion {
+		return WalFileHeader{}, 0, fmt.Errorf("%w: unsupported wal version %d", ErrBadMagic, h.Version)
+	}
+	if h.Version < 4 {
+		return h, walFileHeaderV2Size, nil
+	}
+	if len(buf) < WalFileHeaderSize {
+		return WalFileHeader{}, 0, ErrBadMagic
+	}
+	h.KeyID = binary.BigEndian.Uint64(buf[walFileHeaderV2Size:])
+	h.IV = append([]byte{}, buf[walFileHeaderV2Size+8:WalFileHeaderSize]...)
+	return h, WalFileHeaderSize, nil
+}
+
+// Encode writes the header in the encoding of version
+func (h WalHeader) Encode(out []byte, version uint32) int {
 	index := 0
 	index = binary.PutUvarint(out[index:], uint64(h.KeyLen))
 	index += binary.PutUvarint(out[index:], uint64(h.ValueLen))
+	if version >= 2 {
+		out[index] = h.Meta
+		out[index+1] = h.UserMeta
+		index += 2
+	}
+	if version >= 3 {
+		index += binary.PutUvarint(out[index:], uint64(h.ColumnFamily))
+	}
 	index += binary.PutUvarint(out[index:], h.ExpiresAt)
 	return index
 }
 
-func (h *WalHeader) Decode(reader *HashReader) (int, error) {
+// Decode reads a header written in the encoding of version
+func (h *WalHeader) Decode(reader *HashReader, version uint32) (int, error) {
 	var err error
 
 	klen, err := binary.ReadUvarint(reader)
@@ -37,6 +103,21 @@ func (h *WalHeader) Decode(reader *HashReader) (int, error) {
 		return 0, err
 	}
 	h.ValueLen = uint32(vlen)
+	if version >= 2 {
+		if h.Meta, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+		if h.UserMeta, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	if version >= 3 {
+		cf, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return 0, err
+		}
+		h.ColumnFamily = uint32(cf)
+	}
 	h.ExpiresAt, err = binary.ReadUvarint(reader)
 	if err != nil {
 		return 0, err
@@ -44,13 +125,17 @@ func (h *WalHeader) Decode(reader *HashReader) (int, error) {
 	return reader.BytesRead, nil
 }
 
-// WalCodec encoding to write wal file
+// WalCodec encoding to write wal file, in the current WalVersion
 // | header | key | value | crc32 |
+// header: | keyLen | valueLen | meta | userMeta | columnFamily | expiresAt |
 func WalCodec(buf *bytes.Buffer, e *Entry) int {
 	buf.Reset()
 	h := WalHeader{
 		KeyLen: uint32(len(e.Key)),
 		ValueLen: uint32(len(e.Value)),
+		Meta: e.Meta,
+		UserMeta: e.UserMeta,
+		ColumnFamily: e.ColumnFamily,
 		ExpiresAt: e.ExpiresAt,
 	}
 
@@ -59,7 +144,7 @@ func WalCodec(buf *bytes.Buffer, e *Entry) int {
 
 	// encode header
 	var headerEnc [maxHeaderSize]byte
-	sz := h.Encode(headerEnc[:])
+	sz := h.Encode(headerEnc[:], WalVersion)
 	writer.Write(headerEnc[:sz])
 	writer.Write(e.Key)
 	writer.Write(e.Value)
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+// readWalRecord decodes one record written in version
+func readWalRecord(t *testing.T, r io.Reader, version uint32) *Entry {
+	t.Helper()
+	hr := NewHashReader(r)
+	var h WalHeader
+	if _, err := h.Decode(hr, version); err != nil {
+		t.Fatal(err)
+	}
+	kv := make([]byte, h.KeyLen+h.ValueLen)
+	if _, err := io.ReadFull(hr, kv); err != nil {
+		t.Fatal(err)
+	}
+	var crc [crc32.Size]byte
+	if _, err := io.ReadFull(r, crc[:]); err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint32(crc[:]) != hr.Sum32() {
+		t.Fatal("record checksum mismatch")
+	}
+	return &Entry{Key: kv[:h.KeyLen], Value: kv[h.KeyLen:], Meta: h.Meta, UserMeta: h.UserMeta,
+		ColumnFamily: h.ColumnFamily, ExpiresAt: h.ExpiresAt}
+}
+
+func TestWalCodecRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	e := &Entry{Key: KeyWithTs([]byte("key"), 7), Value: []byte("value"), Meta: BitMerge, UserMeta: 9,
+		ColumnFamily: 300, ExpiresAt: 1234}
+	n := WalCodec(&buf, e)
+	if n != buf.Len() || n > EstimateWalCodecSize(e) {
+		t.Fatalf("encoded %d bytes, buffer %d, estimate %d", n, buf.Len(), EstimateWalCodecSize(e))
+	}
+	got := readWalRecord(t, &buf, WalVersion)
+	if !bytes.Equal(got.Key, e.Key) || !bytes.Equal(got.Value, e.Value) ||
+		got.Meta != e.Meta || got.UserMeta != e.UserMeta || got.ExpiresAt != e.ExpiresAt ||
+		got.ColumnFamily != e.ColumnFamily {
+		t.Fatalf("got %+v, want %+v", got, e)
+	}
+}
+
+func TestWalVersion1Record(t *testing.T) {
+	// | keyLen | valueLen | expiresAt | key | value | crc32 |, no meta bytes
+	key, val := KeyWithTs([]byte("old"), 3), []byte("v1")
+	var rec []byte
+	rec = binary.AppendUvarint(rec, uint64(len(key)))
+	rec = binary.AppendUvarint(rec, uint64(len(val)))
+	rec = binary.AppendUvarint(rec, 99)
+	rec = append(append(rec, key...), val...)
+	rec = binary.BigEndian.AppendUint32(rec, crc32.Checksum(rec, CastagnoliCrcTable))
+
+	h, n, err := DecodeWalFileHeader(rec)
+	if err != nil || h.Version != 1 || n != 0 {
+		t.Fatalf("headerless file: version %d size %d err %v", h.Version, n, err)
+	}
+	got := readWalRecord(t, bytes.NewReader(rec), h.Version)
+	if !bytes.Equal(got.Key, key) || !bytes.Equal(got.Value, val) || got.ExpiresAt != 99 || got.Meta != 0 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestWalVersion2Header(t *testing.T) {
+	// version 2 headers have no column family, their entries are in the default one
+	h := WalHeader{KeyLen: 3, ValueLen: 2, Meta: BitDelete, UserMeta: 1, ColumnFamily: 5, ExpiresAt: 77}
+	var buf [maxHeaderSize]byte
+	n := h.Encode(buf[:], 2)
+	if n3 := h.Encode(make([]byte, maxHeaderSize), 3); n3 != n+1 {
+		t.Fatalf("version 3 header is %d bytes, version 2 %d", n3, n)
+	}
+	var got WalHeader
+	if _, err := got.Decode(NewHashReader(bytes.NewReader(buf[:n])), 2); err != nil {
+		t.Fatal(err)
+	}
+	h.ColumnFamily = 0
+	if got != h {
+		t.Fatalf("got %+v, want %+v", got, h)
+	}
+}
+
+func TestWalFileHeader(t *testing.T) {
+	iv := bytes.Repeat([]byte{3}, 16)
+	hdr := WalFileHeader{KeyID: 9, IV: iv}.Encode()
+	h, n, err := DecodeWalFileHeader(append(hdr, 1, 2, 3))
+	if err != nil || h.Version != WalVersion || n != WalFileHeaderSize || h.KeyID != 9 || !bytes.Equal(h.IV, iv) {
+		t.Fatalf("header %+v size %d err %v", h, n, err)
+	}
+	// version 3 headers end after the version and are unencrypted
+	v3 := append([]byte{}, hdr[:8]...)
+	binary.BigEndian.PutUint32(v3[len(WalMagic):], 3)
+	if h, n, err := DecodeWalFileHeader(append(v3, 1, 2, 3)); err != nil || h.Version != 3 || n != 8 || h.KeyID != 0 {
+		t.Fatalf("version 3: header %+v size %d err %v", h, n, err)
+	}
+	if _, _, err := DecodeWalFileHeader(hdr[:WalFileHeaderSize-1]); !errors.Is(err, ErrBadMagic) {
+		t.Fatalf("short version 4 header: err = %v", err)
+	}
+	binary.BigEndian.PutUint32(hdr[len(WalMagic):], WalVersion+1)
+	if _, _, err := DecodeWalFileHeader(hdr); !errors.Is(err, ErrBadMagic) {
+		t.Fatalf("newer version: err = %v", err)
+	}
+	if _, _, err := DecodeWalFileHeader(hdr[:5]); !errors.Is(err, ErrBadMagic) {
+		t.Fatalf("short header: err = %v", err)
+	}
+	if h, n, _ := DecodeWalFileHeader(nil); h.Version != 1 || n != 0 {
+		t.Fatalf("empty file: version %d size %d", h.Version, n)
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sort"
+)
+
+// An xor filter (Graf and Lemire) stores an 8 bit fingerprint per key in
+// three slots so that the fingerprint is the xor of the slots. It takes about
+// 9.9 bits per key for a 0.4% false positive rate, less than a bloom filter
+// needs, but it can't be built incrementally, which suits immutable tables.
+// | fingerprints | seed | blockLength | filterXor |
+const xorTrailerSize = 8 + 4
+
+type xorPolicy struct{}
+
+// NewXorPolicy returns the 8 bit xor filter policy
+func NewXorPolicy() FilterPolicy { return xorPolicy{} }
+
+func (xorPolicy) Name() string { return "xor8" }
+
+func (xorPolicy) NewFilter(keys []uint32) Filter {
+	// versions of a key share the hash, the construction needs distinct keys
+	uniq := append([]uint32{}, keys...)
+	sort.Slice(uniq, func(i, j int) bool { return uniq[i] < uniq[j] })
+	n := 0
+	for i, h := range uniq {
+		if i == 0 || h != uniq[n-1] {
+			uniq[n] = h
+			n++
+		}
+	}
+	uniq = uniq[:n]
+
+	capacity := 32 + (123*len(uniq)+99)/100
+	blockLength := uint32(capacity / 3)
+	fingerprints := make([]byte, 3*blockLength)
+
+	type xorSet struct {
+		mask  uint64
+		count uint32
+	}
+	type keyIndex struct {
+		hash  uint64
+		index uint32
+	}
+	sets := make([]xorSet, len(fingerprints))
+	stack := make([]keyIndex, 0, len(uniq))
+	queue := make([]uint32, 0, len(fingerprints))
+	seed := uint64(0x726b2b9d438b9d4d)
+	for {
+		for i := range sets {
+			sets[i] = xorSet{}
+		}
+		for _, k := range uniq {
+			hash := xorMix(uint64(k) + seed)
+			for _, idx := range xorSlots(hash, blockLength) {
+				sets[idx].mask ^= hash
+				sets[idx].count++
+			}
+		}
+		queue, stack = queue[:0], stack[:0]
+		for i := range sets {
+			if sets[i].count == 1 {
+				queue = append(queue, uint32(i))
+			}
+		}
+		// peel slots holding a single key until none are left
+		for len(queue) > 0 {
+			idx := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if sets[idx].count != 1 {
+				continue
+			}
+			hash := sets[idx].mask
+			stack = append(stack, keyIndex{hash, idx})
+			for _, s := range xorSlots(hash, blockLength) {
+				sets[s].mask ^= hash
+				sets[s].count--
+				if sets[s].count == 1 {
+					queue = append(queue, s)
+				}
+			}
+		}
+		if len(stack) == len(uniq) {
+			break
+		}
+		// a cycle, try another seed
+		seed = xorMix(seed)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		ki := stack[i]
+		slots := xorSlots(ki.hash, blockLength)
+		fingerprints[ki.index] = 0
+		fingerprints[ki.index] = xorFingerprint(ki.hash) ^
+			fingerprints[slots[0]] ^ fingerprints[slots[1]] ^ fingerprints[slots[2]]
+	}
+
+	f := make([]byte, len(fingerprints)+xorTrailerSize+1)
+	n = copy(f, fingerprints)
+	binary.LittleEndian.PutUint64(f[n:], seed)
+	binary.LittleEndian.PutUint32(f[n+8:], blockLength)
+	f[len(f)-1] = filterXor
+	return f
+}
+
+// xorMayContain tests f without its encoding tag
+func xorMayContain(f []byte, h uint32) bool {
+	if len(f) < xorTrailerSize {
+		return false
+	}
+	n := len(f) - xorTrailerSize
+	seed := binary.LittleEndian.Uint64(f[n:])
+	blockLength := binary.LittleEndian.Uint32(f[n+8:])
+	if uint64(blockLength)*3 != uint64(n) {
+		return true // not ours, don't drop data over it
+	}
+	hash := xorMix(uint64(h) + seed)
+	slots := xorSlots(hash, blockLength)
+	return xorFingerprint(hash) == f[slots[0]]^f[slots[1]]^f[slots[2]]
+}
+
+func xorSlots(hash uint64, blockLength uint32) [3]uint32 {
+	reduce := func(x uint32) uint32 { return uint32((uint64(x) * uint64(blockLength)) >> 32) }
+	return [3]uint32{
+		reduce(uint32(hash)),
+		reduce(uint32(bits.RotateLeft64(hash, 21))) + blockLength,
+		reduce(uint32(bits.RotateLeft64(hash, 42))) + 2*blockLength,
+	}
+}
+
+func xorFingerprint(hash uint64) byte {
+	return byte(hash ^ hash>>32)
+}
+
+// xorMix is the murmur3 finalizer
+func xorMix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}